@@ -2,14 +2,22 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"os"
+	"strings"
 	"time"
+	_ "time/tzdata" // quiet hours use IANA timezones, which slim images lack
 
 	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/auth"
+	"github.com/harrypall/havn-backend/internal/events"
 	"github.com/harrypall/havn-backend/internal/handlers"
 	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/push"
+	"github.com/harrypall/havn-backend/internal/rbac"
 	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/harrypall/havn-backend/internal/webhook"
 	"github.com/harrypall/havn-backend/pkg/database"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
@@ -48,18 +56,68 @@ func main() {
 
 	log.Info().Msg("Database connection established")
 
-	// Initialize Auth0 JWT verification
-	if err := middleware.InitAuth0(); err != nil {
-		log.Fatal().Err(err).Msg("Failed to initialize Auth0")
+	// Initialize JWT verification for every trusted issuer
+	issuers, err := auth.LoadIssuers()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load token issuers")
+	}
+	devIssuer := newDevIssuer(port)
+	var extraVerifiers []auth.TokenVerifier
+	if devIssuer != nil {
+		extraVerifiers = append(extraVerifiers, devIssuer.Verifier())
+	}
+	tokenVerifier, err := auth.Build(context.Background(), issuers, extraVerifiers...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize token verification")
 	}
-	log.Info().Msg("Auth0 JWT verification initialized")
+	log.Info().Strs("issuers", tokenVerifier.Issuers()).Msg("JWT verification initialized")
+
+	// Domain events fan out to every replica
+	bus := eventBus(db)
 
 	// Initialize services
 	spotService := services.NewSpotService(db)
-	occupancyService := services.NewOccupancyService(db)
-	userService := services.NewUserService(db)
-	friendService := services.NewFriendService(db)
-	spotSaveService := services.NewSpotSaveService(db)
+	userService := services.NewUserService(db, bus)
+	limitService := services.NewLimitService(db)
+	notificationService := services.NewNotificationService(db)
+	deviceService := services.NewDeviceService(db)
+	proximityService := services.NewProximityService(db, notificationService)
+	watchService := services.NewWatchService(db, notificationService)
+	waitlistService := services.NewWaitlistService(db, notificationService)
+	occupancyFeed := services.NewOccupancyFeed(db, bus)
+	presenceFeed := services.NewPresenceFeed(db, bus)
+	occupancyService := services.NewOccupancyService(db, proximityService, watchService, waitlistService, bus)
+	friendService := services.NewFriendService(db, limitService, notificationService, bus)
+	friendGroupService := services.NewFriendGroupService(db, bus)
+	spotSaveService := services.NewSpotSaveService(db, limitService, friendGroupService, notificationService, bus)
+	shareService := services.NewShareService(db, bus)
+	inviteService := services.NewInviteService(db, friendService, notificationService, inviteSigningSecret(env), inviteBaseURL())
+	syncService := services.NewSyncService(db)
+	identityService := services.NewIdentityService(db)
+	roleService := services.NewRoleService(db)
+	webhookSender := newWebhookSender(env)
+	webhookService := services.NewWebhookService(db, webhookSender)
+
+	// ADMIN_USER_IDS (comma separated profile ids) seeds the first super
+	// admins; every other role is granted through /admin/users/:id/roles
+	if err := roleService.BootstrapSuperAdmins(context.Background(), strings.Split(os.Getenv("ADMIN_USER_IDS"), ",")); err != nil {
+		log.Error().Err(err).Msg("Failed to bootstrap super admins")
+	}
+
+	// Start background workers
+	go bus.Run(context.Background())
+	outboxRelay := services.NewOutboxRelay(db)
+	outboxRelay.Register(events.SpotOccupancyChanged, "webhooks", webhookService.EnqueueDeliveries)
+	go outboxRelay.Run(context.Background())
+	webhookDispatcher := services.NewWebhookDispatcher(db, webhookSender)
+	go webhookDispatcher.Run(context.Background())
+	pushDispatcher := services.NewPushDispatcher(db, deviceService, pushSender())
+	go pushDispatcher.Run(context.Background())
+	go occupancyService.RunAutoCheckout(context.Background())
+	go waitlistService.Run(context.Background())
+	go occupancyFeed.Run(context.Background())
+	go presenceFeed.Run(context.Background())
+	go syncService.Run(context.Background())
 
 	// Initialize handlers
 	spotHandler := handlers.NewSpotHandler(spotService)
@@ -67,6 +125,18 @@ func main() {
 	userHandler := handlers.NewUserHandler(userService)
 	friendHandler := handlers.NewFriendHandler(friendService)
 	spotSaveHandler := handlers.NewSpotSaveHandler(spotSaveService)
+	inviteHandler := handlers.NewInviteHandler(inviteService)
+	shareHandler := handlers.NewShareHandler(shareService)
+	friendGroupHandler := handlers.NewFriendGroupHandler(friendGroupService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	deviceHandler := handlers.NewDeviceHandler(deviceService)
+	watchHandler := handlers.NewWatchHandler(watchService)
+	waitlistHandler := handlers.NewWaitlistHandler(waitlistService)
+	streamHandler := handlers.NewStreamHandler(occupancyFeed, presenceFeed)
+	outboxHandler := handlers.NewOutboxHandler(outboxRelay)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	syncHandler := handlers.NewSyncHandler(syncService)
+	roleHandler := handlers.NewRoleHandler(roleService)
 
 	// Set up Gin
 	if env == "production" {
@@ -97,15 +167,34 @@ func main() {
 			auth.POST("/login", userHandler.Login)
 		}
 
-		// Protected routes (require Auth0 authentication)
+		// Development token issuer (ENV=development with DEV_AUTH=true only)
+		if devIssuer != nil {
+			devAuthHandler := handlers.NewDevAuthHandler(devIssuer, identityService)
+			devAuth := api.Group("/dev-auth")
+			{
+				devAuth.GET("/.well-known/jwks.json", devAuthHandler.JWKS)
+				devAuth.GET("/users", devAuthHandler.ListUsers)
+				devAuth.POST("/token", devAuthHandler.MintToken)
+			}
+		}
+
+		// Protected routes (require a token from a trusted issuer)
 		protected := api.Group("")
-		protected.Use(middleware.Auth0Middleware())
+		protected.Use(middleware.Auth(tokenVerifier, identityService), middleware.LoadRoles(roleService))
 		{
 			// Spots
 			spots := protected.Group("/spots")
 			{
 				spots.GET("", spotHandler.GetSpots)
+				spots.GET("/stream", streamHandler.StreamOccupancy)
 				spots.GET("/:id", spotHandler.GetSpotByID)
+				spots.GET("/:id/watch", watchHandler.GetWatch)
+				spots.POST("/:id/watch", watchHandler.CreateWatch)
+				spots.PUT("/:id/watch", watchHandler.UpdateWatch)
+				spots.DELETE("/:id/watch", watchHandler.DeleteWatch)
+				spots.GET("/:id/waitlist", waitlistHandler.GetStatus)
+				spots.POST("/:id/waitlist", waitlistHandler.Join)
+				spots.DELETE("/:id/waitlist", waitlistHandler.Leave)
 			}
 
 			// Occupancy
@@ -121,14 +210,40 @@ func main() {
 				users.GET("/search", userHandler.Search)
 				users.GET("/me", userHandler.GetProfile)
 				users.PUT("/me", userHandler.UpdateProfile)
+				users.GET("/me/preferences", userHandler.GetPreferences)
+				users.PUT("/me/preferences", userHandler.UpdatePreferences)
+				users.GET("/me/shares", shareHandler.GetShares)
+				users.POST("/me/shares", shareHandler.CreateShare)
+				users.DELETE("/me/shares/:id", shareHandler.RevokeShare)
+				users.GET("/me/devices", deviceHandler.GetDevices)
+				users.POST("/me/devices", deviceHandler.RegisterDevice)
+				users.DELETE("/me/devices/:id", deviceHandler.UnregisterDevice)
+				users.GET("/me/roles", roleHandler.GetMyRoles)
 			}
 
 			// Friends
 			friends := protected.Group("/friends")
 			{
 				friends.GET("", friendHandler.GetFriends)
+				friends.GET("/suggestions", friendHandler.GetSuggestions)
+				friends.GET("/nearby", friendHandler.GetNearbyFriends)
+				friends.GET("/stream", streamHandler.StreamPresence)
 				friends.POST("/request", friendHandler.SendRequest)
 				friends.POST("/respond", friendHandler.RespondToRequest)
+				friends.DELETE("/:id", friendHandler.RemoveFriend)
+				friends.DELETE("/requests/:id", friendHandler.CancelRequest)
+				friends.POST("/:id/block", friendHandler.Block)
+				friends.POST("/:id/unblock", friendHandler.Unblock)
+				friends.POST("/invites", inviteHandler.CreateInvite)
+				friends.DELETE("/invites/:id", inviteHandler.RevokeInvite)
+				friends.POST("/invites/:token/redeem", inviteHandler.RedeemInvite)
+				friends.GET("/invites/:token/qr", inviteHandler.GetInviteQRCode)
+				friends.GET("/groups", friendGroupHandler.GetGroups)
+				friends.POST("/groups", friendGroupHandler.CreateGroup)
+				friends.PUT("/groups/:id", friendGroupHandler.UpdateGroup)
+				friends.DELETE("/groups/:id", friendGroupHandler.DeleteGroup)
+				friends.POST("/groups/:id/members", friendGroupHandler.AddMember)
+				friends.DELETE("/groups/:id/members/:friend_id", friendGroupHandler.RemoveMember)
 			}
 
 			// Spot saves
@@ -138,6 +253,57 @@ func main() {
 				spotSaves.POST("/request", spotSaveHandler.CreateRequest)
 				spotSaves.POST("/respond", spotSaveHandler.Respond)
 			}
+
+			// Notifications
+			notifications := protected.Group("/notifications")
+			{
+				notifications.GET("", notificationHandler.GetNotifications)
+				notifications.GET("/unread-count", notificationHandler.GetUnreadCount)
+				notifications.POST("/read", notificationHandler.MarkAllRead)
+				notifications.POST("/:id/read", notificationHandler.MarkRead)
+				notifications.DELETE("/:id", notificationHandler.DeleteNotification)
+			}
+
+			// Delta sync for offline caches
+			protected.GET("/sync", syncHandler.Sync)
+
+			// Webhooks (admins and partner accounts)
+			webhooks := protected.Group("/webhooks")
+			{
+				webhooks.GET("", webhookHandler.ListSubscriptions)
+				webhooks.POST("", webhookHandler.CreateSubscription)
+				webhooks.GET("/:id", webhookHandler.GetSubscription)
+				webhooks.PUT("/:id", webhookHandler.UpdateSubscription)
+				webhooks.DELETE("/:id", webhookHandler.DeleteSubscription)
+				webhooks.POST("/:id/rotate-secret", webhookHandler.RotateSecret)
+				webhooks.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+				webhooks.POST("/:id/deliveries/:delivery_id/replay", webhookHandler.ReplayDelivery)
+			}
+
+			// Admin (staff roles; each route checks its own permission)
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireRole(rbac.StaffRoles...))
+			{
+				verifySpots := middleware.RequirePermission(rbac.PermSpotsVerify)
+				admin.PUT("/spots/:id/verification", verifySpots, spotHandler.SetVerified)
+
+				manageRoles := middleware.RequirePermission(rbac.PermRolesManage)
+				admin.GET("/users/:id/roles", manageRoles, roleHandler.ListUserRoles)
+				admin.POST("/users/:id/roles", manageRoles, roleHandler.GrantRole)
+				admin.DELETE("/users/:id/roles/:role", manageRoles, roleHandler.RevokeRole)
+				admin.GET("/role-audit", middleware.RequirePermission(rbac.PermAuditRead), roleHandler.ListAudit)
+
+				manageOutbox := middleware.RequirePermission(rbac.PermOutboxManage)
+				admin.GET("/outbox", manageOutbox, outboxHandler.ListEvents)
+				admin.GET("/outbox/stats", manageOutbox, outboxHandler.GetStats)
+				admin.GET("/outbox/:id", manageOutbox, outboxHandler.GetEvent)
+				admin.POST("/outbox/:id/retry", manageOutbox, outboxHandler.RetryEvent)
+
+				managePartners := middleware.RequirePermission(rbac.PermPartnersManage)
+				admin.GET("/partner-scopes", managePartners, webhookHandler.ListPartnerScopes)
+				admin.POST("/partner-scopes", managePartners, webhookHandler.GrantPartnerScope)
+				admin.DELETE("/partner-scopes/:id", managePartners, webhookHandler.RevokePartnerScope)
+			}
 		}
 	}
 
@@ -149,3 +315,98 @@ func main() {
 	}
 }
 
+// pushSender returns the push sender selected by PUSH_SENDER ("expo" by
+// default, or "fake" to record pushes in memory during local development)
+func pushSender() services.PushSender {
+	if os.Getenv("PUSH_SENDER") == "fake" {
+		log.Warn().Msg("PUSH_SENDER=fake, push notifications will not be delivered")
+		return push.NewFakeSender()
+	}
+	return push.NewExpoSender(os.Getenv("EXPO_PUSH_URL"), os.Getenv("EXPO_ACCESS_TOKEN"))
+}
+
+// eventBus returns the domain event bus. EVENT_BUS=memory keeps events inside
+// this process, which is only correct with a single replica.
+func eventBus(db *database.Database) events.Bus {
+	if os.Getenv("EVENT_BUS") == "memory" {
+		log.Warn().Msg("EVENT_BUS=memory, events will not reach other replicas")
+		return events.NewMemoryBus()
+	}
+	return events.NewPostgresBus(db.Pool)
+}
+
+// newWebhookSender returns the sender for partner webhooks.
+// WEBHOOK_ALLOW_PRIVATE_URLS=true permits plain http and private addresses so
+// a local receiver can be used during development; it is refused in production.
+func newWebhookSender(env string) *webhook.HTTPSender {
+	allowPrivate := os.Getenv("WEBHOOK_ALLOW_PRIVATE_URLS") == "true"
+	if allowPrivate && env == "production" {
+		log.Fatal().Msg("WEBHOOK_ALLOW_PRIVATE_URLS must not be enabled in production")
+	}
+	return webhook.NewHTTPSender(10*time.Second, allowPrivate)
+}
+
+// newDevIssuer returns the built-in development token issuer when DEV_AUTH=true,
+// or nil. It refuses to start unless ENV is explicitly set to development,
+// since anyone could mint tokens for the seeded users. Tokens only carry
+// roles with DEV_AUTH_ALLOW_ROLES=true.
+func newDevIssuer(port string) *auth.DevIssuer {
+	if os.Getenv("DEV_AUTH") != "true" {
+		return nil
+	}
+	// Check the raw value: an unset ENV defaults to development elsewhere
+	if env := os.Getenv("ENV"); env != "development" {
+		log.Fatal().Str("env", env).Msg("DEV_AUTH must only be enabled with ENV=development")
+	}
+
+	issuer := os.Getenv("DEV_AUTH_ISSUER")
+	if issuer == "" {
+		issuer = fmt.Sprintf("http://localhost:%s/api/v1/dev-auth/", port)
+	}
+	audience := os.Getenv("AUTH0_AUDIENCE")
+	if audience == "" {
+		audience = "https://havn-api"
+	}
+	users := os.Getenv("DEV_AUTH_USERS")
+	if users == "" {
+		users = "alice,bob,carol,dave"
+	}
+
+	allowRoles := os.Getenv("DEV_AUTH_ALLOW_ROLES") == "true"
+
+	devIssuer, err := auth.NewDevIssuer(issuer, audience, strings.Split(users, ","), allowRoles)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize dev token issuer")
+	}
+
+	log.Warn().Str("issuer", issuer).Bool("allow_roles", allowRoles).Msg("Development token issuer enabled; never use this in production")
+	return devIssuer
+}
+
+// inviteSigningSecret returns the key used to sign friend invite tokens. Outside
+// production a random per-process key is used if none is configured.
+func inviteSigningSecret(env string) []byte {
+	secret := os.Getenv("INVITE_SIGNING_SECRET")
+	if secret != "" {
+		return []byte(secret)
+	}
+
+	if env == "production" {
+		log.Fatal().Msg("INVITE_SIGNING_SECRET must be set in production")
+	}
+
+	log.Warn().Msg("INVITE_SIGNING_SECRET not set, using a random key (invites won't survive restarts)")
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal().Err(err).Msg("Failed to generate invite signing key")
+	}
+	return key
+}
+
+// inviteBaseURL returns the link prefix that invite tokens are appended to
+func inviteBaseURL() string {
+	if url := os.Getenv("INVITE_BASE_URL"); url != "" {
+		return url
+	}
+	return "havn://invite/"
+}
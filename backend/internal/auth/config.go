@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/jwks"
+	"github.com/rs/zerolog/log"
+)
+
+// IssuerConfig describes one trusted token issuer
+type IssuerConfig struct {
+	Issuer    string `json:"issuer"`
+	Algorithm string `json:"algorithm"`
+
+	// Asymmetric algorithms fetch keys from JWKSURL; HMAC algorithms read the
+	// shared secret from the environment variable named by SecretEnv
+	JWKSURL   string `json:"jwks_url,omitempty"`
+	SecretEnv string `json:"secret_env,omitempty"`
+
+	Audience string        `json:"audience,omitempty"`
+	Claims   *ClaimMapping `json:"claims,omitempty"`
+}
+
+// LoadIssuers reads the trusted issuers from AUTH_ISSUERS (a JSON array of
+// IssuerConfig). Without it, Auth0 and Supabase are configured from their
+// AUTH0_* and SUPABASE_* variables when set.
+func LoadIssuers() ([]IssuerConfig, error) {
+	if raw := os.Getenv("AUTH_ISSUERS"); raw != "" {
+		var configs []IssuerConfig
+		if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+			return nil, fmt.Errorf("invalid AUTH_ISSUERS: %w", err)
+		}
+		return configs, nil
+	}
+
+	var configs []IssuerConfig
+
+	if domain := os.Getenv("AUTH0_DOMAIN"); domain != "" {
+		// Auth0 only allows namespaced custom claims, set by an Action
+		claims := DefaultClaimMapping
+		claims.Roles = envOr("AUTH0_ROLES_CLAIM", "https://havn-api/roles")
+
+		cfg := IssuerConfig{
+			Issuer:    envOr("AUTH0_ISSUER", fmt.Sprintf("https://%s/", domain)),
+			Algorithm: "RS256",
+			JWKSURL:   envOr("AUTH0_JWKS_URL", fmt.Sprintf("https://%s/.well-known/jwks.json", domain)),
+			Audience:  envOr("AUTH0_AUDIENCE", "https://havn-api"),
+			Claims:    &claims,
+		}
+		configs = append(configs, cfg)
+	}
+
+	if os.Getenv("SUPABASE_JWT_SECRET") != "" {
+		issuer := os.Getenv("SUPABASE_ISSUER")
+		if issuer == "" && os.Getenv("SUPABASE_URL") != "" {
+			issuer = strings.TrimSuffix(os.Getenv("SUPABASE_URL"), "/") + "/auth/v1"
+		}
+		if issuer == "" {
+			return nil, fmt.Errorf("SUPABASE_JWT_SECRET is set but neither SUPABASE_ISSUER nor SUPABASE_URL is")
+		}
+
+		configs = append(configs, IssuerConfig{
+			Issuer:    issuer,
+			Algorithm: "HS256",
+			SecretEnv: "SUPABASE_JWT_SECRET",
+			Audience:  "authenticated",
+			Claims: &ClaimMapping{
+				Subject:            "sub",
+				Email:              "email",
+				Nickname:           "user_metadata.user_name",
+				Name:               "user_metadata.full_name",
+				Picture:            "user_metadata.avatar_url",
+				Roles:              "app_metadata.roles",
+				SubjectIsProfileID: true,
+			},
+		})
+	}
+
+	return configs, nil
+}
+
+// Build creates a registry for the configured issuers plus any extra
+// in-process verifiers. JWKS-backed issuers refresh their keys in the
+// background until ctx is cancelled; if an endpoint is down at startup its
+// keys are fetched on demand later.
+func Build(ctx context.Context, configs []IssuerConfig, extra ...TokenVerifier) (*Registry, error) {
+	if len(configs)+len(extra) == 0 {
+		return nil, fmt.Errorf("no trusted token issuers configured")
+	}
+
+	keyOpts, err := jwksOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	verifiers := make([]TokenVerifier, 0, len(configs))
+	for _, cfg := range configs {
+		keys, err := keySource(ctx, cfg, keyOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		claims := DefaultClaimMapping
+		if cfg.Claims != nil {
+			claims = *cfg.Claims
+		}
+
+		verifiers = append(verifiers, NewJWTVerifier(cfg.Issuer, cfg.Algorithm, keys, cfg.Audience, claims))
+		log.Info().Str("issuer", cfg.Issuer).Str("algorithm", cfg.Algorithm).Msg("Trusting token issuer")
+	}
+
+	for _, v := range extra {
+		verifiers = append(verifiers, v)
+		log.Info().Str("issuer", v.Issuer()).Msg("Trusting token issuer")
+	}
+
+	return NewRegistry(verifiers...), nil
+}
+
+// keySource validates cfg and returns where its verification keys come from
+func keySource(ctx context.Context, cfg IssuerConfig, opts jwks.Options) (KeySource, error) {
+	if cfg.Issuer == "" {
+		return nil, fmt.Errorf("issuer is required")
+	}
+
+	switch {
+	case strings.HasPrefix(cfg.Algorithm, "HS"):
+		if cfg.SecretEnv == "" {
+			return nil, fmt.Errorf("issuer %s: secret_env is required for %s", cfg.Issuer, cfg.Algorithm)
+		}
+		secret := os.Getenv(cfg.SecretEnv)
+		if secret == "" {
+			return nil, fmt.Errorf("issuer %s: %s not set", cfg.Issuer, cfg.SecretEnv)
+		}
+		return StaticKey{Value: []byte(secret)}, nil
+
+	case strings.HasPrefix(cfg.Algorithm, "RS"), strings.HasPrefix(cfg.Algorithm, "PS"),
+		strings.HasPrefix(cfg.Algorithm, "ES"), cfg.Algorithm == "EdDSA":
+		if cfg.JWKSURL == "" {
+			return nil, fmt.Errorf("issuer %s: jwks_url is required for %s", cfg.Issuer, cfg.Algorithm)
+		}
+
+		cache := jwks.New(cfg.JWKSURL, opts)
+
+		fetchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := cache.Refresh(fetchCtx); err != nil {
+			log.Warn().Err(err).Str("url", cfg.JWKSURL).Msg("Initial JWKS fetch failed, will retry on demand")
+		}
+
+		go cache.Run(ctx)
+		return cache, nil
+
+	default:
+		return nil, fmt.Errorf("issuer %s: unsupported algorithm %q", cfg.Issuer, cfg.Algorithm)
+	}
+}
+
+func jwksOptions() (jwks.Options, error) {
+	refreshInterval, err := durationEnv("JWKS_REFRESH_INTERVAL", time.Hour)
+	if err != nil {
+		return jwks.Options{}, err
+	}
+	minRefetchInterval, err := durationEnv("JWKS_MIN_REFETCH_INTERVAL", time.Minute)
+	if err != nil {
+		return jwks.Options{}, err
+	}
+
+	return jwks.Options{
+		RefreshInterval:    refreshInterval,
+		MinRefetchInterval: minRefetchInterval,
+	}, nil
+}
+
+// durationEnv reads a duration like "15m" from the environment
+func durationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return d, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
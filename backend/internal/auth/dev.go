@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// maxDevTokenTTL caps how long a minted development token lives
+const maxDevTokenTTL = 24 * time.Hour
+
+// DevUser is a seeded account the development issuer can mint tokens for
+type DevUser struct {
+	Username string `json:"username"`
+	Subject  string `json:"subject"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+}
+
+// DevIssuer is a built-in RS256 issuer for local end-to-end testing. Its key
+// is generated at startup, so tokens don't survive a restart. It must never
+// be enabled in production.
+type DevIssuer struct {
+	issuer   string
+	audience string
+	kid      string
+	key      *rsa.PrivateKey
+	users    []DevUser
+
+	// allowRoles lets minted tokens carry role claims. Off by default, since
+	// anyone who can reach the issuer could otherwise mint a super_admin.
+	allowRoles bool
+}
+
+// NewDevIssuer creates an issuer for the given seeded usernames. Tokens may
+// only carry roles if allowRoles is set.
+func NewDevIssuer(issuer, audience string, usernames []string, allowRoles bool) (*DevIssuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate dev signing key: %w", err)
+	}
+
+	kid := make([]byte, 8)
+	if _, err := rand.Read(kid); err != nil {
+		return nil, fmt.Errorf("failed to generate dev key id: %w", err)
+	}
+
+	d := &DevIssuer{
+		issuer:   issuer,
+		audience: audience,
+		kid:      "dev-" + hex.EncodeToString(kid),
+		key:      key,
+
+		allowRoles: allowRoles,
+	}
+
+	for _, username := range usernames {
+		username = strings.ToLower(strings.TrimSpace(username))
+		if username == "" {
+			continue
+		}
+		d.users = append(d.users, DevUser{
+			Username: username,
+			Subject:  "dev|" + username,
+			Email:    username + "@havn.test",
+			Name:     strings.ToUpper(username[:1]) + username[1:],
+		})
+	}
+	if len(d.users) == 0 {
+		return nil, fmt.Errorf("at least one dev user is required")
+	}
+
+	return d, nil
+}
+
+// Issuer returns the iss claim of minted tokens
+func (d *DevIssuer) Issuer() string {
+	return d.issuer
+}
+
+// Users lists the seeded accounts
+func (d *DevIssuer) Users() []DevUser {
+	return d.users
+}
+
+// Verifier checks minted tokens like any other trusted issuer's
+func (d *DevIssuer) Verifier() *JWTVerifier {
+	claims := DefaultClaimMapping
+	claims.Roles = "roles"
+	return NewJWTVerifier(d.issuer, "RS256", d, d.audience, claims)
+}
+
+// Key returns the public signing key, satisfying KeySource
+func (d *DevIssuer) Key(ctx context.Context, kid string) (interface{}, error) {
+	if kid != d.kid {
+		return nil, fmt.Errorf("key not found")
+	}
+	return &d.key.PublicKey, nil
+}
+
+// Mint signs a token for a seeded user, carrying roles if the issuer allows it
+func (d *DevIssuer) Mint(username string, ttl time.Duration, roles []string) (string, time.Time, error) {
+	if len(roles) > 0 && !d.allowRoles {
+		return "", time.Time{}, fmt.Errorf("dev token roles not allowed")
+	}
+
+	username = strings.ToLower(strings.TrimSpace(username))
+
+	var user *DevUser
+	for i := range d.users {
+		if d.users[i].Username == username {
+			user = &d.users[i]
+			break
+		}
+	}
+	if user == nil {
+		return "", time.Time{}, fmt.Errorf("dev user not found")
+	}
+
+	if ttl <= 0 || ttl > maxDevTokenTTL {
+		ttl = time.Hour
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	claims := jwt.MapClaims{
+		"iss":            d.issuer,
+		"sub":            user.Subject,
+		"aud":            d.audience,
+		"iat":            now.Unix(),
+		"exp":            expiresAt.Unix(),
+		"email":          user.Email,
+		"email_verified": true,
+		"nickname":       user.Username,
+		"name":           user.Name,
+	}
+	if len(roles) > 0 {
+		claims["roles"] = roles
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = d.kid
+
+	signed, err := token.SignedString(d.key)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign dev token: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// JWKS returns the public key set, as served to clients that verify tokens
+// themselves
+func (d *DevIssuer) JWKS() (jwk.Set, error) {
+	key, err := jwk.FromRaw(&d.key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build dev JWK: %w", err)
+	}
+	if err := key.Set(jwk.KeyIDKey, d.kid); err != nil {
+		return nil, err
+	}
+	if err := key.Set(jwk.AlgorithmKey, jwa.RS256); err != nil {
+		return nil, err
+	}
+	if err := key.Set(jwk.KeyUsageKey, "sig"); err != nil {
+		return nil, err
+	}
+
+	set := jwk.NewSet()
+	if err := set.AddKey(key); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
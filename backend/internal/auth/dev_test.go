@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"testing"
+	"time"
+)
+
+const devIssuerURL = "http://localhost:8080/api/v1/dev-auth/"
+
+func TestDevIssuerTokensVerifyThroughRegistry(t *testing.T) {
+	dev, err := NewDevIssuer(devIssuerURL, "https://havn-api", []string{"alice", " Bob "}, true)
+	if err != nil {
+		t.Fatalf("NewDevIssuer: %v", err)
+	}
+
+	registry := NewRegistry(dev.Verifier())
+
+	token, expiresAt, err := dev.Mint("bob", 10*time.Minute, []string{"moderator"})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	if time.Until(expiresAt) > 10*time.Minute {
+		t.Errorf("expiresAt = %v, want within the requested TTL", expiresAt)
+	}
+
+	p, err := registry.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if p.Identity.Issuer != devIssuerURL || p.Identity.Subject != "dev|bob" {
+		t.Errorf("identity = %+v", p.Identity)
+	}
+	if p.Identity.Nickname != "bob" || p.Identity.Email != "bob@havn.test" {
+		t.Errorf("claims not mapped: %+v", p.Identity)
+	}
+	if len(p.Roles) != 1 || p.Roles[0] != "moderator" {
+		t.Errorf("Roles = %v, want [moderator]", p.Roles)
+	}
+
+	if _, _, err := dev.Mint("mallory", time.Minute, nil); err == nil {
+		t.Error("minted a token for an unseeded user")
+	}
+}
+
+func TestDevIssuerRejectsRolesUnlessAllowed(t *testing.T) {
+	dev, err := NewDevIssuer(devIssuerURL, "https://havn-api", []string{"alice"}, false)
+	if err != nil {
+		t.Fatalf("NewDevIssuer: %v", err)
+	}
+
+	if _, _, err := dev.Mint("alice", time.Minute, []string{"super_admin"}); err == nil {
+		t.Error("minted a token with roles without opting in")
+	}
+
+	token, _, err := dev.Mint("alice", time.Minute, nil)
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	p, err := dev.Verifier().Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(p.Roles) != 0 {
+		t.Errorf("Roles = %v, want none", p.Roles)
+	}
+}
+
+func TestDevIssuerJWKSMatchesSigningKey(t *testing.T) {
+	dev, err := NewDevIssuer(devIssuerURL, "https://havn-api", []string{"alice"}, false)
+	if err != nil {
+		t.Fatalf("NewDevIssuer: %v", err)
+	}
+
+	set, err := dev.JWKS()
+	if err != nil {
+		t.Fatalf("JWKS: %v", err)
+	}
+
+	key, ok := set.LookupKeyID(dev.kid)
+	if !ok {
+		t.Fatalf("JWKS has no key %q", dev.kid)
+	}
+
+	var pub rsa.PublicKey
+	if err := key.Raw(&pub); err != nil {
+		t.Fatalf("Raw: %v", err)
+	}
+	if !pub.Equal(&dev.key.PublicKey) {
+		t.Error("JWKS key does not match the signing key")
+	}
+
+	// Tokens from another dev issuer instance (e.g. before a restart) fail
+	other, err := NewDevIssuer(devIssuerURL, "https://havn-api", []string{"alice"}, false)
+	if err != nil {
+		t.Fatalf("NewDevIssuer: %v", err)
+	}
+	token, _, err := other.Mint("alice", time.Minute, nil)
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	if _, err := dev.Verifier().Verify(context.Background(), token); err == nil {
+		t.Error("accepted a token signed by a different key")
+	}
+}
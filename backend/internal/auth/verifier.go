@@ -0,0 +1,274 @@
+// Package auth verifies bearer tokens from a configured list of trusted
+// issuers, so users can move between identity providers without a flag day.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/harrypall/havn-backend/internal/models"
+)
+
+// leeway tolerates clock skew between us and the issuer
+const leeway = 30 * time.Second
+
+var (
+	// ErrUntrustedIssuer is returned for tokens whose iss isn't configured
+	ErrUntrustedIssuer = errors.New("untrusted issuer")
+
+	// ErrInvalidAudience is returned when the token isn't meant for this API
+	ErrInvalidAudience = errors.New("invalid audience")
+
+	// ErrMissingSubject is returned when the mapped subject claim is empty
+	ErrMissingSubject = errors.New("missing subject")
+)
+
+// Principal is the verified identity behind a token
+type Principal struct {
+	Identity models.IdentityClaims
+	Roles    []string
+	Claims   jwt.MapClaims
+}
+
+// TokenVerifier checks tokens signed by one issuer
+type TokenVerifier interface {
+	// Issuer is the exact iss claim this verifier accepts
+	Issuer() string
+
+	// Verify checks the signature and standard claims and maps the identity
+	Verify(ctx context.Context, token string) (*Principal, error)
+}
+
+// KeySource returns the verification key for a token's kid. kid is empty when
+// the token has none.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (interface{}, error)
+}
+
+// StaticKey is a KeySource with a single key, such as an HMAC secret, used
+// regardless of kid
+type StaticKey struct {
+	Value interface{}
+}
+
+// Key returns the static key
+func (k StaticKey) Key(ctx context.Context, kid string) (interface{}, error) {
+	return k.Value, nil
+}
+
+// ClaimMapping names the claims identity fields are read from. Nested claims
+// use dots, e.g. "user_metadata.full_name". Empty fields are not mapped.
+type ClaimMapping struct {
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"email_verified"`
+	Nickname      string `json:"nickname"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+
+	// Roles is a string or array of role names. Only map claims the issuer
+	// controls, never user-editable metadata.
+	Roles string `json:"roles"`
+
+	// SubjectIsProfileID marks issuers whose subjects are already profile
+	// UUIDs, so existing profiles are linked instead of provisioned
+	SubjectIsProfileID bool `json:"subject_is_profile_id"`
+}
+
+// DefaultClaimMapping reads the standard OpenID Connect claims
+var DefaultClaimMapping = ClaimMapping{
+	Subject:       "sub",
+	Email:         "email",
+	EmailVerified: "email_verified",
+	Nickname:      "nickname",
+	Name:          "name",
+	Picture:       "picture",
+}
+
+// JWTVerifier verifies JWTs with one algorithm, key source and audience
+type JWTVerifier struct {
+	issuer    string
+	algorithm string
+	keys      KeySource
+	audience  string
+	claims    ClaimMapping
+}
+
+// NewJWTVerifier creates a verifier. An empty audience skips the aud check.
+func NewJWTVerifier(issuer, algorithm string, keys KeySource, audience string, claims ClaimMapping) *JWTVerifier {
+	if claims.Subject == "" {
+		claims.Subject = "sub"
+	}
+
+	return &JWTVerifier{
+		issuer:    issuer,
+		algorithm: algorithm,
+		keys:      keys,
+		audience:  audience,
+		claims:    claims,
+	}
+}
+
+// Issuer returns the accepted iss claim
+func (v *JWTVerifier) Issuer() string {
+	return v.issuer
+}
+
+// Verify checks the token and maps its identity claims
+func (v *JWTVerifier) Verify(ctx context.Context, tokenString string) (*Principal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{v.algorithm}),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key, err := v.keys.Key(ctx, kid)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+		return key, nil
+	}, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	if v.audience != "" && !hasAudience(claims, v.audience) {
+		return nil, ErrInvalidAudience
+	}
+
+	identity := models.IdentityClaims{
+		Issuer:   v.issuer,
+		Subject:  claimString(claims, v.claims.Subject),
+		Email:    claimString(claims, v.claims.Email),
+		Nickname: claimString(claims, v.claims.Nickname),
+		Name:     claimString(claims, v.claims.Name),
+		Picture:  claimString(claims, v.claims.Picture),
+	}
+	if identity.Subject == "" {
+		return nil, ErrMissingSubject
+	}
+
+	// Unverified addresses can't be trusted for usernames or support lookups
+	if v.claims.EmailVerified != "" {
+		if verified, ok := claimValue(claims, v.claims.EmailVerified).(bool); ok && !verified {
+			identity.Email = ""
+		}
+	}
+
+	if v.claims.SubjectIsProfileID {
+		identity.ProfileID = identity.Subject
+	}
+
+	return &Principal{Identity: identity, Roles: claimStrings(claims, v.claims.Roles), Claims: claims}, nil
+}
+
+// Registry selects a verifier by the token's iss claim
+type Registry struct {
+	verifiers map[string]TokenVerifier
+}
+
+// NewRegistry creates a registry. Later verifiers for the same issuer replace
+// earlier ones.
+func NewRegistry(verifiers ...TokenVerifier) *Registry {
+	r := &Registry{verifiers: map[string]TokenVerifier{}}
+	for _, v := range verifiers {
+		r.verifiers[v.Issuer()] = v
+	}
+	return r
+}
+
+// Issuers lists the trusted issuers
+func (r *Registry) Issuers() []string {
+	issuers := make([]string, 0, len(r.verifiers))
+	for iss := range r.verifiers {
+		issuers = append(issuers, iss)
+	}
+	sort.Strings(issuers)
+	return issuers
+}
+
+// Verify reads iss from the unverified token and hands it to that issuer's
+// verifier. Nothing from the token is trusted until the verifier accepts it.
+func (r *Registry) Verify(ctx context.Context, tokenString string) (*Principal, error) {
+	var claims jwt.MapClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, &claims); err != nil {
+		return nil, err
+	}
+
+	iss, _ := claims["iss"].(string)
+	v, ok := r.verifiers[iss]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUntrustedIssuer, iss)
+	}
+
+	return v.Verify(ctx, tokenString)
+}
+
+// hasAudience reports whether aud is, or contains, the expected audience
+func hasAudience(claims jwt.MapClaims, expected string) bool {
+	switch v := claims["aud"].(type) {
+	case string:
+		return v == expected
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimValue follows a dotted path through nested claim objects
+func claimValue(claims jwt.MapClaims, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+
+	var cur interface{} = map[string]interface{}(claims)
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = obj[part]
+	}
+	return cur
+}
+
+func claimString(claims jwt.MapClaims, path string) string {
+	s, _ := claimValue(claims, path).(string)
+	return s
+}
+
+// claimStrings reads a claim holding a string or an array of strings
+func claimStrings(claims jwt.MapClaims, path string) []string {
+	switch v := claimValue(claims, path).(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	auth0Issuer    = "https://tenant.auth0.test/"
+	supabaseIssuer = "https://project.supabase.test/auth/v1"
+	supabaseSecret = "supabase-test-secret"
+)
+
+// keyMap is a KeySource backed by fixed keys, standing in for a JWKS cache
+type keyMap map[string]interface{}
+
+func (m keyMap) Key(ctx context.Context, kid string) (interface{}, error) {
+	key, ok := m[kid]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
+	return key, nil
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	return key
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+// auth0Claims returns valid claims with overrides applied; nil removes a claim
+func auth0Claims(overrides jwt.MapClaims) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":            auth0Issuer,
+		"sub":            "auth0|abc123",
+		"aud":            []interface{}{"https://havn-api", auth0Issuer + "userinfo"},
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"email":          "sam@uni.test",
+		"email_verified": true,
+		"nickname":       "sam",
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func supabaseClaims(overrides jwt.MapClaims) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":   supabaseIssuer,
+		"sub":   "6f1c1a52-8f2e-4d7a-9d8e-2b4e5f6a7b8c",
+		"aud":   "authenticated",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"email": "alex@uni.test",
+		"user_metadata": map[string]interface{}{
+			"full_name":  "Alex Doe",
+			"avatar_url": "https://cdn.test/alex.png",
+		},
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func newTestRegistry(t *testing.T, rsaKey *rsa.PrivateKey) *Registry {
+	t.Helper()
+
+	auth0 := NewJWTVerifier(auth0Issuer, "RS256", keyMap{"k1": &rsaKey.PublicKey}, "https://havn-api", DefaultClaimMapping)
+	supabase := NewJWTVerifier(supabaseIssuer, "HS256", StaticKey{Value: []byte(supabaseSecret)}, "authenticated", ClaimMapping{
+		Subject:            "sub",
+		Email:              "email",
+		Name:               "user_metadata.full_name",
+		Picture:            "user_metadata.avatar_url",
+		SubjectIsProfileID: true,
+	})
+	return NewRegistry(auth0, supabase)
+}
+
+func TestRegistrySelectsVerifierByIssuer(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	registry := newTestRegistry(t, rsaKey)
+
+	p, err := registry.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "k1", rsaKey, auth0Claims(nil)))
+	if err != nil {
+		t.Fatalf("Verify(auth0): %v", err)
+	}
+	if p.Identity.Issuer != auth0Issuer || p.Identity.Subject != "auth0|abc123" {
+		t.Errorf("auth0 identity = %+v", p.Identity)
+	}
+	if p.Identity.Email != "sam@uni.test" || p.Identity.Nickname != "sam" {
+		t.Errorf("auth0 claims not mapped: %+v", p.Identity)
+	}
+	if p.Identity.ProfileID != "" {
+		t.Errorf("auth0 ProfileID = %q, want empty", p.Identity.ProfileID)
+	}
+
+	p, err = registry.Verify(context.Background(), sign(t, jwt.SigningMethodHS256, "", []byte(supabaseSecret), supabaseClaims(nil)))
+	if err != nil {
+		t.Fatalf("Verify(supabase): %v", err)
+	}
+	if p.Identity.Issuer != supabaseIssuer || p.Identity.Name != "Alex Doe" || p.Identity.Picture != "https://cdn.test/alex.png" {
+		t.Errorf("supabase identity = %+v", p.Identity)
+	}
+	if p.Identity.ProfileID != p.Identity.Subject {
+		t.Errorf("supabase ProfileID = %q, want the subject", p.Identity.ProfileID)
+	}
+}
+
+func TestRegistryRejectsUntrustedIssuer(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	registry := newTestRegistry(t, rsaKey)
+
+	token := sign(t, jwt.SigningMethodRS256, "k1", rsaKey, auth0Claims(jwt.MapClaims{"iss": "https://evil.test/"}))
+	if _, err := registry.Verify(context.Background(), token); !errors.Is(err, ErrUntrustedIssuer) {
+		t.Fatalf("err = %v, want ErrUntrustedIssuer", err)
+	}
+
+	token = sign(t, jwt.SigningMethodRS256, "k1", rsaKey, auth0Claims(jwt.MapClaims{"iss": nil}))
+	if _, err := registry.Verify(context.Background(), token); !errors.Is(err, ErrUntrustedIssuer) {
+		t.Fatalf("missing iss: err = %v, want ErrUntrustedIssuer", err)
+	}
+}
+
+func TestVerifyRejectsBadTokens(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	otherKey := newRSAKey(t)
+	registry := newTestRegistry(t, rsaKey)
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{
+			name:  "wrong signing key",
+			token: sign(t, jwt.SigningMethodRS256, "k1", otherKey, auth0Claims(nil)),
+			want:  jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:  "unknown kid",
+			token: sign(t, jwt.SigningMethodRS256, "k2", rsaKey, auth0Claims(nil)),
+			want:  jwt.ErrTokenUnverifiable,
+		},
+		{
+			// An HS256 token "signed" with the public key must not pass as RS256
+			name:  "algorithm confusion",
+			token: sign(t, jwt.SigningMethodHS256, "k1", publicDER, auth0Claims(nil)),
+			want:  jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:  "expired",
+			token: sign(t, jwt.SigningMethodRS256, "k1", rsaKey, auth0Claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})),
+			want:  jwt.ErrTokenExpired,
+		},
+		{
+			name:  "no expiry",
+			token: sign(t, jwt.SigningMethodRS256, "k1", rsaKey, auth0Claims(jwt.MapClaims{"exp": nil})),
+			want:  jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name:  "wrong audience",
+			token: sign(t, jwt.SigningMethodRS256, "k1", rsaKey, auth0Claims(jwt.MapClaims{"aud": "https://other-api"})),
+			want:  ErrInvalidAudience,
+		},
+		{
+			name:  "missing subject",
+			token: sign(t, jwt.SigningMethodRS256, "k1", rsaKey, auth0Claims(jwt.MapClaims{"sub": ""})),
+			want:  ErrMissingSubject,
+		},
+		{
+			name:  "wrong supabase secret",
+			token: sign(t, jwt.SigningMethodHS256, "", []byte("other-secret"), supabaseClaims(nil)),
+			want:  jwt.ErrTokenSignatureInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := registry.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyDropsUnverifiedEmail(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	registry := newTestRegistry(t, rsaKey)
+
+	token := sign(t, jwt.SigningMethodRS256, "k1", rsaKey, auth0Claims(jwt.MapClaims{"email_verified": false}))
+	p, err := registry.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if p.Identity.Email != "" {
+		t.Errorf("Email = %q, want it dropped", p.Identity.Email)
+	}
+}
+
+func TestLoadIssuers(t *testing.T) {
+	t.Setenv("AUTH_ISSUERS", "")
+	t.Setenv("AUTH0_DOMAIN", "tenant.auth0.test")
+	t.Setenv("AUTH0_ISSUER", "")
+	t.Setenv("AUTH0_JWKS_URL", "")
+	t.Setenv("AUTH0_AUDIENCE", "")
+	t.Setenv("SUPABASE_JWT_SECRET", supabaseSecret)
+	t.Setenv("SUPABASE_ISSUER", "")
+	t.Setenv("SUPABASE_URL", "https://project.supabase.test/")
+
+	configs, err := LoadIssuers()
+	if err != nil {
+		t.Fatalf("LoadIssuers: %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("got %d issuers, want 2", len(configs))
+	}
+
+	got := fmt.Sprintf("%s %s %s %s", configs[0].Issuer, configs[0].Algorithm, configs[0].JWKSURL, configs[0].Audience)
+	want := "https://tenant.auth0.test/ RS256 https://tenant.auth0.test/.well-known/jwks.json https://havn-api"
+	if got != want {
+		t.Errorf("auth0 config = %q, want %q", got, want)
+	}
+	if configs[1].Issuer != supabaseIssuer || configs[1].Algorithm != "HS256" || configs[1].SecretEnv != "SUPABASE_JWT_SECRET" {
+		t.Errorf("supabase config = %+v", configs[1])
+	}
+
+	t.Setenv("AUTH_ISSUERS", `[{"issuer":"https://idp.test/","algorithm":"HS512","secret_env":"IDP_SECRET","claims":{"subject":"uid"}}]`)
+	configs, err = LoadIssuers()
+	if err != nil {
+		t.Fatalf("LoadIssuers(AUTH_ISSUERS): %v", err)
+	}
+	if len(configs) != 1 || configs[0].Claims == nil || configs[0].Claims.Subject != "uid" {
+		t.Errorf("AUTH_ISSUERS configs = %+v", configs)
+	}
+}
+
+func TestBuildRejectsIncompleteIssuers(t *testing.T) {
+	t.Setenv("MISSING_SECRET", "")
+
+	tests := []IssuerConfig{
+		{Issuer: "https://a.test/", Algorithm: "HS256"},
+		{Issuer: "https://a.test/", Algorithm: "HS256", SecretEnv: "MISSING_SECRET"},
+		{Issuer: "https://a.test/", Algorithm: "RS256"},
+		{Issuer: "https://a.test/", Algorithm: "none"},
+		{Algorithm: "HS256", SecretEnv: "MISSING_SECRET"},
+	}
+
+	for _, cfg := range tests {
+		if _, err := Build(context.Background(), []IssuerConfig{cfg}); err == nil {
+			t.Errorf("Build(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
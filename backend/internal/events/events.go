@@ -0,0 +1,170 @@
+// Package events implements the internal domain event bus. Events are
+// published inside the transaction of the write that caused them and
+// delivered to local subscribers on every API replica.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+// Domain event types
+const (
+	SpotOccupancyChanged   = "spot.occupancy_changed"
+	FriendRequestCreated   = "friend.request_created"
+	FriendRequestResponded = "friend.request_responded"
+	SpotSaveResponded      = "spot_save.responded"
+	PresenceChanged        = "user.presence_changed"
+)
+
+// Aggregate types. Outbox events for the same aggregate are delivered in order.
+const (
+	AggregateSpot            = "spot"
+	AggregateFriendship      = "friendship"
+	AggregateSpotSaveRequest = "spot_save_request"
+	AggregateUser            = "user"
+)
+
+// BusReconnected is dispatched locally after the bus recovers from a lost
+// connection. Events published during the outage may have been missed, so
+// subscribers that keep state should resync.
+const BusReconnected = "bus.reconnected"
+
+// AllEvents subscribes a handler to every event type
+const AllEvents = "*"
+
+// Event is a domain event about a single aggregate, e.g. a spot
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type,omitempty"`
+	AggregateID   string          `json:"aggregate_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+}
+
+// NewEvent creates an event with a fresh id and the payload encoded as JSON
+func NewEvent(eventType, aggregateType, aggregateID string, payload interface{}) (Event, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode %s payload: %w", eventType, err)
+	}
+
+	return Event{
+		ID:            newEventID(),
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       encoded,
+		OccurredAt:    time.Now().UTC(),
+	}, nil
+}
+
+// Decode unmarshals the event payload into v
+func (e Event) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("failed to decode %s payload: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Handler receives events. Handlers run on the bus's delivery goroutine and
+// must not block; hand slow work off to another goroutine.
+type Handler func(ctx context.Context, event Event)
+
+// Execer is satisfied by *pgxpool.Pool and pgx.Tx
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Bus publishes domain events and delivers them to subscribers
+type Bus interface {
+	// Publish sends an event as part of q, which should be the transaction
+	// of the originating write. Nothing is delivered if it rolls back.
+	Publish(ctx context.Context, q Execer, event Event) error
+
+	// Subscribe registers a handler for an event type, or AllEvents. The
+	// returned function unsubscribes it.
+	Subscribe(eventType string, handler Handler) (unsubscribe func())
+
+	// Run delivers events until ctx is cancelled
+	Run(ctx context.Context)
+}
+
+// registry tracks local subscribers and dispatches events to them
+type registry struct {
+	mu       sync.RWMutex
+	handlers map[string]map[*Handler]struct{}
+}
+
+func newRegistry() *registry {
+	return &registry{handlers: map[string]map[*Handler]struct{}{}}
+}
+
+// Subscribe registers a handler for an event type
+func (r *registry) Subscribe(eventType string, handler Handler) func() {
+	h := &handler
+
+	r.mu.Lock()
+	if r.handlers[eventType] == nil {
+		r.handlers[eventType] = map[*Handler]struct{}{}
+	}
+	r.handlers[eventType][h] = struct{}{}
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		delete(r.handlers[eventType], h)
+		r.mu.Unlock()
+	}
+}
+
+// dispatch calls every handler subscribed to the event's type or to all
+// events. A panicking handler is logged and doesn't affect the others.
+func (r *registry) dispatch(ctx context.Context, event Event) {
+	r.mu.RLock()
+	handlers := make([]Handler, 0, len(r.handlers[event.Type])+len(r.handlers[AllEvents]))
+	for h := range r.handlers[event.Type] {
+		handlers = append(handlers, *h)
+	}
+	for h := range r.handlers[AllEvents] {
+		handlers = append(handlers, *h)
+	}
+	r.mu.RUnlock()
+
+	for _, handler := range handlers {
+		r.call(ctx, handler, event)
+	}
+}
+
+func (r *registry) call(ctx context.Context, handler Handler, event Event) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Error().
+				Interface("panic", recovered).
+				Str("event_type", event.Type).
+				Str("event_id", event.ID).
+				Msg("Event handler panicked")
+		}
+	}()
+
+	handler(ctx, event)
+}
+
+// newEventID returns a random version 4 UUID
+func newEventID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate event id: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
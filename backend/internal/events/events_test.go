@@ -0,0 +1,226 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type occupancyPayload struct {
+	SpotID           string `json:"spot_id"`
+	CurrentOccupancy int    `json:"current_occupancy"`
+}
+
+// recorder collects the events delivered to a handler
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) handle(ctx context.Context, event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := []string{}
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func mustEvent(t *testing.T, eventType string, payload interface{}) Event {
+	t.Helper()
+	event, err := NewEvent(eventType, AggregateSpot, "spot-1", payload)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	return event
+}
+
+func TestNewEventRoundTrip(t *testing.T) {
+	event := mustEvent(t, SpotOccupancyChanged, occupancyPayload{SpotID: "spot-1", CurrentOccupancy: 12})
+
+	if event.ID == "" || len(event.ID) != 36 {
+		t.Errorf("ID = %q, want a UUID", event.ID)
+	}
+	if event.OccurredAt.IsZero() {
+		t.Error("OccurredAt not set")
+	}
+	if event.AggregateType != AggregateSpot || event.AggregateID != "spot-1" {
+		t.Errorf("aggregate = %s/%s", event.AggregateType, event.AggregateID)
+	}
+
+	var payload occupancyPayload
+	if err := event.Decode(&payload); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if payload.SpotID != "spot-1" || payload.CurrentOccupancy != 12 {
+		t.Errorf("payload = %+v", payload)
+	}
+
+	other := mustEvent(t, SpotOccupancyChanged, nil)
+	if other.ID == event.ID {
+		t.Error("event ids are not unique")
+	}
+}
+
+func TestMemoryBusDeliversByType(t *testing.T) {
+	bus := NewMemoryBus()
+	ctx := context.Background()
+
+	var occupancy, friends, all recorder
+	bus.Subscribe(SpotOccupancyChanged, occupancy.handle)
+	bus.Subscribe(FriendRequestCreated, friends.handle)
+	bus.Subscribe(AllEvents, all.handle)
+
+	for _, eventType := range []string{SpotOccupancyChanged, FriendRequestCreated, SpotSaveResponded} {
+		if err := bus.Publish(ctx, nil, mustEvent(t, eventType, nil)); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	if got := occupancy.types(); len(got) != 1 || got[0] != SpotOccupancyChanged {
+		t.Errorf("occupancy subscriber got %v", got)
+	}
+	if got := friends.types(); len(got) != 1 || got[0] != FriendRequestCreated {
+		t.Errorf("friend subscriber got %v", got)
+	}
+	if got := all.types(); len(got) != 3 {
+		t.Errorf("wildcard subscriber got %v, want all 3 events", got)
+	}
+}
+
+func TestMemoryBusUnsubscribe(t *testing.T) {
+	bus := NewMemoryBus()
+	ctx := context.Background()
+
+	var first, second recorder
+	unsubscribe := bus.Subscribe(SpotSaveResponded, first.handle)
+	bus.Subscribe(SpotSaveResponded, second.handle)
+
+	bus.Publish(ctx, nil, mustEvent(t, SpotSaveResponded, nil))
+	unsubscribe()
+	unsubscribe() // idempotent
+	bus.Publish(ctx, nil, mustEvent(t, SpotSaveResponded, nil))
+
+	if got := len(first.types()); got != 1 {
+		t.Errorf("unsubscribed handler got %d events, want 1", got)
+	}
+	if got := len(second.types()); got != 2 {
+		t.Errorf("remaining handler got %d events, want 2", got)
+	}
+}
+
+func TestMemoryBusIsolatesPanickingHandler(t *testing.T) {
+	bus := NewMemoryBus()
+
+	var after recorder
+	bus.Subscribe(FriendRequestCreated, func(ctx context.Context, event Event) {
+		panic("boom")
+	})
+	bus.Subscribe(FriendRequestCreated, after.handle)
+
+	if err := bus.Publish(context.Background(), nil, mustEvent(t, FriendRequestCreated, nil)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if got := len(after.types()); got != 1 {
+		t.Errorf("handler after panic got %d events, want 1", got)
+	}
+}
+
+func TestMemoryBusConcurrentPublish(t *testing.T) {
+	bus := NewMemoryBus()
+
+	var rec recorder
+	bus.Subscribe(AllEvents, rec.handle)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bus.Publish(context.Background(), nil, mustEvent(t, SpotOccupancyChanged, nil))
+		}()
+	}
+	wg.Wait()
+
+	if got := len(rec.types()); got != 50 {
+		t.Errorf("got %d events, want 50", got)
+	}
+}
+
+// fakeExecer records the statements a bus publishes with
+type fakeExecer struct {
+	sql  string
+	args []any
+}
+
+func (f *fakeExecer) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	f.sql = sql
+	f.args = args
+	return pgconn.CommandTag{}, nil
+}
+
+func TestPostgresBusPublishNotifiesInTransaction(t *testing.T) {
+	bus := NewPostgresBus(nil)
+	event := mustEvent(t, SpotOccupancyChanged, occupancyPayload{SpotID: "spot-1", CurrentOccupancy: 3})
+
+	var tx fakeExecer
+	if err := bus.Publish(context.Background(), &tx, event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	if !strings.Contains(tx.sql, "pg_notify") {
+		t.Errorf("sql = %q, want pg_notify", tx.sql)
+	}
+	if len(tx.args) != 2 || tx.args[0] != DefaultChannel {
+		t.Fatalf("args = %v", tx.args)
+	}
+
+	var sent Event
+	if err := json.Unmarshal([]byte(tx.args[1].(string)), &sent); err != nil {
+		t.Fatalf("payload is not an event: %v", err)
+	}
+	if sent.ID != event.ID || sent.Type != event.Type {
+		t.Errorf("sent %+v, want %+v", sent, event)
+	}
+}
+
+func TestWriteOutboxRequiresAggregate(t *testing.T) {
+	event := mustEvent(t, FriendRequestCreated, nil)
+
+	var tx fakeExecer
+	if err := WriteOutbox(context.Background(), &tx, event); err != nil {
+		t.Fatalf("WriteOutbox: %v", err)
+	}
+	if !strings.Contains(tx.sql, "outbox_events") || tx.args[0] != event.ID {
+		t.Errorf("sql = %q, args = %v", tx.sql, tx.args)
+	}
+
+	event.AggregateID = ""
+	if err := WriteOutbox(context.Background(), &fakeExecer{}, event); err == nil {
+		t.Error("expected an error for an event without an aggregate")
+	}
+}
+
+func TestPostgresBusRejectsOversizedEvent(t *testing.T) {
+	bus := NewPostgresBus(nil)
+	event := mustEvent(t, SpotSaveResponded, map[string]string{"message": strings.Repeat("x", 8000)})
+
+	var tx fakeExecer
+	if err := bus.Publish(context.Background(), &tx, event); err == nil {
+		t.Fatal("expected an error for a payload over the NOTIFY limit")
+	}
+	if tx.sql != "" {
+		t.Error("oversized event was sent")
+	}
+}
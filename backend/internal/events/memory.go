@@ -0,0 +1,28 @@
+package events
+
+import (
+	"context"
+)
+
+// MemoryBus delivers events to subscribers in the same process. It is for
+// tests and single-replica development; it cannot see transactions, so events
+// are delivered immediately rather than on commit.
+type MemoryBus struct {
+	*registry
+}
+
+// NewMemoryBus creates a new in-memory bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{registry: newRegistry()}
+}
+
+// Publish delivers the event synchronously. q is ignored.
+func (b *MemoryBus) Publish(ctx context.Context, q Execer, event Event) error {
+	b.dispatch(ctx, event)
+	return nil
+}
+
+// Run blocks until ctx is cancelled; delivery happens in Publish
+func (b *MemoryBus) Run(ctx context.Context) {
+	<-ctx.Done()
+}
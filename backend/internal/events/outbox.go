@@ -0,0 +1,23 @@
+package events
+
+import (
+	"context"
+	"fmt"
+)
+
+// WriteOutbox stores the event in the outbox as part of q, which should be
+// the transaction of the originating write. The event must name its aggregate.
+func WriteOutbox(ctx context.Context, q Execer, event Event) error {
+	if event.AggregateType == "" || event.AggregateID == "" {
+		return fmt.Errorf("event %s has no aggregate", event.Type)
+	}
+
+	_, err := q.Exec(ctx, `
+		INSERT INTO outbox_events (event_id, event_type, aggregate_type, aggregate_id, payload, occurred_at)
+		VALUES ($1, $2, $3, $4, $5::jsonb, $6)
+	`, event.ID, event.Type, event.AggregateType, event.AggregateID, string(event.Payload), event.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("failed to write %s to outbox: %w", event.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultChannel is the Postgres NOTIFY channel events are sent on
+const DefaultChannel = "havn_events"
+
+// maxNotifyPayload is Postgres' limit on a NOTIFY payload, less one byte
+const maxNotifyPayload = 7999
+
+// PostgresBus fans events out across replicas with LISTEN/NOTIFY. pg_notify
+// is transactional, so an event is only delivered once its write commits.
+//
+// Each replica holds one pool connection in LISTEN mode, which needs a
+// session-mode connection: it won't work through a transaction pooler.
+// Delivery is at-most-once; events sent while a replica is reconnecting are
+// lost to it, which is signalled to subscribers with BusReconnected.
+type PostgresBus struct {
+	*registry
+	pool       *pgxpool.Pool
+	channel    string
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// NewPostgresBus creates a bus that listens on DefaultChannel
+func NewPostgresBus(pool *pgxpool.Pool) *PostgresBus {
+	return &PostgresBus{
+		registry:   newRegistry(),
+		pool:       pool,
+		channel:    DefaultChannel,
+		minBackoff: time.Second,
+		maxBackoff: 30 * time.Second,
+	}
+}
+
+// Publish queues the event with pg_notify on q. Postgres delivers it to every
+// listening replica, including this one, when q's transaction commits.
+func (b *PostgresBus) Publish(ctx context.Context, q Execer, event Event) error {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if len(encoded) > maxNotifyPayload {
+		return fmt.Errorf("event %s is %d bytes, over the %d byte NOTIFY limit", event.Type, len(encoded), maxNotifyPayload)
+	}
+
+	if _, err := q.Exec(ctx, `SELECT pg_notify($1, $2)`, b.channel, string(encoded)); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", event.Type, err)
+	}
+	return nil
+}
+
+// Run listens for events until ctx is cancelled, reconnecting with
+// exponential backoff whenever the connection is lost
+func (b *PostgresBus) Run(ctx context.Context) {
+	log.Info().Str("channel", b.channel).Msg("Event bus started")
+
+	backoff := b.minBackoff
+	connectedBefore := false
+	for {
+		connected, err := b.listen(ctx, connectedBefore)
+		if ctx.Err() != nil {
+			log.Info().Msg("Event bus stopped")
+			return
+		}
+
+		if connected {
+			connectedBefore = true
+			backoff = b.minBackoff
+		}
+		log.Error().Err(err).Dur("retry_in", backoff).Msg("Event bus connection lost")
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Event bus stopped")
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > b.maxBackoff {
+			backoff = b.maxBackoff
+		}
+	}
+}
+
+// listen holds a dedicated connection in LISTEN mode and dispatches each
+// notification. Reports whether LISTEN succeeded before the returned error.
+func (b *PostgresBus) listen(ctx context.Context, reconnecting bool) (bool, error) {
+	pooled, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	// Take the connection out of the pool so its LISTEN state never leaks
+	// to other queries
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return false, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	if reconnecting {
+		log.Info().Msg("Event bus reconnected")
+		b.dispatch(ctx, Event{ID: newEventID(), Type: BusReconnected, OccurredAt: time.Now().UTC()})
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			log.Error().Err(err).Msg("Failed to decode event notification")
+			continue
+		}
+
+		b.dispatch(ctx, event)
+	}
+}
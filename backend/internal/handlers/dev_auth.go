@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/auth"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// DevAuthHandler serves the built-in development token issuer. Its routes
+// are only registered with ENV=development and DEV_AUTH=true.
+type DevAuthHandler struct {
+	issuer     *auth.DevIssuer
+	identities *services.IdentityService
+}
+
+// NewDevAuthHandler creates a new development auth handler
+func NewDevAuthHandler(issuer *auth.DevIssuer, identities *services.IdentityService) *DevAuthHandler {
+	return &DevAuthHandler{issuer: issuer, identities: identities}
+}
+
+// DevTokenBody is the request body for minting a development token
+type DevTokenBody struct {
+	Username   string   `json:"username" binding:"required"`
+	TTLSeconds int      `json:"ttl_seconds" binding:"omitempty,min=1,max=86400"`
+	Roles      []string `json:"roles"`
+}
+
+// JWKS handles GET /api/v1/dev-auth/.well-known/jwks.json
+func (h *DevAuthHandler) JWKS(c *gin.Context) {
+	set, err := h.issuer.JWKS()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build dev JWKS")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to build key set",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, set)
+}
+
+// ListUsers handles GET /api/v1/dev-auth/users
+func (h *DevAuthHandler) ListUsers(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    h.issuer.Users(),
+	})
+}
+
+// MintToken handles POST /api/v1/dev-auth/token
+func (h *DevAuthHandler) MintToken(c *gin.Context) {
+	var body DevTokenBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	token, expiresAt, err := h.issuer.Mint(body.Username, time.Duration(body.TTLSeconds)*time.Second, body.Roles)
+	if err != nil {
+		if err.Error() == "dev user not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Dev user not found",
+				},
+			})
+			return
+		}
+		if err.Error() == "dev token roles not allowed" {
+			c.JSON(403, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "FORBIDDEN",
+					"message": "Minting roles requires DEV_AUTH_ALLOW_ROLES=true",
+				},
+			})
+			return
+		}
+
+		log.Error().Err(err).Msg("Failed to mint dev token")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to mint token",
+			},
+		})
+		return
+	}
+
+	// Go through the normal verification path so the returned user_id is
+	// exactly what protected routes will see
+	principal, err := h.issuer.Verifier().Verify(c.Request.Context(), token)
+	if err != nil {
+		log.Error().Err(err).Msg("Minted dev token failed verification")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to mint token",
+			},
+		})
+		return
+	}
+
+	userID, err := h.identities.ResolveIdentity(c.Request.Context(), principal.Identity)
+	if err != nil {
+		log.Error().Err(err).Str("username", body.Username).Msg("Failed to provision dev user")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to provision dev user",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"access_token": token,
+			"token_type":   "Bearer",
+			"expires_at":   expiresAt,
+			"user_id":      userID,
+		},
+	})
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// DeviceHandler handles push device registration HTTP requests
+type DeviceHandler struct {
+	service *services.DeviceService
+}
+
+// NewDeviceHandler creates a new device handler
+func NewDeviceHandler(service *services.DeviceService) *DeviceHandler {
+	return &DeviceHandler{service: service}
+}
+
+// GetDevices handles GET /api/v1/users/me/devices
+func (h *DeviceHandler) GetDevices(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	devices, err := h.service.GetDevices(c.Request.Context(), userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to get devices")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retrieve devices",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"devices": devices,
+		},
+	})
+}
+
+// RegisterDeviceBody represents the request body for registering a device
+type RegisterDeviceBody struct {
+	Platform             string `json:"platform" binding:"required"`
+	PushToken            string `json:"push_token" binding:"required,max=512"`
+	AppVersion           string `json:"app_version" binding:"max=50"`
+	NotificationsEnabled *bool  `json:"notifications_enabled"`
+}
+
+// RegisterDevice handles POST /api/v1/users/me/devices
+func (h *DeviceHandler) RegisterDevice(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var req RegisterDeviceBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	device, err := h.service.Register(c.Request.Context(), userID, services.DeviceRegistration{
+		Platform:             req.Platform,
+		PushToken:            req.PushToken,
+		AppVersion:           req.AppVersion,
+		NotificationsEnabled: req.NotificationsEnabled,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to register device")
+
+		if err.Error() == "invalid platform" {
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_PLATFORM",
+					"message": "platform must be one of: ios, android, web",
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to register device",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"device": device,
+		},
+	})
+}
+
+// UnregisterDevice handles DELETE /api/v1/users/me/devices/:id
+func (h *DeviceHandler) UnregisterDevice(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	deviceID := c.Param("id")
+
+	if err := h.service.Unregister(c.Request.Context(), userID, deviceID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("device_id", deviceID).
+			Msg("Failed to unregister device")
+
+		if err.Error() == "device not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to unregister device",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"device_id": deviceID,
+			"status":    "unregistered",
+		},
+	})
+}
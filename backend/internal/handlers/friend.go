@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"strconv"
+
 	"github.com/gin-gonic/gin"
 	"github.com/harrypall/havn-backend/internal/middleware"
 	"github.com/harrypall/havn-backend/internal/services"
@@ -31,7 +33,7 @@ func (h *FriendHandler) GetFriends(c *gin.Context) {
 		return
 	}
 
-	friends, err := h.service.GetFriends(c.Request.Context(), userID)
+	friends, err := h.service.GetFriends(c.Request.Context(), userID, c.Query("group_id"))
 	if err != nil {
 		log.Error().Err(err).Str("user_id", userID).Msg("Failed to get friends")
 		c.JSON(500, gin.H{
@@ -89,6 +91,10 @@ func (h *FriendHandler) SendRequest(c *gin.Context) {
 			Str("friend_id", req.FriendID).
 			Msg("Failed to send friend request")
 
+		if respondRateLimited(c, err) {
+			return
+		}
+
 		if err.Error() == "cannot send friend request to yourself" {
 			c.JSON(400, gin.H{
 				"success": false,
@@ -224,3 +230,368 @@ func (h *FriendHandler) RespondToRequest(c *gin.Context) {
 	})
 }
 
+
+// RemoveFriend handles DELETE /api/v1/friends/:id
+func (h *FriendHandler) RemoveFriend(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	friendID := c.Param("id")
+
+	if err := h.service.RemoveFriend(c.Request.Context(), userID, friendID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("friend_id", friendID).
+			Msg("Failed to remove friend")
+
+		if err.Error() == "friendship not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Friendship not found",
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to remove friend",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"friend_id": friendID,
+			"status":    "removed",
+		},
+	})
+}
+
+// CancelRequest handles DELETE /api/v1/friends/requests/:id
+func (h *FriendHandler) CancelRequest(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	friendshipID := c.Param("id")
+
+	if err := h.service.CancelRequest(c.Request.Context(), friendshipID, userID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("friendship_id", friendshipID).
+			Msg("Failed to cancel friend request")
+
+		if err.Error() == "friend request not found or already responded" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		if err.Error() == "unauthorized: you are not the sender of this request" {
+			c.JSON(403, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "UNAUTHORIZED",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to cancel friend request",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"friendship_id": friendshipID,
+			"status":        "cancelled",
+		},
+	})
+}
+
+// Block handles POST /api/v1/friends/:id/block
+func (h *FriendHandler) Block(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	targetID := c.Param("id")
+
+	if err := h.service.Block(c.Request.Context(), userID, targetID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("target_id", targetID).
+			Msg("Failed to block user")
+
+		if err.Error() == "cannot block yourself" {
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_REQUEST",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		if err.Error() == "user not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "USER_NOT_FOUND",
+					"message": "User not found",
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to block user",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"user_id": targetID,
+			"status":  "blocked",
+		},
+	})
+}
+
+// Unblock handles POST /api/v1/friends/:id/unblock
+func (h *FriendHandler) Unblock(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	targetID := c.Param("id")
+
+	if err := h.service.Unblock(c.Request.Context(), userID, targetID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("target_id", targetID).
+			Msg("Failed to unblock user")
+
+		if err.Error() == "block not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Block not found",
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to unblock user",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"user_id": targetID,
+			"status":  "unblocked",
+		},
+	})
+}
+
+// GetSuggestions handles GET /api/v1/friends/suggestions
+func (h *FriendHandler) GetSuggestions(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 50 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_LIMIT",
+				"message": "limit must be between 1 and 50",
+			},
+		})
+		return
+	}
+
+	suggestions, err := h.service.GetSuggestions(c.Request.Context(), userID, limit)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to get friend suggestions")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retrieve friend suggestions",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"suggestions": suggestions,
+			"count":       len(suggestions),
+		},
+	})
+}
+
+// GetNearbyFriends handles GET /api/v1/friends/nearby
+func (h *FriendHandler) GetNearbyFriends(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	latStr := c.Query("lat")
+	lonStr := c.Query("lon")
+	radiusStr := c.DefaultQuery("radius", "1000")
+
+	if latStr == "" || lonStr == "" {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "MISSING_PARAMETERS",
+				"message": "lat and lon query parameters are required",
+			},
+		})
+		return
+	}
+
+	lat, err := strconv.ParseFloat(latStr, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_LATITUDE",
+				"message": "Latitude must be between -90 and 90",
+			},
+		})
+		return
+	}
+
+	lon, err := strconv.ParseFloat(lonStr, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_LONGITUDE",
+				"message": "Longitude must be between -180 and 180",
+			},
+		})
+		return
+	}
+
+	radius, err := strconv.Atoi(radiusStr)
+	if err != nil || radius < 1 || radius > 10000 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_RADIUS",
+				"message": "Radius must be between 1 and 10000 meters",
+			},
+		})
+		return
+	}
+
+	friends, err := h.service.GetNearbyFriends(c.Request.Context(), userID, lat, lon, radius)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to get nearby friends")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retrieve nearby friends",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"friends": friends,
+			"count":   len(friends),
+		},
+	})
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// FriendGroupHandler handles friend group HTTP requests
+type FriendGroupHandler struct {
+	service *services.FriendGroupService
+}
+
+// NewFriendGroupHandler creates a new friend group handler
+func NewFriendGroupHandler(service *services.FriendGroupService) *FriendGroupHandler {
+	return &FriendGroupHandler{service: service}
+}
+
+// GetGroups handles GET /api/v1/friends/groups
+func (h *FriendGroupHandler) GetGroups(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	groups, err := h.service.GetGroups(c.Request.Context(), userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to get friend groups")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retrieve friend groups",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"groups": groups,
+		},
+	})
+}
+
+// CreateGroupBody represents the request body for creating a friend group
+type CreateGroupBody struct {
+	Name               string `json:"name" binding:"required,max=50"`
+	Visibility         string `json:"visibility"`
+	NotificationsMuted bool   `json:"notifications_muted"`
+}
+
+// CreateGroup handles POST /api/v1/friends/groups
+func (h *FriendGroupHandler) CreateGroup(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var req CreateGroupBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	group, err := h.service.CreateGroup(c.Request.Context(), userID, req.Name, req.Visibility, req.NotificationsMuted)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to create friend group")
+		respondGroupError(c, err, "Failed to create friend group")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"group": group,
+		},
+	})
+}
+
+// UpdateGroup handles PUT /api/v1/friends/groups/:id
+func (h *FriendGroupHandler) UpdateGroup(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var req services.GroupUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	if req.Name != nil && (*req.Name == "" || len(*req.Name) > 50) {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "name must be between 1 and 50 characters",
+			},
+		})
+		return
+	}
+
+	groupID := c.Param("id")
+
+	group, err := h.service.UpdateGroup(c.Request.Context(), userID, groupID, req)
+	if err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("group_id", groupID).
+			Msg("Failed to update friend group")
+		respondGroupError(c, err, "Failed to update friend group")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"group": group,
+		},
+	})
+}
+
+// DeleteGroup handles DELETE /api/v1/friends/groups/:id
+func (h *FriendGroupHandler) DeleteGroup(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	groupID := c.Param("id")
+
+	if err := h.service.DeleteGroup(c.Request.Context(), userID, groupID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("group_id", groupID).
+			Msg("Failed to delete friend group")
+		respondGroupError(c, err, "Failed to delete friend group")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"group_id": groupID,
+			"status":   "deleted",
+		},
+	})
+}
+
+// AddMemberBody represents the request body for adding a friend to a group
+type AddMemberBody struct {
+	FriendID string `json:"friend_id" binding:"required"`
+}
+
+// AddMember handles POST /api/v1/friends/groups/:id/members
+func (h *FriendGroupHandler) AddMember(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var req AddMemberBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	groupID := c.Param("id")
+
+	if err := h.service.AddMember(c.Request.Context(), userID, groupID, req.FriendID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("group_id", groupID).
+			Str("friend_id", req.FriendID).
+			Msg("Failed to add friend group member")
+		respondGroupError(c, err, "Failed to add group member")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"group_id":  groupID,
+			"friend_id": req.FriendID,
+			"status":    "added",
+		},
+	})
+}
+
+// RemoveMember handles DELETE /api/v1/friends/groups/:id/members/:friend_id
+func (h *FriendGroupHandler) RemoveMember(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	groupID := c.Param("id")
+	friendID := c.Param("friend_id")
+
+	if err := h.service.RemoveMember(c.Request.Context(), userID, groupID, friendID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("group_id", groupID).
+			Str("friend_id", friendID).
+			Msg("Failed to remove friend group member")
+		respondGroupError(c, err, "Failed to remove group member")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"group_id":  groupID,
+			"friend_id": friendID,
+			"status":    "removed",
+		},
+	})
+}
+
+// respondGroupError maps friend group service errors to HTTP responses
+func respondGroupError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "group not found", "member not found":
+		c.JSON(404, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": err.Error(),
+			},
+		})
+	case "group name already exists":
+		c.JSON(409, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "GROUP_EXISTS",
+				"message": err.Error(),
+			},
+		})
+	case "invalid visibility":
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_VISIBILITY",
+				"message": "visibility must be one of: default, exact, building, hidden",
+			},
+		})
+	case "user is not your friend":
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "NOT_FRIENDS",
+				"message": err.Error(),
+			},
+		})
+	default:
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": fallback,
+			},
+		})
+	}
+}
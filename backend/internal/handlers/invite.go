@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// InviteHandler handles friend invite HTTP requests
+type InviteHandler struct {
+	service *services.InviteService
+}
+
+// NewInviteHandler creates a new invite handler
+func NewInviteHandler(service *services.InviteService) *InviteHandler {
+	return &InviteHandler{service: service}
+}
+
+// CreateInviteBody represents the request body for creating an invite
+type CreateInviteBody struct {
+	MaxUses          int `json:"max_uses"`           // Defaults to 1 (single use)
+	ExpiresInMinutes int `json:"expires_in_minutes"` // Defaults to 24 hours
+}
+
+// CreateInvite handles POST /api/v1/friends/invites
+func (h *InviteHandler) CreateInvite(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var req CreateInviteBody
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_INPUT",
+					"message": "Invalid request body",
+					"details": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.ExpiresInMinutes == 0 {
+		req.ExpiresInMinutes = 24 * 60
+	}
+
+	if req.MaxUses < 1 || req.MaxUses > 50 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_MAX_USES",
+				"message": "max_uses must be between 1 and 50",
+			},
+		})
+		return
+	}
+
+	if req.ExpiresInMinutes < 5 || req.ExpiresInMinutes > 7*24*60 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_EXPIRY",
+				"message": "expires_in_minutes must be between 5 and 10080 (7 days)",
+			},
+		})
+		return
+	}
+
+	invite, err := h.service.CreateInvite(
+		c.Request.Context(),
+		userID,
+		req.MaxUses,
+		time.Duration(req.ExpiresInMinutes)*time.Minute,
+	)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to create invite")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to create invite",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"invite": invite,
+		},
+	})
+}
+
+// RevokeInvite handles DELETE /api/v1/friends/invites/:id
+func (h *InviteHandler) RevokeInvite(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	inviteID := c.Param("id")
+
+	if err := h.service.RevokeInvite(c.Request.Context(), inviteID, userID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("invite_id", inviteID).
+			Msg("Failed to revoke invite")
+
+		if err.Error() == "invite not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Invite not found",
+				},
+			})
+			return
+		}
+
+		if err.Error() == "unauthorized: you did not create this invite" {
+			c.JSON(403, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "UNAUTHORIZED",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to revoke invite",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"invite_id": inviteID,
+			"status":    "revoked",
+		},
+	})
+}
+
+// RedeemInvite handles POST /api/v1/friends/invites/:token/redeem
+func (h *InviteHandler) RedeemInvite(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	friendship, err := h.service.RedeemInvite(c.Request.Context(), c.Param("token"), userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to redeem invite")
+
+		switch err.Error() {
+		case "invalid invite token", "invite not found":
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_INVITE",
+					"message": "Invite not found or invalid",
+				},
+			})
+			return
+		case "invite has expired", "invite has been revoked", "invite has already been used":
+			c.JSON(410, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVITE_UNAVAILABLE",
+					"message": err.Error(),
+				},
+			})
+			return
+		case "cannot redeem your own invite", "cannot add this user as a friend":
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_REQUEST",
+					"message": err.Error(),
+				},
+			})
+			return
+		case "already friends":
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "FRIENDSHIP_EXISTS",
+					"message": "You are already friends",
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to redeem invite",
+			},
+		})
+		return
+	}
+
+	// An existing request may be reused in either direction, so the friend is
+	// whichever side isn't the caller
+	friendID := friendship.UserID
+	if friendID == userID {
+		friendID = friendship.FriendID
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"friendship_id": friendship.ID,
+			"friend_id":     friendID,
+			"status":        friendship.Status,
+		},
+	})
+}
+
+// GetInviteQRCode handles GET /api/v1/friends/invites/:token/qr
+func (h *InviteHandler) GetInviteQRCode(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	size, err := strconv.Atoi(c.DefaultQuery("size", "512"))
+	if err != nil || size < 128 || size > 1024 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_SIZE",
+				"message": "size must be between 128 and 1024 pixels",
+			},
+		})
+		return
+	}
+
+	png, err := h.service.RenderQRCode(c.Param("token"), userID, size)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to render invite QR code")
+
+		if strings.HasPrefix(err.Error(), "unauthorized") {
+			c.JSON(403, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "UNAUTHORIZED",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		if err.Error() == "invalid invite token" || err.Error() == "invite has expired" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_INVITE",
+					"message": "Invite not found or invalid",
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to render QR code",
+			},
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(200, "image/png", png)
+}
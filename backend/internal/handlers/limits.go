@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/services"
+)
+
+// respondRateLimited writes a 429 response if err is a rate limit error.
+// It returns false if err is some other error and still needs handling.
+func respondRateLimited(c *gin.Context, err error) bool {
+	var limitErr *services.RateLimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(limitErr.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(429, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":    "RATE_LIMITED",
+			"message": limitErr.Reason,
+			"details": gin.H{
+				"action":              limitErr.Action,
+				"retry_after_seconds": retryAfter,
+			},
+		},
+	})
+	return true
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// NotificationHandler handles notification inbox HTTP requests
+type NotificationHandler struct {
+	service *services.NotificationService
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(service *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{service: service}
+}
+
+// GetNotifications handles GET /api/v1/notifications
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	limit := 20
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 100 {
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_INPUT",
+					"message": "limit must be between 1 and 100",
+				},
+			})
+			return
+		}
+	}
+
+	offset := 0
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_INPUT",
+					"message": "offset must be a non-negative integer",
+				},
+			})
+			return
+		}
+	}
+
+	unreadOnly := c.Query("unread") == "true"
+
+	page, err := h.service.List(c.Request.Context(), userID, limit, offset, unreadOnly)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to get notifications")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retrieve notifications",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    page,
+	})
+}
+
+// GetUnreadCount handles GET /api/v1/notifications/unread-count
+func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	count, err := h.service.UnreadCount(c.Request.Context(), userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to count unread notifications")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to count unread notifications",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"unread_count": count,
+		},
+	})
+}
+
+// MarkRead handles POST /api/v1/notifications/:id/read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	notificationID := c.Param("id")
+
+	if err := h.service.MarkRead(c.Request.Context(), userID, notificationID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("notification_id", notificationID).
+			Msg("Failed to mark notification read")
+
+		if err.Error() == "notification not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to mark notification read",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"notification_id": notificationID,
+			"read":            true,
+		},
+	})
+}
+
+// MarkReadBody represents the request body for marking notifications read.
+// An empty or missing IDs list marks every notification read.
+type MarkReadBody struct {
+	IDs []string `json:"ids" binding:"max=100"`
+}
+
+// MarkAllRead handles POST /api/v1/notifications/read
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var req MarkReadBody
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_INPUT",
+					"message": "Invalid request body",
+					"details": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	updated, err := h.service.MarkAllRead(c.Request.Context(), userID, req.IDs)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to mark notifications read")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to mark notifications read",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"updated": updated,
+		},
+	})
+}
+
+// DeleteNotification handles DELETE /api/v1/notifications/:id
+func (h *NotificationHandler) DeleteNotification(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	notificationID := c.Param("id")
+
+	if err := h.service.Delete(c.Request.Context(), userID, notificationID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("notification_id", notificationID).
+			Msg("Failed to delete notification")
+
+		if err.Error() == "notification not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to delete notification",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"notification_id": notificationID,
+			"status":          "deleted",
+		},
+	})
+}
@@ -110,6 +110,17 @@ func (h *OccupancyHandler) CheckIn(c *gin.Context) {
 			return
 		}
 
+		if err.Error() == "spot is held for the waitlist" {
+			c.JSON(409, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "SPOT_HELD",
+					"message": "The free seats at this spot are being held for people on its waitlist",
+				},
+			})
+			return
+		}
+
 		c.JSON(400, gin.H{
 			"success": false,
 			"error": gin.H{
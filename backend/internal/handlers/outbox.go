@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// OutboxHandler handles admin HTTP requests for inspecting the event outbox
+type OutboxHandler struct {
+	relay *services.OutboxRelay
+}
+
+// NewOutboxHandler creates a new outbox handler
+func NewOutboxHandler(relay *services.OutboxRelay) *OutboxHandler {
+	return &OutboxHandler{relay: relay}
+}
+
+// GetStats handles GET /api/v1/admin/outbox/stats
+func (h *OutboxHandler) GetStats(c *gin.Context) {
+	stats, err := h.relay.Stats(c.Request.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get outbox stats")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retrieve outbox stats",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    stats,
+	})
+}
+
+// ListEvents handles GET /api/v1/admin/outbox?status=dead
+func (h *OutboxHandler) ListEvents(c *gin.Context) {
+	status := c.DefaultQuery("status", "dead")
+	if status != "pending" && status != "delivered" && status != "dead" {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "status must be one of: pending, delivered, dead",
+			},
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "limit must be between 1 and 200",
+			},
+		})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "offset must be a non-negative integer",
+			},
+		})
+		return
+	}
+
+	list, err := h.relay.ListEvents(c.Request.Context(), status, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Str("status", status).Msg("Failed to list outbox events")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retrieve outbox events",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"events": list,
+		},
+	})
+}
+
+// GetEvent handles GET /api/v1/admin/outbox/:id
+func (h *OutboxHandler) GetEvent(c *gin.Context) {
+	id, ok := parseOutboxID(c)
+	if !ok {
+		return
+	}
+
+	event, err := h.relay.GetEvent(c.Request.Context(), id)
+	if err != nil {
+		if err.Error() == "outbox event not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		log.Error().Err(err).Int64("outbox_id", id).Msg("Failed to get outbox event")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retrieve outbox event",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"event": event,
+		},
+	})
+}
+
+// RetryEvent handles POST /api/v1/admin/outbox/:id/retry
+func (h *OutboxHandler) RetryEvent(c *gin.Context) {
+	id, ok := parseOutboxID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.relay.RetryDeadLetter(c.Request.Context(), id); err != nil {
+		if err.Error() == "dead-lettered event not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retry outbox event",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"id":     id,
+			"status": "pending",
+		},
+	})
+}
+
+// parseOutboxID reads the :id path parameter, responding 400 if it's invalid
+func parseOutboxID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "id must be a positive integer",
+			},
+		})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/rbac"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// RoleHandler handles role and role audit HTTP requests
+type RoleHandler struct {
+	service *services.RoleService
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(service *services.RoleService) *RoleHandler {
+	return &RoleHandler{service: service}
+}
+
+// GrantRoleBody is the request body for granting a role
+type GrantRoleBody struct {
+	Role   string `json:"role" binding:"required"`
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// GetMyRoles handles GET /api/v1/users/me/roles
+func (h *RoleHandler) GetMyRoles(c *gin.Context) {
+	roles, err := middleware.GetRoles(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load roles")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to load roles",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"roles":       roles,
+			"permissions": rbac.Permissions(roles),
+		},
+	})
+}
+
+// ListUserRoles handles GET /api/v1/admin/users/:id/roles
+func (h *RoleHandler) ListUserRoles(c *gin.Context) {
+	grants, err := h.service.ListGrants(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondRoleError(c, err, "Failed to get user roles")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    grants,
+	})
+}
+
+// GrantRole handles POST /api/v1/admin/users/:id/roles
+func (h *RoleHandler) GrantRole(c *gin.Context) {
+	actorID, actorRoles, ok := roleActor(c)
+	if !ok {
+		return
+	}
+
+	var body GrantRoleBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	grant, err := h.service.GrantRole(c.Request.Context(), actorID, actorRoles, c.Param("id"), rbac.Role(body.Role), body.Reason)
+	if err != nil {
+		respondRoleError(c, err, "Failed to grant role")
+		return
+	}
+
+	c.JSON(201, gin.H{
+		"success": true,
+		"data":    grant,
+	})
+}
+
+// RevokeRole handles DELETE /api/v1/admin/users/:id/roles/:role?reason=
+func (h *RoleHandler) RevokeRole(c *gin.Context) {
+	actorID, actorRoles, ok := roleActor(c)
+	if !ok {
+		return
+	}
+
+	err := h.service.RevokeRole(c.Request.Context(), actorID, actorRoles, c.Param("id"), rbac.Role(c.Param("role")), c.Query("reason"))
+	if err != nil {
+		respondRoleError(c, err, "Failed to revoke role")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": "Role revoked",
+	})
+}
+
+// ListAudit handles GET /api/v1/admin/role-audit?user_id=&limit=&offset=
+func (h *RoleHandler) ListAudit(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "limit must be between 1 and 200",
+			},
+		})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "offset must be a non-negative integer",
+			},
+		})
+		return
+	}
+
+	entries, err := h.service.ListAudit(c.Request.Context(), c.Query("user_id"), limit, offset)
+	if err != nil {
+		respondRoleError(c, err, "Failed to get role audit log")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    entries,
+	})
+}
+
+// roleActor returns the authenticated user and their roles, responding if
+// either is unavailable
+func roleActor(c *gin.Context) (string, []rbac.Role, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return "", nil, false
+	}
+
+	roles, err := middleware.GetRoles(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load roles")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to load roles",
+			},
+		})
+		return "", nil, false
+	}
+
+	return userID, roles, true
+}
+
+// respondRoleError maps role service errors to HTTP responses
+func respondRoleError(c *gin.Context, err error, fallback string) {
+	msg := err.Error()
+
+	var status int
+	var code string
+	switch msg {
+	case "invalid role":
+		status, code = 400, "INVALID_ROLE"
+	case "not allowed to manage this role", "cannot revoke your own super_admin role":
+		status, code = 403, "FORBIDDEN"
+	case "user not found", "role not granted":
+		status, code = 404, "NOT_FOUND"
+	case "role already granted":
+		status, code = 409, "ROLE_EXISTS"
+	default:
+		log.Error().Err(err).Str("user_id", c.Param("id")).Msg(fallback)
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": fallback,
+			},
+		})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":    code,
+			"message": msg,
+		},
+	})
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// ShareHandler handles location share grant HTTP requests
+type ShareHandler struct {
+	service *services.ShareService
+}
+
+// NewShareHandler creates a new share handler
+func NewShareHandler(service *services.ShareService) *ShareHandler {
+	return &ShareHandler{service: service}
+}
+
+// GetShares handles GET /api/v1/users/me/shares
+func (h *ShareHandler) GetShares(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	shares, err := h.service.GetShares(c.Request.Context(), userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to get location shares")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retrieve location shares",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    shares,
+	})
+}
+
+// CreateShareBody represents the request body for sharing location with a friend
+type CreateShareBody struct {
+	FriendID        string `json:"friend_id" binding:"required"`
+	DurationMinutes int    `json:"duration_minutes" binding:"required"`
+}
+
+// CreateShare handles POST /api/v1/users/me/shares
+func (h *ShareHandler) CreateShare(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var req CreateShareBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	if req.DurationMinutes < 5 || req.DurationMinutes > 24*60 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_DURATION",
+				"message": "duration_minutes must be between 5 and 1440 (24 hours)",
+			},
+		})
+		return
+	}
+
+	share, err := h.service.CreateShare(
+		c.Request.Context(),
+		userID,
+		req.FriendID,
+		time.Duration(req.DurationMinutes)*time.Minute,
+	)
+	if err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("friend_id", req.FriendID).
+			Msg("Failed to create location share")
+
+		if err.Error() == "user is not your friend" || err.Error() == "cannot share location with yourself" {
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FRIENDS",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to create location share",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"share": share,
+		},
+	})
+}
+
+// RevokeShare handles DELETE /api/v1/users/me/shares/:id
+func (h *ShareHandler) RevokeShare(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	shareID := c.Param("id")
+
+	if err := h.service.RevokeShare(c.Request.Context(), shareID, userID); err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("share_id", shareID).
+			Msg("Failed to revoke location share")
+
+		if err.Error() == "share not found or already ended" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		if err.Error() == "unauthorized: this share does not involve you" {
+			c.JSON(403, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "UNAUTHORIZED",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to revoke location share",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"share_id": shareID,
+			"status":   "revoked",
+		},
+	})
+}
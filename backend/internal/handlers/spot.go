@@ -136,3 +136,64 @@ func (h *SpotHandler) GetSpotByID(c *gin.Context) {
 	})
 }
 
+
+// SetVerifiedBody is the request body for changing a spot's verification
+type SetVerifiedBody struct {
+	Verified *bool `json:"verified" binding:"required"`
+}
+
+// SetVerified handles PUT /api/v1/admin/spots/:id/verification
+func (h *SpotHandler) SetVerified(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var body SetVerifiedBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	verification, err := h.service.SetVerified(c.Request.Context(), c.Param("id"), userID, *body.Verified)
+	if err != nil {
+		if err.Error() == "spot not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Spot not found",
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to update spot verification",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    verification,
+	})
+}
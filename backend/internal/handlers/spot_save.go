@@ -50,10 +50,12 @@ func (h *SpotSaveHandler) GetRequests(c *gin.Context) {
 	})
 }
 
-// CreateRequestBody represents the request body for creating a spot save request
+// CreateRequestBody represents the request body for creating a spot save request.
+// Exactly one of SaverID or GroupID must be set.
 type CreateRequestBody struct {
 	SpotID  string `json:"spot_id" binding:"required"`
-	SaverID string `json:"saver_id" binding:"required"`
+	SaverID string `json:"saver_id"`
+	GroupID string `json:"group_id"`
 	Message string `json:"message"`
 }
 
@@ -84,6 +86,22 @@ func (h *SpotSaveHandler) CreateRequest(c *gin.Context) {
 		return
 	}
 
+	if (req.SaverID == "") == (req.GroupID == "") {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Exactly one of saver_id or group_id is required",
+			},
+		})
+		return
+	}
+
+	if req.GroupID != "" {
+		h.createGroupRequest(c, userID, req)
+		return
+	}
+
 	request, err := h.service.CreateRequest(
 		c.Request.Context(),
 		userID,
@@ -99,6 +117,10 @@ func (h *SpotSaveHandler) CreateRequest(c *gin.Context) {
 			Str("spot_id", req.SpotID).
 			Msg("Failed to create spot save request")
 
+		if respondRateLimited(c, err) {
+			return
+		}
+
 		// Handle specific error cases
 		if err.Error() == "saver is not your friend" {
 			c.JSON(400, gin.H{
@@ -142,6 +164,61 @@ func (h *SpotSaveHandler) CreateRequest(c *gin.Context) {
 	})
 }
 
+// createGroupRequest sends a spot save request to every member of a friend group
+func (h *SpotSaveHandler) createGroupRequest(c *gin.Context, userID string, req CreateRequestBody) {
+	result, err := h.service.CreateGroupRequest(
+		c.Request.Context(),
+		userID,
+		req.GroupID,
+		req.SpotID,
+		req.Message,
+	)
+
+	if err != nil {
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Str("group_id", req.GroupID).
+			Str("spot_id", req.SpotID).
+			Msg("Failed to create group spot save request")
+
+		if err.Error() == "group not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "GROUP_NOT_FOUND",
+					"message": "Group not found",
+				},
+			})
+			return
+		}
+
+		if err.Error() == "spot not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "SPOT_NOT_FOUND",
+					"message": "Spot not found",
+				},
+			})
+			return
+		}
+
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "REQUEST_FAILED",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
 // RespondBody represents the request body for responding to a spot save request
 type RespondBody struct {
 	RequestID string `json:"request_id" binding:"required"`
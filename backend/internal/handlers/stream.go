@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// streamHeartbeatInterval keeps idle connections open through proxies and
+// lets clients detect a dead stream
+const streamHeartbeatInterval = 15 * time.Second
+
+// streamRetryMillis is the reconnect delay suggested to EventSource clients
+const streamRetryMillis = 3000
+
+// maxStreamSpotIDs caps the spot_ids list of a single subscription
+const maxStreamSpotIDs = 100
+
+// maxStreamBBoxDegrees caps each side of a subscribed bounding box
+const maxStreamBBoxDegrees = 1.0
+
+// StreamHandler handles realtime Server-Sent Events streams
+type StreamHandler struct {
+	feed     *services.OccupancyFeed
+	presence *services.PresenceFeed
+}
+
+// NewStreamHandler creates a new stream handler
+func NewStreamHandler(feed *services.OccupancyFeed, presence *services.PresenceFeed) *StreamHandler {
+	return &StreamHandler{feed: feed, presence: presence}
+}
+
+// StreamOccupancy handles GET /api/v1/spots/stream
+//
+// Subscribes to occupancy changes for either ?spot_ids=a,b,c or a bounding
+// box given by ?min_lat=&min_lon=&max_lat=&max_lon=. Each change is sent as an
+// "occupancy" event whose id can be passed back as the Last-Event-ID header (or
+// ?last_event_id=) to resume after a disconnect. A "reset" event means the
+// missed history can't be replayed and the client should refetch GET /spots.
+func (h *StreamHandler) StreamOccupancy(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	filter, err := parseOccupancyFilter(c)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	var resumeFrom int64
+	if lastEventID != "" {
+		resumeFrom, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || resumeFrom < 0 {
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_INPUT",
+					"message": "Last-Event-ID must be a non-negative integer",
+				},
+			})
+			return
+		}
+	}
+
+	// Subscribe before replaying so nothing committed in between is lost
+	sub := h.feed.Subscribe(filter)
+	defer h.feed.Unsubscribe(sub)
+
+	ctx := c.Request.Context()
+
+	var replay []services.OccupancyEvent
+	complete := true
+	if lastEventID != "" {
+		replay, complete, err = h.feed.Replay(ctx, resumeFrom, filter)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", userID).Msg("Failed to replay occupancy events")
+			c.JSON(500, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "SERVER_ERROR",
+					"message": "Failed to resume stream",
+				},
+			})
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
+
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetryMillis)
+
+	if !complete {
+		writeSSE(c, "", "reset", gin.H{"reason": "history_expired"})
+	}
+
+	sent := map[int64]bool{}
+	for _, event := range replay {
+		writeSSE(c, strconv.FormatInt(event.ID, 10), "occupancy", event)
+		sent[event.ID] = true
+	}
+	c.Writer.Flush()
+
+	log.Info().
+		Str("user_id", userID).
+		Int("replayed", len(replay)).
+		Msg("Occupancy stream opened")
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Str("user_id", userID).Msg("Occupancy stream closed")
+			return
+
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and replays
+				return
+			}
+			if sent[event.ID] {
+				continue
+			}
+			writeSSE(c, strconv.FormatInt(event.ID, 10), "occupancy", event)
+			c.Writer.Flush()
+
+		case now := <-heartbeat.C:
+			writeSSE(c, "", "heartbeat", gin.H{"time": now.UTC().Format(time.RFC3339)})
+			c.Writer.Flush()
+		}
+	}
+}
+
+// StreamPresence handles GET /api/v1/friends/stream
+//
+// Starts with a "snapshot" event listing friends as GET /friends would, then
+// sends a "presence" event whenever a friend checks in, checks out, moves or
+// becomes visible, "location_hidden" when a friend stops sharing with the
+// user, and "friend_removed" when a friendship ends. Presence is state rather
+// than history, so there is no resume: a reconnecting client gets a new snapshot.
+func (h *StreamHandler) StreamPresence(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	// Subscribe before the snapshot so nothing committed in between is lost
+	sub := h.presence.Subscribe(userID)
+	defer h.presence.Unsubscribe(sub)
+
+	ctx := c.Request.Context()
+
+	friends, err := h.presence.Snapshot(ctx, sub)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to load presence snapshot")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to open presence stream",
+			},
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
+
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetryMillis)
+	writeSSE(c, "", "snapshot", gin.H{"friends": friends})
+	c.Writer.Flush()
+
+	log.Info().
+		Str("user_id", userID).
+		Int("friends", len(friends)).
+		Msg("Presence stream opened")
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Str("user_id", userID).Msg("Presence stream closed")
+			return
+
+		case update, ok := <-sub.Updates:
+			if !ok {
+				// Dropped for falling behind; the client reconnects for a new snapshot
+				return
+			}
+			writeSSE(c, "", update.Type, update)
+			c.Writer.Flush()
+
+		case now := <-heartbeat.C:
+			writeSSE(c, "", "heartbeat", gin.H{"time": now.UTC().Format(time.RFC3339)})
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeSSE writes a single Server-Sent Event
+func writeSSE(c *gin.Context, id, event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Error().Err(err).Str("event", event).Msg("Failed to encode stream event")
+		return
+	}
+
+	if id != "" {
+		fmt.Fprintf(c.Writer, "id: %s\n", id)
+	}
+	fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, payload)
+}
+
+// parseOccupancyFilter reads either spot_ids or a bounding box from the query
+func parseOccupancyFilter(c *gin.Context) (services.OccupancyFilter, error) {
+	filter := services.OccupancyFilter{}
+
+	if raw := c.Query("spot_ids"); raw != "" {
+		ids := strings.Split(raw, ",")
+		if len(ids) > maxStreamSpotIDs {
+			return filter, fmt.Errorf("at most %d spot_ids may be subscribed", maxStreamSpotIDs)
+		}
+
+		filter.SpotIDs = map[string]bool{}
+		for _, id := range ids {
+			if id = strings.TrimSpace(id); id != "" {
+				filter.SpotIDs[id] = true
+			}
+		}
+	}
+
+	bboxParams := []string{"min_lat", "min_lon", "max_lat", "max_lon"}
+	values := make([]float64, len(bboxParams))
+	given := 0
+	for i, name := range bboxParams {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be a number", name)
+		}
+		values[i] = value
+		given++
+	}
+
+	if given > 0 {
+		if given < len(bboxParams) {
+			return filter, fmt.Errorf("min_lat, min_lon, max_lat and max_lon must be given together")
+		}
+
+		box := services.BoundingBox{MinLat: values[0], MinLon: values[1], MaxLat: values[2], MaxLon: values[3]}
+		if box.MinLat < -90 || box.MaxLat > 90 || box.MinLon < -180 || box.MaxLon > 180 {
+			return filter, fmt.Errorf("bounding box is out of range")
+		}
+		if box.MinLat > box.MaxLat || box.MinLon > box.MaxLon {
+			return filter, fmt.Errorf("bounding box minimums must not exceed maximums")
+		}
+		if box.MaxLat-box.MinLat > maxStreamBBoxDegrees || box.MaxLon-box.MinLon > maxStreamBBoxDegrees {
+			return filter, fmt.Errorf("bounding box may span at most %.0f degree per side", maxStreamBBoxDegrees)
+		}
+		filter.BBox = &box
+	}
+
+	if len(filter.SpotIDs) == 0 && filter.BBox == nil {
+		return filter, fmt.Errorf("either spot_ids or a bounding box is required")
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// SyncHandler handles delta sync HTTP requests from offline-first clients
+type SyncHandler struct {
+	service *services.SyncService
+}
+
+// NewSyncHandler creates a new sync handler
+func NewSyncHandler(service *services.SyncService) *SyncHandler {
+	return &SyncHandler{service: service}
+}
+
+// Sync handles GET /api/v1/sync?since=<token>
+func (h *SyncHandler) Sync(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	response, err := h.service.Sync(c.Request.Context(), userID, c.Query("since"))
+	if err != nil {
+		if err.Error() == "invalid sync token" {
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_TOKEN",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to sync")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to sync",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
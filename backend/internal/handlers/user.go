@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/harrypall/havn-backend/internal/middleware"
 	"github.com/harrypall/havn-backend/internal/services"
@@ -156,13 +158,13 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 	// Validate allowed fields
 	allowedFields := map[string]bool{
-		"full_name":        true,
-		"avatar_url":       true,
-		"bio":              true,
-		"major":            true,
-		"graduation_year":  true,
-		"location_sharing": true,
-		"push_token":       true,
+		"full_name":              true,
+		"avatar_url":             true,
+		"bio":                    true,
+		"major":                  true,
+		"graduation_year":        true,
+		"location_sharing":       true,
+		"colocation_suggestions": true,
 	}
 
 	for key := range updates {
@@ -198,3 +200,109 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	})
 }
 
+// GetPreferences handles GET /api/v1/users/me/preferences
+func (h *UserHandler) GetPreferences(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	prefs, err := h.service.GetPreferences(c.Request.Context(), userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to get preferences")
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to retrieve preferences",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"preferences": prefs,
+		},
+	})
+}
+
+// UpdatePreferences handles PUT /api/v1/users/me/preferences. The body is a
+// JSON merge patch (RFC 7386) applied to the current preferences.
+func (h *UserHandler) UpdatePreferences(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var patch map[string]interface{}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	prefs, err := h.service.UpdatePreferences(c.Request.Context(), userID, patch)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to update preferences")
+
+		if strings.HasPrefix(err.Error(), "invalid preferences") {
+			c.JSON(400, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_PREFERENCES",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		if err.Error() == "profile not found" {
+			c.JSON(404, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "PROFILE_NOT_FOUND",
+					"message": "Profile not found",
+				},
+			})
+			return
+		}
+
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to update preferences",
+			},
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"preferences": prefs,
+		},
+	})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// WaitlistHandler handles spot waitlist HTTP requests
+type WaitlistHandler struct {
+	service *services.WaitlistService
+}
+
+// NewWaitlistHandler creates a new waitlist handler
+func NewWaitlistHandler(service *services.WaitlistService) *WaitlistHandler {
+	return &WaitlistHandler{service: service}
+}
+
+// GetStatus handles GET /api/v1/spots/:id/waitlist
+func (h *WaitlistHandler) GetStatus(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	status, err := h.service.GetStatus(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		respondWaitlistError(c, err, "Failed to retrieve waitlist status")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// Join handles POST /api/v1/spots/:id/waitlist
+func (h *WaitlistHandler) Join(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	status, err := h.service.Join(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		respondWaitlistError(c, err, "Failed to join waitlist")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// Leave handles DELETE /api/v1/spots/:id/waitlist
+func (h *WaitlistHandler) Leave(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	spotID := c.Param("id")
+
+	if err := h.service.Leave(c.Request.Context(), userID, spotID); err != nil {
+		respondWaitlistError(c, err, "Failed to leave waitlist")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"spot_id": spotID,
+			"status":  "left",
+		},
+	})
+}
+
+// respondWaitlistError maps waitlist service errors to HTTP responses
+func respondWaitlistError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "spot not found":
+		c.JSON(404, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SPOT_NOT_FOUND",
+				"message": "Spot not found",
+			},
+		})
+	case "not on waitlist":
+		c.JSON(404, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "NOT_ON_WAITLIST",
+				"message": "You are not on this spot's waitlist",
+			},
+		})
+	case "spot is not full":
+		c.JSON(409, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SPOT_NOT_FULL",
+				"message": "Waitlists are only open while a spot is at high occupancy",
+			},
+		})
+	case "already checked in at this spot":
+		c.JSON(409, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "ALREADY_CHECKED_IN",
+				"message": err.Error(),
+			},
+		})
+	default:
+		log.Error().Err(err).Str("spot_id", c.Param("id")).Msg(fallback)
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": fallback,
+			},
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// WatchHandler handles spot availability watch HTTP requests
+type WatchHandler struct {
+	service *services.WatchService
+}
+
+// NewWatchHandler creates a new watch handler
+func NewWatchHandler(service *services.WatchService) *WatchHandler {
+	return &WatchHandler{service: service}
+}
+
+// defaultWatchMinutes is how long a watch lasts when no expiry is given
+const defaultWatchMinutes = 120
+
+// GetWatch handles GET /api/v1/spots/:id/watch
+func (h *WatchHandler) GetWatch(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	watch, err := h.service.GetWatch(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		respondWatchError(c, err, "Failed to retrieve watch")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"watch": watch,
+		},
+	})
+}
+
+// CreateWatchBody represents the request body for watching a spot
+type CreateWatchBody struct {
+	ThresholdPercent int `json:"threshold_percent" binding:"required,min=1,max=100"`
+	ExpiresInMinutes int `json:"expires_in_minutes" binding:"omitempty,min=15,max=1440"`
+}
+
+// CreateWatch handles POST /api/v1/spots/:id/watch
+func (h *WatchHandler) CreateWatch(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var req CreateWatchBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	minutes := req.ExpiresInMinutes
+	if minutes == 0 {
+		minutes = defaultWatchMinutes
+	}
+
+	watch, err := h.service.CreateWatch(
+		c.Request.Context(),
+		userID,
+		c.Param("id"),
+		req.ThresholdPercent,
+		time.Duration(minutes)*time.Minute,
+	)
+	if err != nil {
+		respondWatchError(c, err, "Failed to create watch")
+		return
+	}
+
+	c.JSON(201, gin.H{
+		"success": true,
+		"data": gin.H{
+			"watch": watch,
+		},
+	})
+}
+
+// UpdateWatchBody represents the request body for changing a watch.
+// Omitted fields are left unchanged.
+type UpdateWatchBody struct {
+	ThresholdPercent *int `json:"threshold_percent" binding:"omitempty,min=1,max=100"`
+	ExpiresInMinutes *int `json:"expires_in_minutes" binding:"omitempty,min=15,max=1440"`
+}
+
+// UpdateWatch handles PUT /api/v1/spots/:id/watch
+func (h *WatchHandler) UpdateWatch(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var req UpdateWatchBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	var ttl *time.Duration
+	if req.ExpiresInMinutes != nil {
+		d := time.Duration(*req.ExpiresInMinutes) * time.Minute
+		ttl = &d
+	}
+
+	watch, err := h.service.UpdateWatch(c.Request.Context(), userID, c.Param("id"), req.ThresholdPercent, ttl)
+	if err != nil {
+		respondWatchError(c, err, "Failed to update watch")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"watch": watch,
+		},
+	})
+}
+
+// DeleteWatch handles DELETE /api/v1/spots/:id/watch
+func (h *WatchHandler) DeleteWatch(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	spotID := c.Param("id")
+
+	if err := h.service.DeleteWatch(c.Request.Context(), userID, spotID); err != nil {
+		respondWatchError(c, err, "Failed to delete watch")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"spot_id": spotID,
+			"status":  "deleted",
+		},
+	})
+}
+
+// respondWatchError maps watch service errors to HTTP responses
+func respondWatchError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "watch not found", "spot not found":
+		c.JSON(404, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": err.Error(),
+			},
+		})
+	default:
+		log.Error().Err(err).Str("spot_id", c.Param("id")).Msg(fallback)
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": fallback,
+			},
+		})
+	}
+}
@@ -0,0 +1,498 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/middleware"
+	"github.com/harrypall/havn-backend/internal/rbac"
+	"github.com/harrypall/havn-backend/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+// WebhookHandler handles webhook subscription HTTP requests from admins and
+// partner accounts
+type WebhookHandler struct {
+	service *services.WebhookService
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(service *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+// webhookActor returns the authenticated user, responding 401 if there is none
+func webhookActor(c *gin.Context) (services.WebhookActor, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return services.WebhookActor{}, false
+	}
+
+	// Only stored grants count: deliveries are enqueued in the background,
+	// where token claims aren't available, so an admin whose role only comes
+	// from their token must be held to partner scopes like everyone else
+	admin := middleware.HasGrantedPermission(c, rbac.PermPartnersManage)
+	return services.WebhookActor{UserID: userID, Admin: admin}, true
+}
+
+// ListSubscriptions handles GET /api/v1/webhooks
+func (h *WebhookHandler) ListSubscriptions(c *gin.Context) {
+	actor, ok := webhookActor(c)
+	if !ok {
+		return
+	}
+
+	subs, err := h.service.ListSubscriptions(c.Request.Context(), actor)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to retrieve webhooks")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"webhooks": subs,
+		},
+	})
+}
+
+// CreateWebhookBody represents the request body for creating a webhook
+// subscription. owner_id is only honoured for admins.
+type CreateWebhookBody struct {
+	OwnerID       string   `json:"owner_id" binding:"omitempty,uuid"`
+	URL           string   `json:"url" binding:"required,max=2048"`
+	Description   *string  `json:"description" binding:"omitempty,max=200"`
+	EventTypes    []string `json:"event_types" binding:"required,min=1,max=10"`
+	SpotIDs       []string `json:"spot_ids" binding:"omitempty,max=100,dive,uuid"`
+	BuildingNames []string `json:"building_names" binding:"omitempty,max=50,dive,max=200"`
+}
+
+// CreateSubscription handles POST /api/v1/webhooks
+func (h *WebhookHandler) CreateSubscription(c *gin.Context) {
+	actor, ok := webhookActor(c)
+	if !ok {
+		return
+	}
+
+	var req CreateWebhookBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	sub, secret, err := h.service.CreateSubscription(c.Request.Context(), actor, services.WebhookSubscriptionInput{
+		OwnerID:       req.OwnerID,
+		URL:           req.URL,
+		Description:   req.Description,
+		EventTypes:    req.EventTypes,
+		SpotIDs:       req.SpotIDs,
+		BuildingNames: req.BuildingNames,
+	})
+	if err != nil {
+		respondWebhookError(c, err, "Failed to create webhook")
+		return
+	}
+
+	c.JSON(201, gin.H{
+		"success": true,
+		"data": gin.H{
+			"webhook": sub,
+			// Shown only here and on rotation
+			"secret": secret,
+		},
+	})
+}
+
+// GetSubscription handles GET /api/v1/webhooks/:id
+func (h *WebhookHandler) GetSubscription(c *gin.Context) {
+	actor, ok := webhookActor(c)
+	if !ok {
+		return
+	}
+	if !validParam(c, "id", "webhook not found") {
+		return
+	}
+
+	sub, err := h.service.GetSubscription(c.Request.Context(), actor, c.Param("id"))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to retrieve webhook")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"webhook": sub,
+		},
+	})
+}
+
+// UpdateWebhookBody represents the request body for changing a webhook
+// subscription. Omitted fields are left unchanged.
+type UpdateWebhookBody struct {
+	URL           *string   `json:"url" binding:"omitempty,max=2048"`
+	Description   *string   `json:"description" binding:"omitempty,max=200"`
+	EventTypes    *[]string `json:"event_types" binding:"omitempty,max=10"`
+	SpotIDs       *[]string `json:"spot_ids" binding:"omitempty,max=100,dive,uuid"`
+	BuildingNames *[]string `json:"building_names" binding:"omitempty,max=50,dive,max=200"`
+	Active        *bool     `json:"active"`
+}
+
+// UpdateSubscription handles PUT /api/v1/webhooks/:id
+func (h *WebhookHandler) UpdateSubscription(c *gin.Context) {
+	actor, ok := webhookActor(c)
+	if !ok {
+		return
+	}
+	if !validParam(c, "id", "webhook not found") {
+		return
+	}
+
+	var req UpdateWebhookBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	sub, err := h.service.UpdateSubscription(c.Request.Context(), actor, c.Param("id"), services.WebhookSubscriptionPatch{
+		URL:           req.URL,
+		Description:   req.Description,
+		EventTypes:    req.EventTypes,
+		SpotIDs:       req.SpotIDs,
+		BuildingNames: req.BuildingNames,
+		Active:        req.Active,
+	})
+	if err != nil {
+		respondWebhookError(c, err, "Failed to update webhook")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"webhook": sub,
+		},
+	})
+}
+
+// DeleteSubscription handles DELETE /api/v1/webhooks/:id
+func (h *WebhookHandler) DeleteSubscription(c *gin.Context) {
+	actor, ok := webhookActor(c)
+	if !ok {
+		return
+	}
+	if !validParam(c, "id", "webhook not found") {
+		return
+	}
+
+	id := c.Param("id")
+
+	if err := h.service.DeleteSubscription(c.Request.Context(), actor, id); err != nil {
+		respondWebhookError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"id":     id,
+			"status": "deleted",
+		},
+	})
+}
+
+// RotateSecret handles POST /api/v1/webhooks/:id/rotate-secret
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	actor, ok := webhookActor(c)
+	if !ok {
+		return
+	}
+	if !validParam(c, "id", "webhook not found") {
+		return
+	}
+
+	id := c.Param("id")
+
+	secret, err := h.service.RotateSecret(c.Request.Context(), actor, id)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to rotate webhook secret")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"id":     id,
+			"secret": secret,
+		},
+	})
+}
+
+// ListDeliveries handles GET /api/v1/webhooks/:id/deliveries?status=failed
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	actor, ok := webhookActor(c)
+	if !ok {
+		return
+	}
+	if !validParam(c, "id", "webhook not found") {
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && status != "pending" && status != "succeeded" && status != "failed" {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "status must be one of: pending, succeeded, failed",
+			},
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "limit must be between 1 and 200",
+			},
+		})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "offset must be a non-negative integer",
+			},
+		})
+		return
+	}
+
+	deliveries, err := h.service.ListDeliveries(c.Request.Context(), actor, c.Param("id"), status, limit, offset)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to retrieve webhook deliveries")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"deliveries": deliveries,
+			"limit":      limit,
+			"offset":     offset,
+		},
+	})
+}
+
+// ReplayDelivery handles POST /api/v1/webhooks/:id/deliveries/:delivery_id/replay
+func (h *WebhookHandler) ReplayDelivery(c *gin.Context) {
+	actor, ok := webhookActor(c)
+	if !ok {
+		return
+	}
+	if !validParam(c, "id", "webhook not found") {
+		return
+	}
+	if !validParam(c, "delivery_id", "delivery not found") {
+		return
+	}
+
+	delivery, err := h.service.ReplayDelivery(c.Request.Context(), actor, c.Param("id"), c.Param("delivery_id"))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to replay webhook delivery")
+		return
+	}
+
+	c.JSON(202, gin.H{
+		"success": true,
+		"data": gin.H{
+			"delivery": delivery,
+		},
+	})
+}
+
+// ListPartnerScopes handles GET /api/v1/admin/partner-scopes?user_id=
+func (h *WebhookHandler) ListPartnerScopes(c *gin.Context) {
+	scopes, err := h.service.ListPartnerScopes(c.Request.Context(), c.Query("user_id"))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to retrieve partner scopes")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"scopes": scopes,
+		},
+	})
+}
+
+// GrantPartnerScopeBody represents the request body for granting a partner
+// access to exactly one spot or building
+type GrantPartnerScopeBody struct {
+	UserID       string  `json:"user_id" binding:"required,uuid"`
+	SpotID       *string `json:"spot_id" binding:"omitempty,uuid"`
+	BuildingName *string `json:"building_name" binding:"omitempty,min=1,max=200"`
+}
+
+// GrantPartnerScope handles POST /api/v1/admin/partner-scopes
+func (h *WebhookHandler) GrantPartnerScope(c *gin.Context) {
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var req GrantPartnerScopeBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_INPUT",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	scope, err := h.service.GrantPartnerScope(c.Request.Context(), adminID, req.UserID, req.SpotID, req.BuildingName)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to grant partner scope")
+		return
+	}
+
+	c.JSON(201, gin.H{
+		"success": true,
+		"data": gin.H{
+			"scope": scope,
+		},
+	})
+}
+
+// RevokePartnerScope handles DELETE /api/v1/admin/partner-scopes/:id
+func (h *WebhookHandler) RevokePartnerScope(c *gin.Context) {
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User not authenticated",
+			},
+		})
+		return
+	}
+
+	if !validParam(c, "id", "partner scope not found") {
+		return
+	}
+	id := c.Param("id")
+
+	if err := h.service.RevokePartnerScope(c.Request.Context(), adminID, id); err != nil {
+		respondWebhookError(c, err, "Failed to revoke partner scope")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": gin.H{
+			"id":     id,
+			"status": "revoked",
+		},
+	})
+}
+
+// validParam responds 404 with message unless the named path parameter is a
+// UUID, so malformed ids don't reach the database
+func validParam(c *gin.Context, name, message string) bool {
+	if services.IsUUID(c.Param(name)) {
+		return true
+	}
+	c.JSON(404, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":    "NOT_FOUND",
+			"message": message,
+		},
+	})
+	return false
+}
+
+// respondWebhookError maps webhook service errors to HTTP responses
+func respondWebhookError(c *gin.Context, err error, fallback string) {
+	msg := err.Error()
+
+	var status int
+	var code string
+	switch {
+	case msg == "partner access required", msg == "only admins can create webhooks for other users":
+		status, code = 403, "FORBIDDEN"
+	case msg == "spot or building outside partner scope":
+		status, code = 403, "OUT_OF_SCOPE"
+	case msg == "webhook not found", msg == "delivery not found", msg == "spot not found",
+		msg == "partner scope not found", msg == "user or spot not found":
+		status, code = 404, "NOT_FOUND"
+	case msg == "partner scope already exists":
+		status, code = 409, "SCOPE_EXISTS"
+	case strings.HasPrefix(msg, "invalid url: "):
+		status, code = 400, "INVALID_URL"
+	case strings.HasPrefix(msg, "unsupported event type: "):
+		status, code = 400, "INVALID_EVENT_TYPE"
+	case msg == "at least one event type is required", msg == "at least one spot or building is required",
+		msg == "exactly one of spot_id or building_name is required":
+		status, code = 400, "INVALID_INPUT"
+	default:
+		log.Error().Err(err).Str("webhook_id", c.Param("id")).Msg(fallback)
+		c.JSON(500, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": fallback,
+			},
+		})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":    code,
+			"message": msg,
+		},
+	})
+}
@@ -0,0 +1,206 @@
+// Package jwks keeps a refreshing cache of a JSON Web Key Set, so tokens
+// signed with rotated keys keep verifying without a restart.
+package jwks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/rs/zerolog/log"
+)
+
+// maxJWKSSize caps the size of a fetched key set
+const maxJWKSSize = 1 << 20
+
+// ErrKeyNotFound is returned when no key with the requested id is known, even
+// after refetching the key set
+var ErrKeyNotFound = errors.New("key not found")
+
+// Options configures a Cache. Zero values take the defaults.
+type Options struct {
+	// RefreshInterval is how often the key set is refetched in the background
+	// (default 1h)
+	RefreshInterval time.Duration
+
+	// MinRefetchInterval rate-limits refetches triggered by unknown key ids,
+	// so a flood of forged tokens can't hammer the issuer (default 1m)
+	MinRefetchInterval time.Duration
+
+	// HTTPClient fetches the key set (default: 10s timeout)
+	HTTPClient *http.Client
+}
+
+// Cache holds the keys published at a JWKS URL. If the endpoint is down the
+// last fetched keys keep being used.
+type Cache struct {
+	url                string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefetchInterval time.Duration
+
+	// fetchMu makes concurrent refetches for unknown keys wait for one fetch
+	fetchMu sync.Mutex
+
+	mu          sync.RWMutex
+	set         jwk.Set
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// New creates a cache for the key set at url. Keys are fetched lazily; call
+// Refresh to fetch them up front and Run to keep them fresh.
+func New(url string, opts Options) *Cache {
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = time.Hour
+	}
+	if opts.MinRefetchInterval <= 0 {
+		opts.MinRefetchInterval = time.Minute
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Cache{
+		url:                url,
+		client:             opts.HTTPClient,
+		refreshInterval:    opts.RefreshInterval,
+		minRefetchInterval: opts.MinRefetchInterval,
+	}
+}
+
+// Key returns the raw public key for kid. An unknown kid triggers a refetch,
+// at most once per MinRefetchInterval, in case the issuer has rotated keys.
+func (c *Cache) Key(ctx context.Context, kid string) (interface{}, error) {
+	if key, ok := c.lookup(kid); ok {
+		return rawKey(key)
+	}
+
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	// Another request may have fetched it while this one waited
+	if key, ok := c.lookup(kid); ok {
+		return rawKey(key)
+	}
+
+	c.mu.RLock()
+	recent := time.Since(c.lastAttempt) < c.minRefetchInterval
+	c.mu.RUnlock()
+	if recent {
+		return nil, ErrKeyNotFound
+	}
+
+	if err := c.fetch(ctx); err != nil {
+		log.Warn().Err(err).Str("url", c.url).Str("kid", kid).Msg("Failed to refetch JWKS for unknown key")
+		return nil, ErrKeyNotFound
+	}
+
+	if key, ok := c.lookup(kid); ok {
+		return rawKey(key)
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Refresh fetches the key set now. On failure the previous keys are kept.
+func (c *Cache) Refresh(ctx context.Context) error {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	return c.fetch(ctx)
+}
+
+// Run refreshes the key set every RefreshInterval until ctx is cancelled.
+// Failures are logged and retried after MinRefetchInterval.
+func (c *Cache) Run(ctx context.Context) {
+	timer := time.NewTimer(c.refreshInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		next := c.refreshInterval
+		if err := c.Refresh(ctx); err != nil {
+			log.Warn().Err(err).Str("url", c.url).Msg("JWKS refresh failed, keeping previous keys")
+			next = c.minRefetchInterval
+		}
+		timer.Reset(next)
+	}
+}
+
+// FetchedAt returns when the key set was last fetched successfully
+func (c *Cache) FetchedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.fetchedAt
+}
+
+func (c *Cache) lookup(kid string) (jwk.Key, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.set == nil {
+		return nil, false
+	}
+	return c.set.LookupKeyID(kid)
+}
+
+// fetch downloads and replaces the key set. Caller holds fetchMu.
+func (c *Cache) fetch(ctx context.Context) error {
+	c.mu.Lock()
+	c.lastAttempt = time.Now()
+	c.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build JWKS request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS: %w", err)
+	}
+
+	set, err := jwk.Parse(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+	if set.Len() == 0 {
+		return fmt.Errorf("JWKS has no keys")
+	}
+
+	c.mu.Lock()
+	c.set = set
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+
+	log.Debug().Str("url", c.url).Int("keys", set.Len()).Msg("JWKS fetched")
+	return nil
+}
+
+func rawKey(key jwk.Key) (interface{}, error) {
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, fmt.Errorf("failed to get raw key: %w", err)
+	}
+	return raw, nil
+}
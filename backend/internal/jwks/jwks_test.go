@@ -0,0 +1,238 @@
+package jwks
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// keyServer is a local JWKS endpoint whose keys can be rotated and which can
+// be taken down
+type keyServer struct {
+	t *testing.T
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey
+	down bool
+
+	hits atomic.Int32
+	srv  *httptest.Server
+}
+
+func newKeyServer(t *testing.T) *keyServer {
+	t.Helper()
+
+	ks := &keyServer{t: t, keys: map[string]*rsa.PrivateKey{}}
+	ks.srv = httptest.NewServer(http.HandlerFunc(ks.serve))
+	t.Cleanup(ks.srv.Close)
+	return ks
+}
+
+func (ks *keyServer) serve(w http.ResponseWriter, r *http.Request) {
+	ks.hits.Add(1)
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.down {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	set := jwk.NewSet()
+	for kid, priv := range ks.keys {
+		key, err := jwk.FromRaw(priv.Public())
+		if err != nil {
+			ks.t.Errorf("jwk.FromRaw: %v", err)
+			return
+		}
+		_ = key.Set(jwk.KeyIDKey, kid)
+		_ = key.Set(jwk.AlgorithmKey, "RS256")
+		_ = set.AddKey(key)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(set)
+}
+
+// rotate replaces every published key with a new one under kid
+func (ks *keyServer) rotate(kid string) *rsa.PrivateKey {
+	ks.t.Helper()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		ks.t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+
+	ks.mu.Lock()
+	ks.keys = map[string]*rsa.PrivateKey{kid: priv}
+	ks.mu.Unlock()
+	return priv
+}
+
+func (ks *keyServer) setDown(down bool) {
+	ks.mu.Lock()
+	ks.down = down
+	ks.mu.Unlock()
+}
+
+func assertKey(t *testing.T, c *Cache, kid string, want *rsa.PrivateKey) {
+	t.Helper()
+
+	got, err := c.Key(context.Background(), kid)
+	if err != nil {
+		t.Fatalf("Key(%q): %v", kid, err)
+	}
+	pub, ok := got.(*rsa.PublicKey)
+	if !ok {
+		t.Fatalf("Key(%q) returned %T, want *rsa.PublicKey", kid, got)
+	}
+	if !pub.Equal(want.Public()) {
+		t.Fatalf("Key(%q) returned the wrong key", kid)
+	}
+}
+
+func TestKeyRefetchesOnRotation(t *testing.T) {
+	ks := newKeyServer(t)
+	first := ks.rotate("k1")
+
+	c := New(ks.srv.URL, Options{MinRefetchInterval: time.Millisecond})
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	assertKey(t, c, "k1", first)
+
+	second := ks.rotate("k2")
+	time.Sleep(2 * time.Millisecond)
+
+	assertKey(t, c, "k2", second)
+	if got := ks.hits.Load(); got != 2 {
+		t.Fatalf("server hits = %d, want 2", got)
+	}
+
+	// The old key is gone once the set has been refetched
+	time.Sleep(2 * time.Millisecond)
+	if _, err := c.Key(context.Background(), "k1"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Key(k1) after rotation: err = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestUnknownKidRefetchIsRateLimited(t *testing.T) {
+	ks := newKeyServer(t)
+	ks.rotate("k1")
+
+	c := New(ks.srv.URL, Options{MinRefetchInterval: time.Hour})
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Key(context.Background(), "forged"); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("Key(forged): err = %v, want ErrKeyNotFound", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The initial fetch only; unknown kids within the interval don't refetch
+	if got := ks.hits.Load(); got != 1 {
+		t.Fatalf("server hits = %d, want 1", got)
+	}
+}
+
+func TestKeepsKeysWhenEndpointIsDown(t *testing.T) {
+	ks := newKeyServer(t)
+	first := ks.rotate("k1")
+
+	c := New(ks.srv.URL, Options{MinRefetchInterval: time.Millisecond})
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	fetchedAt := c.FetchedAt()
+
+	ks.setDown(true)
+	if err := c.Refresh(context.Background()); err == nil {
+		t.Fatal("Refresh succeeded while endpoint is down")
+	}
+	if !c.FetchedAt().Equal(fetchedAt) {
+		t.Fatal("FetchedAt changed after a failed refresh")
+	}
+	assertKey(t, c, "k1", first)
+
+	time.Sleep(2 * time.Millisecond)
+	if _, err := c.Key(context.Background(), "k2"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Key(k2) while down: err = %v, want ErrKeyNotFound", err)
+	}
+	assertKey(t, c, "k1", first)
+}
+
+func TestRecoversAfterStartingWhileEndpointIsDown(t *testing.T) {
+	ks := newKeyServer(t)
+	ks.setDown(true)
+
+	c := New(ks.srv.URL, Options{MinRefetchInterval: time.Millisecond})
+	if err := c.Refresh(context.Background()); err == nil {
+		t.Fatal("Refresh succeeded while endpoint is down")
+	}
+	if _, err := c.Key(context.Background(), "k1"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Key(k1) while down: err = %v, want ErrKeyNotFound", err)
+	}
+
+	key := ks.rotate("k1")
+	ks.setDown(false)
+	time.Sleep(2 * time.Millisecond)
+
+	assertKey(t, c, "k1", key)
+}
+
+func TestRunRefreshesInBackground(t *testing.T) {
+	ks := newKeyServer(t)
+	ks.rotate("k1")
+
+	c := New(ks.srv.URL, Options{
+		RefreshInterval: 10 * time.Millisecond,
+		// Keep Key from refetching so only Run can pick up the new key
+		MinRefetchInterval: time.Hour,
+	})
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	second := ks.rotate("k2")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if key, ok := c.lookup("k2"); ok {
+			var pub rsa.PublicKey
+			if err := key.Raw(&pub); err != nil {
+				t.Fatalf("Raw: %v", err)
+			}
+			if !pub.Equal(second.Public()) {
+				t.Fatal("background refresh picked up the wrong key")
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not pick up the rotated key")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -1,20 +1,31 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/harrypall/havn-backend/internal/auth"
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/rs/zerolog/log"
 )
 
-// Auth middleware validates Supabase JWT tokens
-func Auth() gin.HandlerFunc {
+// IdentityResolver maps a verified token subject to the internal profile id
+type IdentityResolver interface {
+	ResolveIdentity(ctx context.Context, claims models.IdentityClaims) (string, error)
+}
+
+// Auth validates bearer tokens from any trusted issuer, picking the verifier
+// by the token's iss claim, and resolves the subject to a profile,
+// provisioning one on first sign-in
+func Auth(verifier *auth.Registry, identities IdentityResolver) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(401, gin.H{
+			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
 				"error": gin.H{
 					"code":    "MISSING_AUTH",
@@ -28,7 +39,7 @@ func Auth() gin.HandlerFunc {
 		// Extract token from "Bearer <token>"
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			c.JSON(401, gin.H{
+			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
 				"error": gin.H{
 					"code":    "INVALID_AUTH_FORMAT",
@@ -39,28 +50,23 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
-		// Parse and validate JWT
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Verify signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			// Return Supabase JWT secret
-			secret := os.Getenv("SUPABASE_JWT_SECRET")
-			if secret == "" {
-				return nil, fmt.Errorf("SUPABASE_JWT_SECRET not set")
+		principal, err := verifier.Verify(c.Request.Context(), tokenString)
+		if err != nil {
+			code, message := "INVALID_TOKEN", "Invalid or expired token"
+			switch {
+			case errors.Is(err, auth.ErrInvalidAudience):
+				code, message = "INVALID_AUDIENCE", "Token audience does not match"
+			case errors.Is(err, auth.ErrMissingSubject):
+				code, message = "MISSING_USER_ID", "User ID not found in token"
+			case errors.Is(err, auth.ErrUntrustedIssuer):
+				message = "Token issuer is not trusted"
 			}
 
-			return []byte(secret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(401, gin.H{
+			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
 				"error": gin.H{
-					"code":    "INVALID_TOKEN",
-					"message": "Invalid or expired token",
+					"code":    code,
+					"message": message,
 					"details": err.Error(),
 				},
 			})
@@ -68,43 +74,36 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
-		// Extract claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(401, gin.H{
-				"success": false,
-				"error": gin.H{
-					"code":    "INVALID_CLAIMS",
-					"message": "Invalid token claims",
-				},
-			})
-			c.Abort()
-			return
-		}
-
-		// Extract user ID from "sub" claim
-		userID, ok := claims["sub"].(string)
-		if !ok {
-			c.JSON(401, gin.H{
+		profileID, err := identities.ResolveIdentity(c.Request.Context(), principal.Identity)
+		if err != nil {
+			log.Error().Err(err).
+				Str("issuer", principal.Identity.Issuer).
+				Str("subject", principal.Identity.Subject).
+				Msg("Failed to resolve identity")
+			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
 				"error": gin.H{
-					"code":    "MISSING_USER_ID",
-					"message": "User ID not found in token",
+					"code":    "SERVER_ERROR",
+					"message": "Failed to load user profile",
 				},
 			})
 			c.Abort()
 			return
 		}
 
-		// Store user ID in context
-		c.Set("user_id", userID)
-		c.Set("claims", claims)
+		// Store profile ID, token identity and claims in context
+		c.Set("user_id", profileID)
+		c.Set("auth_issuer", principal.Identity.Issuer)
+		c.Set("auth_subject", principal.Identity.Subject)
+		c.Set("token_roles", principal.Roles)
+		c.Set("claims", principal.Claims)
 
 		c.Next()
 	}
 }
 
-// GetUserID retrieves the authenticated user ID from context
+// GetUserID retrieves the authenticated user's profile ID from context. This
+// is the internal UUID, not the token subject.
 func GetUserID(c *gin.Context) (string, error) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -118,4 +117,3 @@ func GetUserID(c *gin.Context) (string, error) {
 
 	return userIDStr, nil
 }
-
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/rbac"
+	"github.com/rs/zerolog/log"
+)
+
+// RoleResolver returns the roles granted to a user inside havn
+type RoleResolver interface {
+	UserRoles(ctx context.Context, userID string) ([]string, error)
+}
+
+// LoadRoles makes GetRoles available to later handlers. Roles are only
+// looked up when something asks for them. Must run after Auth.
+func LoadRoles(resolver RoleResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("role_resolver", resolver)
+		c.Next()
+	}
+}
+
+// GetRoles returns the authenticated user's roles: student, plus any from
+// the token's roles claim and from user_roles
+func GetRoles(c *gin.Context) ([]rbac.Role, error) {
+	if roles, ok := c.Get("roles"); ok {
+		return roles.([]rbac.Role), nil
+	}
+
+	granted, err := grantedRoleNames(c)
+	if err != nil {
+		return nil, err
+	}
+
+	names := c.GetStringSlice("token_roles")
+	roles := rbac.Normalize(append(names, granted...)...)
+
+	c.Set("roles", roles)
+	return roles, nil
+}
+
+// GetGrantedRoles returns the authenticated user's roles from user_roles
+// only, ignoring the token's roles claim. Use it where the decision must
+// match background work that can only see stored grants.
+func GetGrantedRoles(c *gin.Context) ([]rbac.Role, error) {
+	granted, err := grantedRoleNames(c)
+	if err != nil {
+		return nil, err
+	}
+	return rbac.Normalize(granted...), nil
+}
+
+// grantedRoleNames looks up the user's user_roles once per request
+func grantedRoleNames(c *gin.Context) ([]string, error) {
+	if granted, ok := c.Get("granted_roles"); ok {
+		return granted.([]string), nil
+	}
+
+	userID, err := GetUserID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, ok := c.Get("role_resolver")
+	if !ok {
+		return nil, fmt.Errorf("roles not loaded")
+	}
+
+	granted, err := resolver.(RoleResolver).UserRoles(c.Request.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Set("granted_roles", granted)
+	return granted, nil
+}
+
+// HasPermission reports whether the authenticated user holds p. Lookup
+// failures count as no.
+func HasPermission(c *gin.Context, p rbac.Permission) bool {
+	roles, err := GetRoles(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load roles")
+		return false
+	}
+	return rbac.HasPermission(roles, p)
+}
+
+// HasGrantedPermission reports whether the user's stored grants carry p,
+// ignoring token claims. Lookup failures count as no.
+func HasGrantedPermission(c *gin.Context, p rbac.Permission) bool {
+	roles, err := GetGrantedRoles(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load granted roles")
+		return false
+	}
+	return rbac.HasPermission(roles, p)
+}
+
+// RequireRole allows only users holding at least one of roles
+func RequireRole(roles ...rbac.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		held, ok := rolesOrAbort(c)
+		if !ok {
+			return
+		}
+
+		if !rbac.HasRole(held, roles...) {
+			forbidden(c, "Insufficient role")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission allows only users whose roles carry p
+func RequirePermission(p rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		held, ok := rolesOrAbort(c)
+		if !ok {
+			return
+		}
+
+		if !rbac.HasPermission(held, p) {
+			forbidden(c, fmt.Sprintf("Permission %s required", p))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func rolesOrAbort(c *gin.Context) ([]rbac.Role, bool) {
+	roles, err := GetRoles(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load roles")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "SERVER_ERROR",
+				"message": "Failed to load roles",
+			},
+		})
+		c.Abort()
+		return nil, false
+	}
+	return roles, true
+}
+
+func forbidden(c *gin.Context, message string) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":    "FORBIDDEN",
+			"message": message,
+		},
+	})
+	c.Abort()
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/harrypall/havn-backend/internal/rbac"
+)
+
+// fakeResolver answers UserRoles from a fixed set of stored grants
+type fakeResolver []string
+
+func (f fakeResolver) UserRoles(ctx context.Context, userID string) ([]string, error) {
+	return f, nil
+}
+
+// rolesContext builds a request context as Auth and LoadRoles would leave it
+func rolesContext(tokenRoles []string, granted fakeResolver) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Set("user_id", "11111111-1111-1111-1111-111111111111")
+	c.Set("token_roles", tokenRoles)
+	c.Set("role_resolver", RoleResolver(granted))
+	return c
+}
+
+func TestGrantedPermissionIgnoresTokenClaims(t *testing.T) {
+	c := rolesContext([]string{"super_admin"}, nil)
+
+	if !HasPermission(c, rbac.PermPartnersManage) {
+		t.Error("a token-claim super_admin should hold partners:manage")
+	}
+	if HasGrantedPermission(c, rbac.PermPartnersManage) {
+		t.Error("a token-claim super_admin must not count as a granted partners:manage")
+	}
+}
+
+func TestGrantedPermissionFromStoredGrants(t *testing.T) {
+	c := rolesContext(nil, fakeResolver{"super_admin"})
+
+	if !HasGrantedPermission(c, rbac.PermPartnersManage) {
+		t.Error("a stored super_admin grant should hold partners:manage")
+	}
+	if !HasPermission(c, rbac.PermPartnersManage) {
+		t.Error("stored grants should also count for HasPermission")
+	}
+}
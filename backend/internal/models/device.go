@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Device represents an app install registered to receive push notifications
+type Device struct {
+	ID                   string    `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID               string    `json:"user_id" gorm:"type:uuid;not null"`
+	Platform             string    `json:"platform" gorm:"type:varchar(20);not null"`
+	PushToken            string    `json:"push_token" gorm:"not null;unique"`
+	AppVersion           *string   `json:"app_version,omitempty" gorm:"type:varchar(50)"`
+	NotificationsEnabled bool      `json:"notifications_enabled" gorm:"default:true"`
+	LastSeenAt           time.Time `json:"last_seen_at" gorm:"default:now()"`
+	CreatedAt            time.Time `json:"created_at" gorm:"default:now()"`
+	UpdatedAt            time.Time `json:"updated_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (Device) TableName() string {
+	return "user_devices"
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// FriendGroup represents a named list of friends such as "Close friends"
+type FriendGroup struct {
+	ID                 string    `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	OwnerID            string    `json:"owner_id" gorm:"type:uuid;not null"`
+	Name               string    `json:"name" gorm:"type:varchar(50);not null"`
+	Visibility         string    `json:"visibility" gorm:"type:varchar(20);default:'default'"` // default|exact|building|hidden
+	NotificationsMuted bool      `json:"notifications_muted" gorm:"default:false"`
+	CreatedAt          time.Time `json:"created_at" gorm:"default:now()"`
+	UpdatedAt          time.Time `json:"updated_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (FriendGroup) TableName() string {
+	return "friend_groups"
+}
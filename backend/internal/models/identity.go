@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// AuthIdentity links a token issuer's subject to a profile
+type AuthIdentity struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	Issuer     string    `json:"issuer" gorm:"not null"`
+	Subject    string    `json:"subject" gorm:"not null"`
+	UserID     string    `json:"user_id" gorm:"type:uuid;not null"`
+	Email      *string   `json:"email,omitempty"`
+	CreatedAt  time.Time `json:"created_at" gorm:"default:now()"`
+	LastSeenAt time.Time `json:"last_seen_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (AuthIdentity) TableName() string {
+	return "auth_identities"
+}
+
+// IdentityClaims are the verified token claims used to find or provision a
+// profile
+type IdentityClaims struct {
+	Issuer   string
+	Subject  string
+	Email    string
+	Nickname string
+	Name     string
+	Picture  string
+
+	// ProfileID is set by issuers whose subjects are already profile ids;
+	// an existing profile with that id is linked rather than a new one made
+	ProfileID string
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// FriendInvite represents a signed, expiring invite to become friends with the inviter
+type FriendInvite struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	InviterID string     `json:"inviter_id" gorm:"type:uuid;not null"`
+	MaxUses   int        `json:"max_uses" gorm:"not null;default:1"`
+	UseCount  int        `json:"use_count" gorm:"not null;default:0"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"default:now()"`
+	Token     string     `json:"token,omitempty" gorm:"-"` // Only returned when minted
+	URL       string     `json:"url,omitempty" gorm:"-"`   // Only returned when minted
+}
+
+// TableName specifies the table name for GORM
+func (FriendInvite) TableName() string {
+	return "friend_invites"
+}
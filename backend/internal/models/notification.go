@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification represents an in-app notification, which is also queued for push delivery
+type Notification struct {
+	ID           string     `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID       string     `json:"user_id" gorm:"type:uuid;not null"`
+	Type         string     `json:"type" gorm:"type:varchar(50);not null"`
+	Title        string     `json:"title" gorm:"type:varchar(200);not null"`
+	Body         string     `json:"body" gorm:"not null"`
+	Data         JSONB      `json:"data,omitempty" gorm:"type:jsonb"`
+	Status       string     `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	SentAt       *time.Time `json:"sent_at,omitempty"`
+	ErrorMessage *string    `json:"error_message,omitempty"`
+	Read         bool       `json:"read" gorm:"default:false"`
+	ReadAt       *time.Time `json:"read_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (Notification) TableName() string {
+	return "notifications"
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent represents a domain event awaiting or past delivery by the outbox relay
+type OutboxEvent struct {
+	ID            int64           `json:"id" gorm:"primaryKey"`
+	EventID       string          `json:"event_id" gorm:"type:uuid;not null;unique"`
+	EventType     string          `json:"event_type" gorm:"type:varchar(100);not null"`
+	AggregateType string          `json:"aggregate_type" gorm:"type:varchar(50);not null"`
+	AggregateID   string          `json:"aggregate_id" gorm:"not null"`
+	Payload       json.RawMessage `json:"payload" gorm:"type:jsonb;not null"`
+	OccurredAt    time.Time       `json:"occurred_at" gorm:"not null"`
+	Status        string          `json:"status" gorm:"type:varchar(20);default:'pending'"` // pending|delivered|dead
+	Attempts      int             `json:"attempts" gorm:"default:0"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" gorm:"default:now()"`
+	LastError     *string         `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// UserRole is a role granted to a user inside havn
+type UserRole struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey;type:uuid"`
+	Role      string    `json:"role" gorm:"primaryKey;type:varchar(30)"`
+	GrantedBy *string   `json:"granted_by,omitempty" gorm:"type:uuid"`
+	GrantedAt time.Time `json:"granted_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (UserRole) TableName() string {
+	return "user_roles"
+}
+
+// RoleAuditEntry records a role grant or revocation
+type RoleAuditEntry struct {
+	ID        int64     `json:"id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"type:uuid;not null"`
+	Role      string    `json:"role" gorm:"type:varchar(30);not null"`
+	Action    string    `json:"action" gorm:"type:varchar(10);not null"`
+	ActorID   *string   `json:"actor_id,omitempty" gorm:"type:uuid"`
+	Reason    *string   `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (RoleAuditEntry) TableName() string {
+	return "role_audit_log"
+}
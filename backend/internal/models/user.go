@@ -6,21 +6,21 @@ import (
 
 // Profile represents a user profile
 type Profile struct {
-	ID              string     `json:"id" gorm:"primaryKey;type:uuid"`
-	Username        string     `json:"username" gorm:"type:varchar(20);unique;not null"`
-	FullName        string     `json:"full_name,omitempty" gorm:"type:varchar(100)"`
-	AvatarURL       string     `json:"avatar_url,omitempty"`
-	UniversityID    *string    `json:"university_id,omitempty" gorm:"type:uuid"`
-	GraduationYear  int        `json:"graduation_year,omitempty"`
-	Major           string     `json:"major,omitempty" gorm:"type:varchar(100)"`
-	Bio             string     `json:"bio,omitempty"`
-	LocationSharing string     `json:"location_sharing" gorm:"type:varchar(20);default:'friends'"`
-	CurrentSpotID   *string    `json:"current_spot_id,omitempty" gorm:"type:uuid"`
-	CheckedInAt     *time.Time `json:"checked_in_at,omitempty"`
-	PushToken       string     `json:"push_token,omitempty"`
-	Preferences     JSONB      `json:"preferences" gorm:"type:jsonb"`
-	CreatedAt       time.Time  `json:"created_at" gorm:"default:now()"`
-	UpdatedAt       time.Time  `json:"updated_at" gorm:"default:now()"`
+	ID                    string     `json:"id" gorm:"primaryKey;type:uuid"`
+	Username              string     `json:"username" gorm:"type:varchar(20);unique;not null"`
+	FullName              string     `json:"full_name,omitempty" gorm:"type:varchar(100)"`
+	AvatarURL             string     `json:"avatar_url,omitempty"`
+	UniversityID          *string    `json:"university_id,omitempty" gorm:"type:uuid"`
+	GraduationYear        int        `json:"graduation_year,omitempty"`
+	Major                 string     `json:"major,omitempty" gorm:"type:varchar(100)"`
+	Bio                   string     `json:"bio,omitempty"`
+	LocationSharing       string     `json:"location_sharing" gorm:"type:varchar(20);default:'friends'"`
+	CurrentSpotID         *string    `json:"current_spot_id,omitempty" gorm:"type:uuid"`
+	CheckedInAt           *time.Time `json:"checked_in_at,omitempty"`
+	ColocationSuggestions bool       `json:"colocation_suggestions" gorm:"default:true"`
+	Preferences           JSONB      `json:"preferences" gorm:"type:jsonb"`
+	CreatedAt             time.Time  `json:"created_at" gorm:"default:now()"`
+	UpdatedAt             time.Time  `json:"updated_at" gorm:"default:now()"`
 }
 
 // TableName specifies the table name for GORM
@@ -44,4 +44,3 @@ type Friendship struct {
 func (Friendship) TableName() string {
 	return "friendships"
 }
-
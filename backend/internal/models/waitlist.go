@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// WaitlistEntry represents a user's place in a spot's waitlist
+type WaitlistEntry struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	SpotID         string     `json:"spot_id" gorm:"type:uuid;not null"`
+	UserID         string     `json:"user_id" gorm:"type:uuid;not null"`
+	Status         string     `json:"status" gorm:"type:varchar(20);default:'waiting'"` // waiting|offered|claimed|expired|left
+	JoinedAt       time.Time  `json:"joined_at" gorm:"not null;default:now()"`
+	OfferedAt      *time.Time `json:"offered_at,omitempty"`
+	ClaimExpiresAt *time.Time `json:"claim_expires_at,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (WaitlistEntry) TableName() string {
+	return "spot_waitlist_entries"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// SpotWatch represents a subscription to be notified when a spot has space
+type SpotWatch struct {
+	ID               string     `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID           string     `json:"user_id" gorm:"type:uuid;not null"`
+	SpotID           string     `json:"spot_id" gorm:"type:uuid;not null"`
+	ThresholdPercent int        `json:"threshold_percent" gorm:"not null"`
+	ExpiresAt        time.Time  `json:"expires_at" gorm:"not null"`
+	Armed            bool       `json:"armed" gorm:"default:true"`
+	LastNotifiedAt   *time.Time `json:"last_notified_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"default:now()"`
+	UpdatedAt        time.Time  `json:"updated_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (SpotWatch) TableName() string {
+	return "spot_watches"
+}
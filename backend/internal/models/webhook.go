@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookSubscription represents a partner endpoint receiving events for a set
+// of spots and buildings
+type WebhookSubscription struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	OwnerID       string    `json:"owner_id" gorm:"type:uuid;not null"`
+	URL           string    `json:"url" gorm:"not null"`
+	Description   *string   `json:"description,omitempty" gorm:"type:varchar(200)"`
+	Secret        string    `json:"-" gorm:"not null"`
+	SpotIDs       []string  `json:"spot_ids" gorm:"type:uuid[]"`
+	BuildingNames []string  `json:"building_names" gorm:"type:text[]"`
+	EventTypes    []string  `json:"event_types" gorm:"type:text[];not null"`
+	Active        bool      `json:"active" gorm:"default:true"`
+	CreatedAt     time.Time `json:"created_at" gorm:"default:now()"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// WebhookDelivery represents one event sent, or to be sent, to a subscription
+type WebhookDelivery struct {
+	ID             string          `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	SubscriptionID string          `json:"subscription_id" gorm:"type:uuid;not null"`
+	EventID        string          `json:"event_id" gorm:"type:uuid;not null"`
+	EventType      string          `json:"event_type" gorm:"type:varchar(100);not null"`
+	Payload        json.RawMessage `json:"payload" gorm:"type:jsonb;not null"`
+	Status         string          `json:"status" gorm:"type:varchar(20);default:'pending'"` // pending|succeeded|failed
+	Attempts       int             `json:"attempts" gorm:"default:0"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" gorm:"default:now()"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ResponseBody   *string         `json:"response_body,omitempty"`
+	DurationMs     *int            `json:"duration_ms,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at" gorm:"default:now()"`
+	UpdatedAt      time.Time       `json:"updated_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// PartnerScope grants a partner account access to a spot or a whole building
+type PartnerScope struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID       string    `json:"user_id" gorm:"type:uuid;not null"`
+	SpotID       *string   `json:"spot_id,omitempty" gorm:"type:uuid"`
+	BuildingName *string   `json:"building_name,omitempty" gorm:"type:varchar(200)"`
+	CreatedBy    *string   `json:"created_by,omitempty" gorm:"type:uuid"`
+	CreatedAt    time.Time `json:"created_at" gorm:"default:now()"`
+}
+
+// TableName specifies the table name for GORM
+func (PartnerScope) TableName() string {
+	return "partner_scopes"
+}
@@ -0,0 +1,121 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultExpoEndpoint is the Expo push API send endpoint
+const DefaultExpoEndpoint = "https://exp.host/--/api/v2/push/send"
+
+// expoMaxBatchSize is the maximum number of messages Expo accepts per request
+const expoMaxBatchSize = 100
+
+// ExpoSender sends push notifications through the Expo push service
+type ExpoSender struct {
+	client      *http.Client
+	endpoint    string
+	accessToken string
+}
+
+// NewExpoSender creates an Expo sender. accessToken is optional and only
+// required if enhanced push security is enabled for the Expo project.
+func NewExpoSender(endpoint, accessToken string) *ExpoSender {
+	if endpoint == "" {
+		endpoint = DefaultExpoEndpoint
+	}
+
+	return &ExpoSender{
+		client:      &http.Client{Timeout: 15 * time.Second},
+		endpoint:    endpoint,
+		accessToken: accessToken,
+	}
+}
+
+// MaxBatchSize returns the largest number of messages accepted per Send call
+func (s *ExpoSender) MaxBatchSize() int {
+	return expoMaxBatchSize
+}
+
+// expoResponse is the body returned by the Expo send endpoint
+type expoResponse struct {
+	Data []struct {
+		Status  string `json:"status"`
+		ID      string `json:"id"`
+		Message string `json:"message"`
+		Details struct {
+			Error string `json:"error"`
+		} `json:"details"`
+	} `json:"data"`
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Send sends a batch of messages, returning one ticket per message in order.
+// A non-nil error means the whole batch failed and may be retried.
+func (s *ExpoSender) Send(ctx context.Context, messages []Message) ([]Ticket, error) {
+	if len(messages) > expoMaxBatchSize {
+		return nil, fmt.Errorf("batch of %d exceeds Expo limit of %d", len(messages), expoMaxBatchSize)
+	}
+
+	payload, err := json.Marshal(messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode push messages: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if s.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.accessToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("push request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read push response: %w", err)
+	}
+
+	var parsed expoResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("push service returned status %d with unreadable body", resp.StatusCode)
+	}
+
+	if resp.StatusCode != http.StatusOK || len(parsed.Errors) > 0 {
+		if len(parsed.Errors) > 0 {
+			return nil, fmt.Errorf("push service returned status %d: %s: %s", resp.StatusCode, parsed.Errors[0].Code, parsed.Errors[0].Message)
+		}
+		return nil, fmt.Errorf("push service returned status %d", resp.StatusCode)
+	}
+
+	if len(parsed.Data) != len(messages) {
+		return nil, fmt.Errorf("push service returned %d tickets for %d messages", len(parsed.Data), len(messages))
+	}
+
+	tickets := make([]Ticket, len(parsed.Data))
+	for i, d := range parsed.Data {
+		tickets[i] = Ticket{
+			Status:  d.Status,
+			ID:      d.ID,
+			Message: d.Message,
+			Error:   d.Details.Error,
+		}
+	}
+
+	return tickets, nil
+}
@@ -0,0 +1,122 @@
+package push_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harrypall/havn-backend/internal/push"
+)
+
+// expoServer answers like the Expo send endpoint, failing tokens in dead with
+// DeviceNotRegistered
+func expoServer(t *testing.T, dead map[string]bool, received *[][]push.Message) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer expo-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+
+		var messages []push.Message
+		if err := json.NewDecoder(r.Body).Decode(&messages); err != nil {
+			t.Errorf("decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*received = append(*received, messages)
+
+		data := []map[string]interface{}{}
+		for i, m := range messages {
+			if dead[m.To] {
+				data = append(data, map[string]interface{}{
+					"status":  "error",
+					"message": fmt.Sprintf("%q is not a registered push notification recipient", m.To),
+					"details": map[string]string{"error": push.ErrorDeviceNotRegistered},
+				})
+				continue
+			}
+			data = append(data, map[string]interface{}{"status": "ok", "id": fmt.Sprintf("ticket-%d", i)})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+}
+
+func TestExpoSenderTickets(t *testing.T) {
+	var received [][]push.Message
+	server := expoServer(t, map[string]bool{"ExponentPushToken[dead]": true}, &received)
+	defer server.Close()
+
+	sender := push.NewExpoSender(server.URL, "expo-token")
+	tickets, err := sender.Send(context.Background(), []push.Message{
+		{To: "ExponentPushToken[alive]", Title: "Hi", Data: map[string]interface{}{"notification_id": "n1"}},
+		{To: "ExponentPushToken[dead]", Title: "Hi"},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if len(received) != 1 || len(received[0]) != 2 {
+		t.Fatalf("received %v, want one request of two messages", received)
+	}
+	if received[0][0].Data["notification_id"] != "n1" {
+		t.Errorf("data = %v", received[0][0].Data)
+	}
+
+	if len(tickets) != 2 {
+		t.Fatalf("got %d tickets, want 2", len(tickets))
+	}
+	if tickets[0].Status != push.StatusOK || tickets[0].ID != "ticket-0" {
+		t.Errorf("ticket 0 = %+v", tickets[0])
+	}
+	if tickets[1].Status != push.StatusError || tickets[1].Error != push.ErrorDeviceNotRegistered {
+		t.Errorf("ticket 1 = %+v", tickets[1])
+	}
+}
+
+func TestExpoSenderRejectsOversizedBatch(t *testing.T) {
+	var received [][]push.Message
+	server := expoServer(t, nil, &received)
+	defer server.Close()
+
+	sender := push.NewExpoSender(server.URL, "expo-token")
+	messages := make([]push.Message, sender.MaxBatchSize()+1)
+	if _, err := sender.Send(context.Background(), messages); err == nil {
+		t.Error("expected an oversized batch to be rejected")
+	}
+	if len(received) != 0 {
+		t.Errorf("oversized batch reached the server")
+	}
+}
+
+func TestExpoSenderRequestErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"server error", http.StatusInternalServerError, `{}`, "status 500"},
+		{"request error", http.StatusBadRequest, `{"errors":[{"code":"VALIDATION_ERROR","message":"bad"}]}`, "VALIDATION_ERROR"},
+		{"unreadable", http.StatusBadGateway, `<html>`, "unreadable body"},
+		{"ticket mismatch", http.StatusOK, `{"data":[]}`, "0 tickets for 1 messages"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			sender := push.NewExpoSender(server.URL, "")
+			_, err := sender.Send(context.Background(), []push.Message{{To: "ExponentPushToken[a]"}})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
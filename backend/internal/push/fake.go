@@ -0,0 +1,88 @@
+package push
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeSender is an in-memory push sender for tests and local development. It
+// records every message and returns configurable per-token failures.
+type FakeSender struct {
+	mu        sync.Mutex
+	sent      []Message
+	batches   []int
+	failures  map[string]string
+	err       error
+	batchSize int
+}
+
+// NewFakeSender creates a fake sender that accepts every message
+func NewFakeSender() *FakeSender {
+	return &FakeSender{failures: map[string]string{}, batchSize: expoMaxBatchSize}
+}
+
+// FailToken makes every message to token fail with the given error code,
+// e.g. ErrorDeviceNotRegistered
+func (f *FakeSender) FailToken(token, code string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[token] = code
+}
+
+// FailAll makes every Send call fail with err until reset with nil
+func (f *FakeSender) FailAll(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// SetMaxBatchSize overrides the batch size reported by MaxBatchSize
+func (f *FakeSender) SetMaxBatchSize(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batchSize = n
+}
+
+// Sent returns a copy of the messages accepted so far
+func (f *FakeSender) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
+
+// BatchSizes returns the size of every Send call so far, including failed ones
+func (f *FakeSender) BatchSizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.batches...)
+}
+
+// MaxBatchSize returns the largest number of messages accepted per Send call
+func (f *FakeSender) MaxBatchSize() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.batchSize
+}
+
+// Send records messages, failing those addressed to tokens set with FailToken
+func (f *FakeSender) Send(ctx context.Context, messages []Message) ([]Ticket, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.batches = append(f.batches, len(messages))
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	tickets := make([]Ticket, len(messages))
+	for i, m := range messages {
+		if code, ok := f.failures[m.To]; ok {
+			tickets[i] = Ticket{Status: StatusError, Message: "fake failure", Error: code}
+			continue
+		}
+		f.sent = append(f.sent, m)
+		tickets[i] = Ticket{Status: StatusOK}
+	}
+
+	return tickets, nil
+}
@@ -0,0 +1,30 @@
+// Package push implements senders for mobile push notifications.
+package push
+
+// Ticket statuses returned by a sender for each message
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// ErrorDeviceNotRegistered means the token is no longer valid (the app was
+// uninstalled or the token was rotated) and must not be used again.
+const ErrorDeviceNotRegistered = "DeviceNotRegistered"
+
+// Message is a single push notification addressed to one device token
+type Message struct {
+	To    string                 `json:"to"`
+	Title string                 `json:"title,omitempty"`
+	Body  string                 `json:"body,omitempty"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+	Sound string                 `json:"sound,omitempty"`
+}
+
+// Ticket is the per-message result of a send. Error holds a machine-readable
+// code such as ErrorDeviceNotRegistered when Status is StatusError.
+type Ticket struct {
+	Status  string
+	ID      string
+	Message string
+	Error   string
+}
@@ -0,0 +1,162 @@
+// Package rbac defines roles, the permissions they carry and who may grant
+// them.
+package rbac
+
+import (
+	"sort"
+)
+
+// Role is a named set of permissions
+type Role string
+
+const (
+	// Student is every authenticated user's implicit role
+	Student     Role = "student"
+	Moderator   Role = "moderator"
+	CampusAdmin Role = "campus_admin"
+	SuperAdmin  Role = "super_admin"
+)
+
+// Permission is a single privileged capability
+type Permission string
+
+const (
+	PermSpotsVerify     Permission = "spots:verify"
+	PermContentModerate Permission = "content:moderate"
+	PermRolesManage     Permission = "roles:manage"
+	PermAuditRead       Permission = "audit:read"
+	PermPartnersManage  Permission = "partners:manage"
+	PermOutboxManage    Permission = "outbox:manage"
+)
+
+// rolePermissions lists what each role may do. Roles don't inherit; higher
+// roles repeat the permissions of lower ones.
+var rolePermissions = map[Role][]Permission{
+	Student: {},
+	Moderator: {
+		PermSpotsVerify,
+		PermContentModerate,
+	},
+	CampusAdmin: {
+		PermSpotsVerify,
+		PermContentModerate,
+		PermRolesManage,
+		PermAuditRead,
+	},
+	SuperAdmin: {
+		PermSpotsVerify,
+		PermContentModerate,
+		PermRolesManage,
+		PermAuditRead,
+		PermPartnersManage,
+		PermOutboxManage,
+	},
+}
+
+// grantable lists the roles each role may grant and revoke
+var grantable = map[Role][]Role{
+	CampusAdmin: {Moderator},
+	SuperAdmin:  {Moderator, CampusAdmin, SuperAdmin},
+}
+
+// StaffRoles are the roles with any privileged access
+var StaffRoles = []Role{Moderator, CampusAdmin, SuperAdmin}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Assignable reports whether r can be stored in user_roles; student is
+// implicit
+func (r Role) Assignable() bool {
+	return r.Valid() && r != Student
+}
+
+// Permissions returns the permissions of r
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// Normalize parses role names from any source, dropping unknown ones and
+// duplicates, and always includes student
+func Normalize(names ...string) []Role {
+	seen := map[Role]bool{Student: true}
+	roles := []Role{Student}
+
+	for _, name := range names {
+		r := Role(name)
+		if !r.Valid() || seen[r] {
+			continue
+		}
+		seen[r] = true
+		roles = append(roles, r)
+	}
+	return roles
+}
+
+// HasRole reports whether roles includes any of want
+func HasRole(roles []Role, want ...Role) bool {
+	for _, r := range roles {
+		for _, w := range want {
+			if r == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether any of roles carries p
+func HasPermission(roles []Role, p Permission) bool {
+	for _, r := range roles {
+		for _, rp := range rolePermissions[r] {
+			if rp == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RolesWith returns the roles that carry p
+func RolesWith(p Permission) []Role {
+	roles := []Role{}
+	for _, r := range []Role{Student, Moderator, CampusAdmin, SuperAdmin} {
+		if HasPermission([]Role{r}, p) {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+// Permissions returns the union of the permissions of roles, sorted
+func Permissions(roles []Role) []Permission {
+	seen := map[Permission]bool{}
+	perms := []Permission{}
+
+	for _, r := range roles {
+		for _, p := range rolePermissions[r] {
+			if !seen[p] {
+				seen[p] = true
+				perms = append(perms, p)
+			}
+		}
+	}
+
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
+
+// CanGrant reports whether a user holding roles may grant or revoke target
+func CanGrant(roles []Role, target Role) bool {
+	for _, r := range roles {
+		for _, g := range grantable[r] {
+			if g == target {
+				return true
+			}
+		}
+	}
+	return false
+}
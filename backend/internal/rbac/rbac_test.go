@@ -0,0 +1,131 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+)
+
+var allRoles = []Role{Student, Moderator, CampusAdmin, SuperAdmin}
+
+var allPermissions = []Permission{
+	PermSpotsVerify,
+	PermContentModerate,
+	PermRolesManage,
+	PermAuditRead,
+	PermPartnersManage,
+	PermOutboxManage,
+}
+
+func TestRolePermissions(t *testing.T) {
+	want := map[Role]map[Permission]bool{
+		Student: {},
+		Moderator: {
+			PermSpotsVerify:     true,
+			PermContentModerate: true,
+		},
+		CampusAdmin: {
+			PermSpotsVerify:     true,
+			PermContentModerate: true,
+			PermRolesManage:     true,
+			PermAuditRead:       true,
+		},
+		SuperAdmin: {
+			PermSpotsVerify:     true,
+			PermContentModerate: true,
+			PermRolesManage:     true,
+			PermAuditRead:       true,
+			PermPartnersManage:  true,
+			PermOutboxManage:    true,
+		},
+	}
+
+	for _, role := range allRoles {
+		for _, perm := range allPermissions {
+			if got := HasPermission([]Role{role}, perm); got != want[role][perm] {
+				t.Errorf("HasPermission(%s, %s) = %v, want %v", role, perm, got, want[role][perm])
+			}
+		}
+	}
+
+	if HasPermission(nil, PermSpotsVerify) {
+		t.Error("no roles must carry no permissions")
+	}
+	if HasPermission([]Role{"root"}, PermOutboxManage) {
+		t.Error("an unknown role must carry no permissions")
+	}
+}
+
+func TestCanGrant(t *testing.T) {
+	want := map[Role]map[Role]bool{
+		Student:     {},
+		Moderator:   {},
+		CampusAdmin: {Moderator: true},
+		SuperAdmin:  {Moderator: true, CampusAdmin: true, SuperAdmin: true},
+	}
+
+	for _, granter := range allRoles {
+		for _, target := range allRoles {
+			if got := CanGrant([]Role{granter}, target); got != want[granter][target] {
+				t.Errorf("CanGrant(%s, %s) = %v, want %v", granter, target, got, want[granter][target])
+			}
+		}
+	}
+
+	// Holding several roles grants the union
+	if !CanGrant([]Role{Student, CampusAdmin}, Moderator) {
+		t.Error("campus_admin alongside student should grant moderator")
+	}
+	if CanGrant([]Role{Student, Moderator, CampusAdmin}, CampusAdmin) {
+		t.Error("campus_admin must not grant campus_admin")
+	}
+	if CanGrant([]Role{Student, Moderator, CampusAdmin}, SuperAdmin) {
+		t.Error("campus_admin must not grant super_admin")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  []Role
+	}{
+		{nil, []Role{Student}},
+		{[]string{"moderator"}, []Role{Student, Moderator}},
+		{[]string{"super_admin", "moderator", "super_admin"}, []Role{Student, SuperAdmin, Moderator}},
+		{[]string{"admin", "root", "Moderator", ""}, []Role{Student}},
+		{[]string{"student", "campus_admin"}, []Role{Student, CampusAdmin}},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.names...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Normalize(%q) = %v, want %v", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestAssignable(t *testing.T) {
+	for _, role := range []Role{Moderator, CampusAdmin, SuperAdmin} {
+		if !role.Assignable() {
+			t.Errorf("%s should be assignable", role)
+		}
+	}
+	for _, role := range []Role{Student, "admin", ""} {
+		if role.Assignable() {
+			t.Errorf("%q should not be assignable", role)
+		}
+	}
+}
+
+func TestPermissionsAndRolesWith(t *testing.T) {
+	got := Permissions([]Role{Moderator, CampusAdmin})
+	want := []Permission{PermAuditRead, PermContentModerate, PermRolesManage, PermSpotsVerify}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Permissions = %v, want %v", got, want)
+	}
+
+	if got := RolesWith(PermPartnersManage); !reflect.DeepEqual(got, []Role{SuperAdmin}) {
+		t.Errorf("RolesWith(partners:manage) = %v, want [super_admin]", got)
+	}
+	if got := RolesWith(PermSpotsVerify); !reflect.DeepEqual(got, []Role{Moderator, CampusAdmin, SuperAdmin}) {
+		t.Errorf("RolesWith(spots:verify) = %v", got)
+	}
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/rs/zerolog/log"
+)
+
+// DeviceService manages the devices registered for push notifications
+type DeviceService struct {
+	db         *database.Database
+	staleAfter time.Duration
+}
+
+// NewDeviceService creates a new device service. Devices not seen for
+// DEVICE_STALE_AFTER (default 60 days) stop receiving pushes and are pruned.
+func NewDeviceService(db *database.Database) *DeviceService {
+	return &DeviceService{
+		db:         db,
+		staleAfter: envDuration("DEVICE_STALE_AFTER", 60*24*time.Hour),
+	}
+}
+
+// validPlatforms lists the accepted values for user_devices.platform
+var validPlatforms = map[string]bool{
+	"ios":     true,
+	"android": true,
+	"web":     true,
+}
+
+// DeviceRegistration holds the details sent by the app when registering a device
+type DeviceRegistration struct {
+	Platform             string
+	PushToken            string
+	AppVersion           string
+	NotificationsEnabled *bool
+}
+
+// activeDevice is a device eligible to receive pushes
+type activeDevice struct {
+	ID        string
+	UserID    string
+	PushToken string
+}
+
+// GetDevices lists the user's registered devices
+func (s *DeviceService) GetDevices(ctx context.Context, userID string) ([]models.Device, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, user_id, platform, push_token, app_version, notifications_enabled,
+		       last_seen_at, created_at, updated_at
+		FROM user_devices
+		WHERE user_id = $1
+		ORDER BY last_seen_at DESC
+	`, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query devices")
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+	defer rows.Close()
+
+	devices := []models.Device{}
+	for rows.Next() {
+		var device models.Device
+		err := rows.Scan(
+			&device.ID,
+			&device.UserID,
+			&device.Platform,
+			&device.PushToken,
+			&device.AppVersion,
+			&device.NotificationsEnabled,
+			&device.LastSeenAt,
+			&device.CreatedAt,
+			&device.UpdatedAt,
+		)
+
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan device")
+			continue
+		}
+
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+// Register adds or refreshes a device. The app calls this on every launch, so
+// it also updates last_seen_at. A token already registered to another user
+// (e.g. after logging into a different account) is moved to this user.
+func (s *DeviceService) Register(ctx context.Context, userID string, reg DeviceRegistration) (*models.Device, error) {
+	if !validPlatforms[reg.Platform] {
+		return nil, fmt.Errorf("invalid platform")
+	}
+
+	var appVersion *string
+	if reg.AppVersion != "" {
+		appVersion = &reg.AppVersion
+	}
+
+	var device models.Device
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO user_devices (user_id, platform, push_token, app_version, notifications_enabled)
+		VALUES ($1, $2, $3, $4, COALESCE($5, true))
+		ON CONFLICT (push_token) DO UPDATE
+		SET user_id = EXCLUDED.user_id,
+		    platform = EXCLUDED.platform,
+		    app_version = COALESCE(EXCLUDED.app_version, user_devices.app_version),
+		    notifications_enabled = COALESCE($5, CASE
+		        WHEN user_devices.user_id = EXCLUDED.user_id THEN user_devices.notifications_enabled
+		        ELSE true
+		    END),
+		    last_seen_at = NOW(),
+		    updated_at = NOW()
+		RETURNING id, user_id, platform, push_token, app_version, notifications_enabled,
+		          last_seen_at, created_at, updated_at
+	`, userID, reg.Platform, reg.PushToken, appVersion, reg.NotificationsEnabled).Scan(
+		&device.ID,
+		&device.UserID,
+		&device.Platform,
+		&device.PushToken,
+		&device.AppVersion,
+		&device.NotificationsEnabled,
+		&device.LastSeenAt,
+		&device.CreatedAt,
+		&device.UpdatedAt,
+	)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to register device")
+		return nil, fmt.Errorf("failed to register device: %w", err)
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("device_id", device.ID).
+		Str("platform", device.Platform).
+		Msg("Device registered")
+
+	return &device, nil
+}
+
+// Unregister removes one of the user's devices, e.g. on logout
+func (s *DeviceService) Unregister(ctx context.Context, userID, deviceID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM user_devices WHERE id = $1 AND user_id = $2
+	`, deviceID, userID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to unregister device")
+		return fmt.Errorf("failed to unregister device: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("device not found")
+	}
+
+	log.Info().Str("user_id", userID).Str("device_id", deviceID).Msg("Device unregistered")
+	return nil
+}
+
+// activeDevices returns the opted-in, recently seen devices of the given users
+func (s *DeviceService) activeDevices(ctx context.Context, userIDs []string) (map[string][]activeDevice, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, user_id, push_token
+		FROM user_devices
+		WHERE user_id = ANY($1::uuid[])
+		AND notifications_enabled = true
+		AND last_seen_at > NOW() - make_interval(secs => $2)
+	`, userIDs, s.staleAfter.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+	defer rows.Close()
+
+	devices := map[string][]activeDevice{}
+	for rows.Next() {
+		var d activeDevice
+		if err := rows.Scan(&d.ID, &d.UserID, &d.PushToken); err != nil {
+			log.Error().Err(err).Msg("Failed to scan device")
+			continue
+		}
+		devices[d.UserID] = append(devices[d.UserID], d)
+	}
+
+	return devices, rows.Err()
+}
+
+// removeDevice deletes a device whose token the push service rejected
+func (s *DeviceService) removeDevice(ctx context.Context, deviceID string) {
+	_, err := s.db.Pool.Exec(ctx, `DELETE FROM user_devices WHERE id = $1`, deviceID)
+	if err != nil {
+		log.Error().Err(err).Str("device_id", deviceID).Msg("Failed to remove unregistered device")
+		return
+	}
+
+	log.Info().Str("device_id", deviceID).Msg("Removed unregistered device")
+}
+
+// PruneStale deletes devices that haven't been seen within the stale period
+func (s *DeviceService) PruneStale(ctx context.Context) (int64, error) {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM user_devices WHERE last_seen_at < NOW() - make_interval(secs => $1)
+	`, s.staleAfter.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune stale devices: %w", err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		log.Info().Int64("count", tag.RowsAffected()).Msg("Pruned stale devices")
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+
+	"github.com/harrypall/havn-backend/internal/events"
+)
+
+// FriendRequestCreatedEvent is the payload of events.FriendRequestCreated
+type FriendRequestCreatedEvent struct {
+	FriendshipID string `json:"friendship_id"`
+	RequesterID  string `json:"requester_id"`
+	AddresseeID  string `json:"addressee_id"`
+}
+
+// FriendRequestRespondedEvent is the payload of events.FriendRequestResponded
+type FriendRequestRespondedEvent struct {
+	FriendshipID string `json:"friendship_id"`
+	RequesterID  string `json:"requester_id"`
+	AddresseeID  string `json:"addressee_id"`
+	Status       string `json:"status"`
+}
+
+// SpotSaveRespondedEvent is the payload of events.SpotSaveResponded
+type SpotSaveRespondedEvent struct {
+	RequestID   string `json:"request_id"`
+	RequesterID string `json:"requester_id"`
+	SaverID     string `json:"saver_id"`
+	SpotID      string `json:"spot_id"`
+	Status      string `json:"status"`
+}
+
+// PresenceChangedEvent is the payload of events.PresenceChanged
+type PresenceChangedEvent struct {
+	UserID string `json:"user_id"`
+}
+
+// publishEvent records a domain event in the outbox for reliable delivery and
+// notifies live subscribers, both as part of q, which should be the
+// transaction of the write that caused it. spot.occupancy_changed carries an
+// OccupancyEvent.
+func publishEvent(ctx context.Context, bus events.Bus, q querier, eventType, aggregateType, aggregateID string, payload interface{}) error {
+	event, err := events.NewEvent(eventType, aggregateType, aggregateID, payload)
+	if err != nil {
+		return err
+	}
+
+	if err := events.WriteOutbox(ctx, q, event); err != nil {
+		return err
+	}
+	return bus.Publish(ctx, q, event)
+}
+
+// publishPresenceChanged tells presence streams that what the given users'
+// friends can see of them may have changed. Streams recompute presence from
+// current state, so these events only go on the bus, not into the outbox.
+func publishPresenceChanged(ctx context.Context, bus events.Bus, q querier, userIDs ...string) error {
+	for _, userID := range userIDs {
+		event, err := events.NewEvent(events.PresenceChanged, events.AggregateUser, userID, PresenceChangedEvent{UserID: userID})
+		if err != nil {
+			return err
+		}
+		if err := bus.Publish(ctx, q, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
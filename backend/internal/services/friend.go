@@ -5,39 +5,63 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/harrypall/havn-backend/internal/events"
 	"github.com/harrypall/havn-backend/internal/models"
 	"github.com/harrypall/havn-backend/pkg/database"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/rs/zerolog/log"
 )
 
 // FriendService handles friendship operations
 type FriendService struct {
-	db *database.Database
+	db            *database.Database
+	limits        *LimitService
+	notifications *NotificationService
+	bus           events.Bus
 }
 
 // NewFriendService creates a new friend service
-func NewFriendService(db *database.Database) *FriendService {
-	return &FriendService{db: db}
+func NewFriendService(db *database.Database, limits *LimitService, notifications *NotificationService, bus events.Bus) *FriendService {
+	return &FriendService{db: db, limits: limits, notifications: notifications, bus: bus}
 }
 
+// Location visibility levels returned by the location_visibility() SQL function
+const (
+	VisibilityExact    = "exact"
+	VisibilityBuilding = "building"
+	VisibilityHidden   = "hidden"
+)
+
+// PrecisionSpot is the CurrentSpot precision for friends sharing their exact
+// spot; building-level friends use VisibilityBuilding
+const PrecisionSpot = "spot"
+
+// walkingSpeedMetersPerMinute is an average walking pace (~5 km/h)
+const walkingSpeedMetersPerMinute = 84.0
+
 // FriendWithLocation represents a friend with their current location
 type FriendWithLocation struct {
-	ID          string                 `json:"id"`
-	Username    string                 `json:"username"`
-	FullName    string                 `json:"full_name"`
-	AvatarURL   string                 `json:"avatar_url,omitempty"`
-	CurrentSpot *CurrentSpot           `json:"current_spot,omitempty"`
-	CheckedInAt *time.Time             `json:"checked_in_at,omitempty"`
-	DistanceFromMe float64             `json:"distance_from_me,omitempty"`
+	ID             string       `json:"id"`
+	Username       string       `json:"username"`
+	FullName       string       `json:"full_name"`
+	AvatarURL      string       `json:"avatar_url,omitempty"`
+	CurrentSpot    *CurrentSpot `json:"current_spot,omitempty"`
+	CheckedInAt    *time.Time   `json:"checked_in_at,omitempty"`
+	DistanceFromMe float64      `json:"distance_from_me,omitempty"`
+	WalkingMinutes int          `json:"walking_minutes,omitempty"`
 }
 
-// CurrentSpot represents a friend's current spot
+// CurrentSpot represents a friend's current spot. Friends who only share their
+// building have Precision "building", no spot ID/name and a coarsened building
+// centroid; they have no CurrentSpot when their building can't be coarsened.
 type CurrentSpot struct {
-	ID        string  `json:"id"`
-	Name      string  `json:"name"`
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
+	ID           string  `json:"id,omitempty"`
+	Name         string  `json:"name,omitempty"`
+	BuildingName string  `json:"building_name,omitempty"`
+	Precision    string  `json:"precision"`
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
 }
 
 // FriendRequest represents a friend request
@@ -57,27 +81,29 @@ type UserInfo struct {
 
 // FriendsResponse represents the complete friends data
 type FriendsResponse struct {
-	Friends                 []FriendWithLocation `json:"friends"`
-	FriendRequestsReceived  []FriendRequest      `json:"friend_requests_received"`
-	FriendRequestsSent      []FriendRequest      `json:"friend_requests_sent"`
+	Friends                []FriendWithLocation `json:"friends"`
+	FriendRequestsReceived []FriendRequest      `json:"friend_requests_received"`
+	FriendRequestsSent     []FriendRequest      `json:"friend_requests_sent"`
 }
 
-// GetFriends retrieves a user's friends and friend requests
-func (s *FriendService) GetFriends(ctx context.Context, userID string) (*FriendsResponse, error) {
+// GetFriends retrieves a user's friends and friend requests. If groupID is
+// set, only friends in that group (owned by the user) are returned.
+func (s *FriendService) GetFriends(ctx context.Context, userID, groupID string) (*FriendsResponse, error) {
 	response := &FriendsResponse{
 		Friends:                []FriendWithLocation{},
 		FriendRequestsReceived: []FriendRequest{},
 		FriendRequestsSent:     []FriendRequest{},
 	}
 
-	// Get accepted friends with their current location
+	// Get accepted friends with their current location, as visible to the user
 	friendsQuery := `
 		SELECT 
 			p.id, p.username, p.full_name, p.avatar_url,
-			p.current_spot_id, p.checked_in_at,
-			s.id, s.name, 
-			ST_Y(s.location::geometry) as latitude,
-			ST_X(s.location::geometry) as longitude
+			v.level,
+			CASE WHEN v.level = 'hidden' THEN NULL ELSE p.checked_in_at END AS checked_in_at,
+			s.id, s.name, s.building_name,
+			ST_Y(loc.point) as latitude,
+			ST_X(loc.point) as longitude
 		FROM friendships f
 		JOIN profiles p ON (
 			CASE 
@@ -85,12 +111,20 @@ func (s *FriendService) GetFriends(ctx context.Context, userID string) (*Friends
 				ELSE p.id = f.user_id
 			END
 		)
-		LEFT JOIN spots s ON s.id = p.current_spot_id
+		CROSS JOIN LATERAL (SELECT location_visibility(p.id, $1) AS level) v
+		LEFT JOIN spots s ON s.id = p.current_spot_id AND v.level != 'hidden'
+		LEFT JOIN LATERAL (` + friendLocationPointSQL + `) loc ON true
 		WHERE (f.user_id = $1 OR f.friend_id = $1) AND f.status = 'accepted'
-		ORDER BY p.checked_in_at DESC NULLS LAST
+		AND ($2 = '' OR p.id IN (
+			SELECT m.member_id
+			FROM friend_group_members m
+			JOIN friend_groups g ON g.id = m.group_id
+			WHERE g.owner_id = $1 AND g.id::text = $2
+		))
+		ORDER BY checked_in_at DESC NULLS LAST
 	`
 
-	rows, err := s.db.Pool.Query(ctx, friendsQuery, userID)
+	rows, err := s.db.Pool.Query(ctx, friendsQuery, userID, groupID)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to query friends")
 		return nil, fmt.Errorf("failed to get friends: %w", err)
@@ -98,43 +132,13 @@ func (s *FriendService) GetFriends(ctx context.Context, userID string) (*Friends
 	defer rows.Close()
 
 	for rows.Next() {
-		var friend FriendWithLocation
-		var currentSpotID *string
-		var checkedInAt *time.Time
-		var spotID, spotName *string
-		var spotLat, spotLon *float64
-
-		err := rows.Scan(
-			&friend.ID,
-			&friend.Username,
-			&friend.FullName,
-			&friend.AvatarURL,
-			&currentSpotID,
-			&checkedInAt,
-			&spotID,
-			&spotName,
-			&spotLat,
-			&spotLon,
-		)
-
+		friend, err := scanFriendWithLocation(rows)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to scan friend")
 			continue
 		}
 
-		friend.CheckedInAt = checkedInAt
-
-		// Add current spot if available
-		if spotID != nil && spotName != nil {
-			friend.CurrentSpot = &CurrentSpot{
-				ID:        *spotID,
-				Name:      *spotName,
-				Latitude:  *spotLat,
-				Longitude: *spotLon,
-			}
-		}
-
-		response.Friends = append(response.Friends, friend)
+		response.Friends = append(response.Friends, *friend)
 	}
 
 	// Get received friend requests
@@ -219,41 +223,98 @@ func (s *FriendService) SendRequest(ctx context.Context, userID, friendID string
 		return nil, fmt.Errorf("cannot send friend request to yourself")
 	}
 
-	// Check if friendship already exists
-	var existingID string
-	err := s.db.Pool.QueryRow(ctx, `
-		SELECT id FROM friendships
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Blocks in either direction prevent new requests. A block is a friendships
+	// row, so report it exactly like any other existing relationship and the
+	// sender can't tell they have been blocked.
+	blocked, err := blockExists(ctx, tx, userID, friendID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, fmt.Errorf("friendship already exists")
+	}
+
+	// Check if friendship already exists. A declined request may be re-sent
+	// once the decline cooldown has passed, reusing the existing row.
+	var existingID, existingStatus string
+	err = tx.QueryRow(ctx, `
+		SELECT id, status FROM friendships
 		WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
 		LIMIT 1
-	`, userID, friendID).Scan(&existingID)
+	`, userID, friendID).Scan(&existingID, &existingStatus)
 
-	if err == nil {
+	if err == nil && existingStatus != "declined" {
 		return nil, fmt.Errorf("friendship already exists")
-	} else if err != pgx.ErrNoRows {
+	} else if err != nil && err != pgx.ErrNoRows {
 		return nil, fmt.Errorf("failed to check existing friendship: %w", err)
 	}
 
+	if err := s.limits.CheckAndRecord(ctx, tx, LimitActionFriendRequest, userID, friendID); err != nil {
+		return nil, err
+	}
+
 	// Create friend request
 	var friendship models.Friendship
-	err = s.db.Pool.QueryRow(ctx, `
-		INSERT INTO friendships (user_id, friend_id, status)
-		VALUES ($1, $2, 'pending')
-		RETURNING id, user_id, friend_id, status, requested_at, created_at, updated_at
-	`, userID, friendID).Scan(
-		&friendship.ID,
-		&friendship.UserID,
-		&friendship.FriendID,
-		&friendship.Status,
-		&friendship.RequestedAt,
-		&friendship.CreatedAt,
-		&friendship.UpdatedAt,
-	)
+	if existingID != "" {
+		err = tx.QueryRow(ctx, `
+			UPDATE friendships
+			SET user_id = $1, friend_id = $2, status = 'pending',
+			    requested_at = NOW(), responded_at = NULL, updated_at = NOW()
+			WHERE id = $3
+			RETURNING id, user_id, friend_id, status, requested_at, created_at, updated_at
+		`, userID, friendID, existingID).Scan(
+			&friendship.ID,
+			&friendship.UserID,
+			&friendship.FriendID,
+			&friendship.Status,
+			&friendship.RequestedAt,
+			&friendship.CreatedAt,
+			&friendship.UpdatedAt,
+		)
+	} else {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO friendships (user_id, friend_id, status)
+			VALUES ($1, $2, 'pending')
+			RETURNING id, user_id, friend_id, status, requested_at, created_at, updated_at
+		`, userID, friendID).Scan(
+			&friendship.ID,
+			&friendship.UserID,
+			&friendship.FriendID,
+			&friendship.Status,
+			&friendship.RequestedAt,
+			&friendship.CreatedAt,
+			&friendship.UpdatedAt,
+		)
+	}
 
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create friend request")
 		return nil, fmt.Errorf("failed to send friend request: %w", err)
 	}
 
+	if err := s.notifications.NotifyFriendRequest(ctx, tx, userID, friendID, friendship.ID); err != nil {
+		return nil, err
+	}
+
+	err = publishEvent(ctx, s.bus, tx, events.FriendRequestCreated, events.AggregateFriendship, friendship.ID, FriendRequestCreatedEvent{
+		FriendshipID: friendship.ID,
+		RequesterID:  userID,
+		AddresseeID:  friendID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	log.Info().
 		Str("user_id", userID).
 		Str("friend_id", friendID).
@@ -270,11 +331,11 @@ func (s *FriendService) RespondToRequest(ctx context.Context, friendshipID, user
 	}
 
 	// Verify the user is the friend_id (recipient) of the request
-	var friendID string
+	var requesterID, friendID string
 	err := s.db.Pool.QueryRow(ctx, `
-		SELECT friend_id FROM friendships
+		SELECT user_id, friend_id FROM friendships
 		WHERE id = $1 AND status = 'pending'
-	`, friendshipID).Scan(&friendID)
+	`, friendshipID).Scan(&requesterID, &friendID)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -287,8 +348,14 @@ func (s *FriendService) RespondToRequest(ctx context.Context, friendshipID, user
 		return fmt.Errorf("unauthorized: you are not the recipient of this request")
 	}
 
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	// Update friendship status
-	_, err = s.db.Pool.Exec(ctx, `
+	_, err = tx.Exec(ctx, `
 		UPDATE friendships
 		SET status = $1, responded_at = NOW(), updated_at = NOW()
 		WHERE id = $2
@@ -299,11 +366,340 @@ func (s *FriendService) RespondToRequest(ctx context.Context, friendshipID, user
 		return fmt.Errorf("failed to respond to friend request: %w", err)
 	}
 
+	err = publishEvent(ctx, s.bus, tx, events.FriendRequestResponded, events.AggregateFriendship, friendshipID, FriendRequestRespondedEvent{
+		FriendshipID: friendshipID,
+		RequesterID:  requesterID,
+		AddresseeID:  friendID,
+		Status:       response,
+	})
+	if err != nil {
+		return err
+	}
+
+	if response == "accepted" {
+		if err := publishPresenceChanged(ctx, s.bus, tx, requesterID, friendID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	log.Info().
 		Str("friendship_id", friendshipID).
 		Str("response", response).
 		Msg("Friend request responded")
 
+	// Declines are silent so the requester isn't told they were turned down
+	if response == "accepted" {
+		if err := s.notifications.NotifyFriendAccepted(ctx, s.db.Pool, userID, requesterID, friendshipID); err != nil {
+			log.Error().Err(err).Str("friendship_id", friendshipID).Msg("Failed to notify friend request accepted")
+		}
+	}
+
 	return nil
 }
 
+// RemoveFriend removes an accepted friendship between two users
+func (s *FriendService) RemoveFriend(ctx context.Context, userID, friendID string) error {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM friendships
+		WHERE ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+		AND status = 'accepted'
+	`, userID, friendID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to remove friendship")
+		return fmt.Errorf("failed to remove friend: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("friendship not found")
+	}
+
+	if err := cancelPendingSpotSaves(ctx, tx, userID, friendID); err != nil {
+		return err
+	}
+
+	if err := revokeSharesBetween(ctx, tx, userID, friendID); err != nil {
+		return err
+	}
+
+	if err := removeGroupMembershipsBetween(ctx, tx, userID, friendID); err != nil {
+		return err
+	}
+
+	if err := publishPresenceChanged(ctx, s.bus, tx, userID, friendID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("friend_id", friendID).
+		Msg("Friend removed")
+
+	return nil
+}
+
+// CancelRequest withdraws a pending friend request sent by the user
+func (s *FriendService) CancelRequest(ctx context.Context, friendshipID, userID string) error {
+	var senderID string
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT user_id FROM friendships
+		WHERE id = $1 AND status = 'pending'
+	`, friendshipID).Scan(&senderID)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("friend request not found or already responded")
+		}
+		return fmt.Errorf("failed to find friend request: %w", err)
+	}
+
+	if senderID != userID {
+		return fmt.Errorf("unauthorized: you are not the sender of this request")
+	}
+
+	_, err = s.db.Pool.Exec(ctx, `
+		DELETE FROM friendships WHERE id = $1 AND status = 'pending'
+	`, friendshipID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to cancel friend request")
+		return fmt.Errorf("failed to cancel friend request: %w", err)
+	}
+
+	log.Info().
+		Str("friendship_id", friendshipID).
+		Str("user_id", userID).
+		Msg("Friend request cancelled")
+
+	return nil
+}
+
+// Block blocks another user, removing any existing friendship or pending
+// request between them. A block held by the other user is left untouched.
+func (s *FriendService) Block(ctx context.Context, userID, targetID string) error {
+	if userID == targetID {
+		return fmt.Errorf("cannot block yourself")
+	}
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM profiles WHERE id = $1)`, targetID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("user not found")
+	}
+
+	// Remove any friendship or request in either direction
+	_, err = tx.Exec(ctx, `
+		DELETE FROM friendships
+		WHERE ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+		AND status != 'blocked'
+	`, userID, targetID)
+
+	if err != nil {
+		return fmt.Errorf("failed to remove friendship: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO friendships (user_id, friend_id, status, responded_at)
+		VALUES ($1, $2, 'blocked', NOW())
+		ON CONFLICT (user_id, friend_id)
+		DO UPDATE SET status = 'blocked', responded_at = NOW(), updated_at = NOW()
+	`, userID, targetID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to block user")
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+
+	if err := cancelPendingSpotSaves(ctx, tx, userID, targetID); err != nil {
+		return err
+	}
+
+	if err := revokeSharesBetween(ctx, tx, userID, targetID); err != nil {
+		return err
+	}
+
+	if err := removeGroupMembershipsBetween(ctx, tx, userID, targetID); err != nil {
+		return err
+	}
+
+	if err := publishPresenceChanged(ctx, s.bus, tx, userID, targetID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("blocked_id", targetID).
+		Msg("User blocked")
+
+	return nil
+}
+
+// Unblock removes a block the user previously placed on another user
+func (s *FriendService) Unblock(ctx context.Context, userID, targetID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM friendships
+		WHERE user_id = $1 AND friend_id = $2 AND status = 'blocked'
+	`, userID, targetID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to unblock user")
+		return fmt.Errorf("failed to unblock user: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("block not found")
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("unblocked_id", targetID).
+		Msg("User unblocked")
+
+	return nil
+}
+
+// querier is satisfied by both the connection pool and transactions
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// blockExists reports whether either user has blocked the other
+func blockExists(ctx context.Context, q querier, userID, otherID string) (bool, error) {
+	var blocked bool
+	err := q.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM friendships
+			WHERE ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+			AND status = 'blocked'
+		)
+	`, userID, otherID).Scan(&blocked)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to check block: %w", err)
+	}
+	return blocked, nil
+}
+
+// cancelPendingSpotSaves cancels outstanding spot save requests between two users
+func cancelPendingSpotSaves(ctx context.Context, q querier, userID, otherID string) error {
+	_, err := q.Exec(ctx, `
+		UPDATE spot_save_requests
+		SET status = 'cancelled', updated_at = NOW()
+		WHERE ((requester_id = $1 AND saver_id = $2) OR (requester_id = $2 AND saver_id = $1))
+		AND status = 'pending'
+	`, userID, otherID)
+
+	if err != nil {
+		return fmt.Errorf("failed to cancel spot save requests: %w", err)
+	}
+	return nil
+}
+
+// CreateFriendship makes two users friends immediately, without a pending
+// request. Existing pending or declined rows are accepted in place. It runs
+// inside the caller's transaction so it can be combined with other writes.
+func (s *FriendService) CreateFriendship(ctx context.Context, tx pgx.Tx, userID, friendID string) (*models.Friendship, error) {
+	if userID == friendID {
+		return nil, fmt.Errorf("cannot add yourself as a friend")
+	}
+
+	blocked, err := blockExists(ctx, tx, userID, friendID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, fmt.Errorf("cannot add this user as a friend")
+	}
+
+	var existingID, existingStatus string
+	err = tx.QueryRow(ctx, `
+		SELECT id, status FROM friendships
+		WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
+		LIMIT 1
+		FOR UPDATE
+	`, userID, friendID).Scan(&existingID, &existingStatus)
+
+	if err == nil && existingStatus == "accepted" {
+		return nil, fmt.Errorf("already friends")
+	} else if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to check existing friendship: %w", err)
+	}
+
+	var friendship models.Friendship
+	if existingID != "" {
+		err = tx.QueryRow(ctx, `
+			UPDATE friendships
+			SET status = 'accepted', responded_at = NOW(), updated_at = NOW()
+			WHERE id = $1
+			RETURNING id, user_id, friend_id, status, requested_at, responded_at, created_at, updated_at
+		`, existingID).Scan(
+			&friendship.ID,
+			&friendship.UserID,
+			&friendship.FriendID,
+			&friendship.Status,
+			&friendship.RequestedAt,
+			&friendship.RespondedAt,
+			&friendship.CreatedAt,
+			&friendship.UpdatedAt,
+		)
+	} else {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO friendships (user_id, friend_id, status, responded_at)
+			VALUES ($1, $2, 'accepted', NOW())
+			RETURNING id, user_id, friend_id, status, requested_at, responded_at, created_at, updated_at
+		`, userID, friendID).Scan(
+			&friendship.ID,
+			&friendship.UserID,
+			&friendship.FriendID,
+			&friendship.Status,
+			&friendship.RequestedAt,
+			&friendship.RespondedAt,
+			&friendship.CreatedAt,
+			&friendship.UpdatedAt,
+		)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create friendship")
+		return nil, fmt.Errorf("failed to create friendship: %w", err)
+	}
+
+	if err := publishPresenceChanged(ctx, s.bus, tx, userID, friendID); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("friend_id", friendID).
+		Msg("Friendship created")
+
+	return &friendship, nil
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/harrypall/havn-backend/internal/events"
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+// FriendGroupService handles custom friend groups
+type FriendGroupService struct {
+	db  *database.Database
+	bus events.Bus
+}
+
+// NewFriendGroupService creates a new friend group service
+func NewFriendGroupService(db *database.Database, bus events.Bus) *FriendGroupService {
+	return &FriendGroupService{db: db, bus: bus}
+}
+
+// validGroupVisibility lists the accepted values for friend_groups.visibility
+var validGroupVisibility = map[string]bool{
+	"default":          true,
+	VisibilityExact:    true,
+	VisibilityBuilding: true,
+	VisibilityHidden:   true,
+}
+
+// FriendGroupWithMembers represents a group and its members
+type FriendGroupWithMembers struct {
+	models.FriendGroup
+	Members []UserInfo `json:"members"`
+}
+
+// GroupUpdate holds optional changes to a group; nil fields are left unchanged
+type GroupUpdate struct {
+	Name               *string `json:"name"`
+	Visibility         *string `json:"visibility"`
+	NotificationsMuted *bool   `json:"notifications_muted"`
+}
+
+// GetGroups retrieves all groups owned by the user with their members
+func (s *FriendGroupService) GetGroups(ctx context.Context, ownerID string) ([]FriendGroupWithMembers, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, owner_id, name, visibility, notifications_muted, created_at, updated_at
+		FROM friend_groups
+		WHERE owner_id = $1
+		ORDER BY name
+	`, ownerID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query friend groups")
+		return nil, fmt.Errorf("failed to get groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []FriendGroupWithMembers{}
+	index := map[string]int{}
+	for rows.Next() {
+		var group FriendGroupWithMembers
+		err := rows.Scan(
+			&group.ID,
+			&group.OwnerID,
+			&group.Name,
+			&group.Visibility,
+			&group.NotificationsMuted,
+			&group.CreatedAt,
+			&group.UpdatedAt,
+		)
+
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan friend group")
+			continue
+		}
+
+		group.Members = []UserInfo{}
+		index[group.ID] = len(groups)
+		groups = append(groups, group)
+	}
+
+	memberRows, err := s.db.Pool.Query(ctx, `
+		SELECT m.group_id, p.id, p.username, p.full_name, p.avatar_url
+		FROM friend_group_members m
+		JOIN friend_groups g ON g.id = m.group_id
+		JOIN profiles p ON p.id = m.member_id
+		WHERE g.owner_id = $1
+		ORDER BY p.username
+	`, ownerID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query friend group members")
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	defer memberRows.Close()
+
+	for memberRows.Next() {
+		var groupID string
+		var member UserInfo
+		err := memberRows.Scan(&groupID, &member.ID, &member.Username, &member.FullName, &member.AvatarURL)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan friend group member")
+			continue
+		}
+
+		if i, ok := index[groupID]; ok {
+			groups[i].Members = append(groups[i].Members, member)
+		}
+	}
+
+	return groups, nil
+}
+
+// CreateGroup creates a new friend group
+func (s *FriendGroupService) CreateGroup(ctx context.Context, ownerID, name, visibility string, muted bool) (*models.FriendGroup, error) {
+	if visibility == "" {
+		visibility = "default"
+	}
+	if !validGroupVisibility[visibility] {
+		return nil, fmt.Errorf("invalid visibility")
+	}
+
+	var group models.FriendGroup
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO friend_groups (owner_id, name, visibility, notifications_muted)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, owner_id, name, visibility, notifications_muted, created_at, updated_at
+	`, ownerID, name, visibility, muted).Scan(
+		&group.ID,
+		&group.OwnerID,
+		&group.Name,
+		&group.Visibility,
+		&group.NotificationsMuted,
+		&group.CreatedAt,
+		&group.UpdatedAt,
+	)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("group name already exists")
+		}
+		log.Error().Err(err).Msg("Failed to create friend group")
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	log.Info().Str("owner_id", ownerID).Str("group_id", group.ID).Msg("Friend group created")
+	return &group, nil
+}
+
+// UpdateGroup renames a group or changes its visibility or mute setting
+func (s *FriendGroupService) UpdateGroup(ctx context.Context, ownerID, groupID string, update GroupUpdate) (*models.FriendGroup, error) {
+	if update.Visibility != nil && !validGroupVisibility[*update.Visibility] {
+		return nil, fmt.Errorf("invalid visibility")
+	}
+
+	var group models.FriendGroup
+	err := s.db.Pool.QueryRow(ctx, `
+		UPDATE friend_groups
+		SET name = COALESCE($3, name),
+		    visibility = COALESCE($4, visibility),
+		    notifications_muted = COALESCE($5, notifications_muted),
+		    updated_at = NOW()
+		WHERE id = $1 AND owner_id = $2
+		RETURNING id, owner_id, name, visibility, notifications_muted, created_at, updated_at
+	`, groupID, ownerID, update.Name, update.Visibility, update.NotificationsMuted).Scan(
+		&group.ID,
+		&group.OwnerID,
+		&group.Name,
+		&group.Visibility,
+		&group.NotificationsMuted,
+		&group.CreatedAt,
+		&group.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("group not found")
+		}
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("group name already exists")
+		}
+		log.Error().Err(err).Msg("Failed to update friend group")
+		return nil, fmt.Errorf("failed to update group: %w", err)
+	}
+
+	if update.Visibility != nil {
+		s.presenceChanged(ctx, ownerID)
+	}
+
+	return &group, nil
+}
+
+// DeleteGroup deletes a group. Friendships with its members are unaffected.
+func (s *FriendGroupService) DeleteGroup(ctx context.Context, ownerID, groupID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM friend_groups WHERE id = $1 AND owner_id = $2
+	`, groupID, ownerID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete friend group")
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("group not found")
+	}
+
+	s.presenceChanged(ctx, ownerID)
+
+	log.Info().Str("owner_id", ownerID).Str("group_id", groupID).Msg("Friend group deleted")
+	return nil
+}
+
+// AddMember adds an accepted friend to one of the owner's groups
+func (s *FriendGroupService) AddMember(ctx context.Context, ownerID, groupID, friendID string) error {
+	if err := s.checkOwner(ctx, ownerID, groupID); err != nil {
+		return err
+	}
+
+	var friendshipID string
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT id FROM friendships
+		WHERE ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+		AND status = 'accepted'
+		LIMIT 1
+	`, ownerID, friendID).Scan(&friendshipID)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("user is not your friend")
+		}
+		return fmt.Errorf("failed to check friendship: %w", err)
+	}
+
+	_, err = s.db.Pool.Exec(ctx, `
+		INSERT INTO friend_group_members (group_id, member_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, groupID, friendID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to add friend group member")
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+
+	s.presenceChanged(ctx, ownerID)
+
+	return nil
+}
+
+// RemoveMember removes a friend from one of the owner's groups
+func (s *FriendGroupService) RemoveMember(ctx context.Context, ownerID, groupID, friendID string) error {
+	if err := s.checkOwner(ctx, ownerID, groupID); err != nil {
+		return err
+	}
+
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM friend_group_members WHERE group_id = $1 AND member_id = $2
+	`, groupID, friendID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to remove friend group member")
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("member not found")
+	}
+
+	s.presenceChanged(ctx, ownerID)
+
+	return nil
+}
+
+// GetMemberIDs returns the IDs of the members of one of the owner's groups
+func (s *FriendGroupService) GetMemberIDs(ctx context.Context, ownerID, groupID string) ([]string, error) {
+	if err := s.checkOwner(ctx, ownerID, groupID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT member_id FROM friend_group_members WHERE group_id = $1
+	`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Error().Err(err).Msg("Failed to scan group member")
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// checkOwner verifies the group exists and belongs to ownerID
+func (s *FriendGroupService) checkOwner(ctx context.Context, ownerID, groupID string) error {
+	var groupOwnerID string
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT owner_id FROM friend_groups WHERE id = $1
+	`, groupID).Scan(&groupOwnerID)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("group not found")
+		}
+		return fmt.Errorf("failed to find group: %w", err)
+	}
+
+	// Report other users' groups as missing rather than leaking their existence
+	if groupOwnerID != ownerID {
+		return fmt.Errorf("group not found")
+	}
+
+	return nil
+}
+
+// removeGroupMembershipsBetween removes each user from the other's groups
+func removeGroupMembershipsBetween(ctx context.Context, q querier, userID, otherID string) error {
+	_, err := q.Exec(ctx, `
+		DELETE FROM friend_group_members m
+		USING friend_groups g
+		WHERE g.id = m.group_id
+		AND ((g.owner_id = $1 AND m.member_id = $2) OR (g.owner_id = $2 AND m.member_id = $1))
+	`, userID, otherID)
+
+	if err != nil {
+		return fmt.Errorf("failed to remove group memberships: %w", err)
+	}
+	return nil
+}
+
+// presenceChanged publishes a presence change for the owner, since group
+// visibility decides what members see of them. Failures are logged; presence
+// streams resync periodically.
+func (s *FriendGroupService) presenceChanged(ctx context.Context, ownerID string) {
+	if err := publishPresenceChanged(ctx, s.bus, s.db.Pool, ownerID); err != nil {
+		log.Error().Err(err).Str("owner_id", ownerID).Msg("Failed to publish presence change")
+	}
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err is a Postgres foreign key violation
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
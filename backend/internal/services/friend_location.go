@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Building-level locations are only shown for buildings with at least
+// buildingMinSpots spots, snapped to a grid of buildingGridDegrees (~100m), so
+// the point can't be traced back to a single spot
+const (
+	buildingMinSpots    = 3
+	buildingGridDegrees = 0.001
+)
+
+// friendLocationPointSQL resolves the point shown for a friend at spot s with
+// visibility v.level: the spot itself, or the coarsened centroid of every spot
+// in the same building when only building-level location is shared. Spots
+// without a building, or in too small a building, resolve to NULL.
+var friendLocationPointSQL = fmt.Sprintf(`
+	SELECT CASE
+		WHEN v.level = 'building' THEN (
+			SELECT ST_SnapToGrid(ST_Centroid(ST_Collect(b.location::geometry)), %[2]g)
+			FROM spots b
+			WHERE b.building_name = s.building_name
+			HAVING COUNT(*) >= %[1]d
+		)
+		ELSE s.location::geometry
+	END AS point
+`, buildingMinSpots, buildingGridDegrees)
+
+// scanFriendWithLocation scans a row of (id, username, full_name, avatar_url,
+// visibility, checked_in_at, spot id, spot name, building name, latitude, longitude)
+func scanFriendWithLocation(rows pgx.Rows, extra ...any) (*FriendWithLocation, error) {
+	var friend FriendWithLocation
+	var level string
+	var spotID, spotName, buildingName *string
+	var spotLat, spotLon *float64
+
+	dest := []any{
+		&friend.ID,
+		&friend.Username,
+		&friend.FullName,
+		&friend.AvatarURL,
+		&level,
+		&friend.CheckedInAt,
+		&spotID,
+		&spotName,
+		&buildingName,
+		&spotLat,
+		&spotLon,
+	}
+
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	// Add current spot if visible
+	if spotID == nil || spotLat == nil || spotLon == nil {
+		return &friend, nil
+	}
+
+	friend.CurrentSpot = &CurrentSpot{
+		Latitude:  *spotLat,
+		Longitude: *spotLon,
+	}
+	if buildingName != nil {
+		friend.CurrentSpot.BuildingName = *buildingName
+	}
+
+	if level == VisibilityBuilding {
+		friend.CurrentSpot.Precision = VisibilityBuilding
+	} else {
+		friend.CurrentSpot.Precision = PrecisionSpot
+		friend.CurrentSpot.ID = *spotID
+		if spotName != nil {
+			friend.CurrentSpot.Name = *spotName
+		}
+	}
+
+	return &friend, nil
+}
+
+// GetNearbyFriends retrieves checked-in friends within radius meters of the
+// given point, closest first. Distances to friends who only share their
+// building are measured to the building and rounded to the nearest 50m.
+func (s *FriendService) GetNearbyFriends(ctx context.Context, userID string, lat, lon float64, radius int) ([]FriendWithLocation, error) {
+	query := `
+		SELECT 
+			p.id, p.username, p.full_name, p.avatar_url,
+			v.level, p.checked_in_at,
+			s.id, s.name, s.building_name,
+			ST_Y(loc.point) as latitude,
+			ST_X(loc.point) as longitude,
+			ST_Distance(loc.point::geography, ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography) as distance_meters
+		FROM friendships f
+		JOIN profiles p ON (
+			CASE 
+				WHEN f.user_id = $1 THEN p.id = f.friend_id
+				ELSE p.id = f.user_id
+			END
+		)
+		CROSS JOIN LATERAL (SELECT location_visibility(p.id, $1) AS level) v
+		JOIN spots s ON s.id = p.current_spot_id
+		CROSS JOIN LATERAL (` + friendLocationPointSQL + `) loc
+		WHERE (f.user_id = $1 OR f.friend_id = $1) AND f.status = 'accepted'
+		AND v.level != 'hidden'
+		AND ST_DWithin(
+			loc.point::geography,
+			ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography,
+			$4
+		)
+		ORDER BY distance_meters
+		LIMIT 100
+	`
+
+	rows, err := s.db.Pool.Query(ctx, query, userID, lon, lat, radius)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query nearby friends")
+		return nil, fmt.Errorf("failed to get nearby friends: %w", err)
+	}
+	defer rows.Close()
+
+	friends := []FriendWithLocation{}
+	for rows.Next() {
+		var distance float64
+		friend, err := scanFriendWithLocation(rows, &distance)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan nearby friend")
+			continue
+		}
+
+		if friend.CurrentSpot == nil {
+			continue
+		}
+
+		if friend.CurrentSpot.Precision == VisibilityBuilding {
+			distance = math.Round(distance/50) * 50
+		}
+
+		friend.DistanceFromMe = math.Round(distance)
+		friend.WalkingMinutes = walkingMinutes(distance)
+		friends = append(friends, *friend)
+	}
+
+	return friends, nil
+}
+
+// walkingMinutes estimates walking time for a distance, rounded up to the next minute
+func walkingMinutes(distanceMeters float64) int {
+	minutes := int(math.Ceil(distanceMeters / walkingSpeedMetersPerMinute))
+	if minutes < 1 {
+		return 1
+	}
+	return minutes
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FriendSuggestion represents a suggested friend with the signals behind the suggestion
+type FriendSuggestion struct {
+	User               UserInfo `json:"user"`
+	Major              string   `json:"major,omitempty"`
+	GraduationYear     int      `json:"graduation_year,omitempty"`
+	MutualFriends      int      `json:"mutual_friends"`
+	SameMajor          bool     `json:"same_major"`
+	SameGraduationYear bool     `json:"same_graduation_year"`
+	CoLocations        int      `json:"co_locations"`
+	Score              float64  `json:"score"`
+}
+
+// GetSuggestions ranks users the caller has no relationship with by mutual friends,
+// shared major / graduation year and how often they studied at the same spot at
+// overlapping times. Anyone with an existing friendship row (pending, accepted,
+// declined or blocked, in either direction) is excluded.
+func (s *FriendService) GetSuggestions(ctx context.Context, userID string, limit int) ([]FriendSuggestion, error) {
+	query := `
+		WITH me AS (
+			SELECT major, graduation_year, colocation_suggestions
+			FROM profiles WHERE id = $1
+		),
+		related AS (
+			SELECT CASE WHEN user_id = $1 THEN friend_id ELSE user_id END AS id
+			FROM friendships
+			WHERE user_id = $1 OR friend_id = $1
+		),
+		my_friends AS (
+			SELECT CASE WHEN user_id = $1 THEN friend_id ELSE user_id END AS id
+			FROM friendships
+			WHERE (user_id = $1 OR friend_id = $1) AND status = 'accepted'
+		),
+		mutuals AS (
+			SELECT
+				CASE WHEN f.user_id = mf.id THEN f.friend_id ELSE f.user_id END AS id,
+				COUNT(*) AS mutual_count
+			FROM friendships f
+			JOIN my_friends mf ON f.user_id = mf.id OR f.friend_id = mf.id
+			WHERE f.status = 'accepted'
+			GROUP BY 1
+		),
+		colocations AS (
+			SELECT other.user_id AS id, COUNT(*) AS colocation_count
+			FROM occupancy_logs mine
+			JOIN occupancy_logs other ON other.spot_id = mine.spot_id
+				AND other.user_id != mine.user_id
+				AND other.checked_in_at < COALESCE(mine.checked_out_at, NOW())
+				AND COALESCE(other.checked_out_at, NOW()) > mine.checked_in_at
+			WHERE mine.user_id = $1
+			AND mine.checked_in_at > NOW() - INTERVAL '60 days'
+			AND (SELECT colocation_suggestions FROM me)
+			GROUP BY other.user_id
+		),
+		candidates AS (
+			SELECT
+				p.id,
+				COALESCE(p.username, '') AS username,
+				COALESCE(p.full_name, '') AS full_name,
+				COALESCE(p.avatar_url, '') AS avatar_url,
+				COALESCE(p.major, '') AS major,
+				COALESCE(p.graduation_year, 0) AS graduation_year,
+				COALESCE(m.mutual_count, 0) AS mutual_count,
+				COALESCE(me.major <> '' AND p.major = me.major, false) AS same_major,
+				COALESCE(p.graduation_year = me.graduation_year, false) AS same_year,
+				CASE WHEN p.colocation_suggestions THEN COALESCE(c.colocation_count, 0) ELSE 0 END AS colocation_count
+			FROM profiles p
+			CROSS JOIN me
+			LEFT JOIN mutuals m ON m.id = p.id
+			LEFT JOIN colocations c ON c.id = p.id
+			WHERE p.id != $1
+			AND p.id NOT IN (SELECT id FROM related)
+		)
+		SELECT
+			id, username, full_name, avatar_url, major, graduation_year,
+			mutual_count, same_major, same_year, colocation_count,
+			mutual_count * 3.0
+				+ CASE WHEN same_major THEN 2.0 ELSE 0 END
+				+ CASE WHEN same_year THEN 1.0 ELSE 0 END
+				+ LEAST(colocation_count, 5) * 1.5 AS score
+		FROM candidates
+		WHERE mutual_count > 0 OR same_major OR same_year OR colocation_count > 0
+		ORDER BY score DESC, mutual_count DESC, username
+		LIMIT $2
+	`
+
+	rows, err := s.db.Pool.Query(ctx, query, userID, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query friend suggestions")
+		return nil, fmt.Errorf("failed to get friend suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	suggestions := []FriendSuggestion{}
+	for rows.Next() {
+		var suggestion FriendSuggestion
+		err := rows.Scan(
+			&suggestion.User.ID,
+			&suggestion.User.Username,
+			&suggestion.User.FullName,
+			&suggestion.User.AvatarURL,
+			&suggestion.Major,
+			&suggestion.GraduationYear,
+			&suggestion.MutualFriends,
+			&suggestion.SameMajor,
+			&suggestion.SameGraduationYear,
+			&suggestion.CoLocations,
+			&suggestion.Score,
+		)
+
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan friend suggestion")
+			continue
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, nil
+}
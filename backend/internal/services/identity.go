@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxUsernameLength matches profiles.username
+	maxUsernameLength = 20
+
+	// usernameAttempts is how many suffixed usernames are tried before
+	// falling back to a random one
+	usernameAttempts = 5
+
+	// identityCacheSize bounds the in-memory subject -> profile cache
+	identityCacheSize = 10000
+)
+
+// IdentityService maps token subjects to profiles, provisioning a profile the
+// first time a subject is seen
+type IdentityService struct {
+	db  *database.Database
+	ttl time.Duration
+
+	mu    sync.RWMutex
+	cache map[string]cachedIdentity
+}
+
+type cachedIdentity struct {
+	userID    string
+	expiresAt time.Time
+}
+
+// NewIdentityService creates a new identity service
+func NewIdentityService(db *database.Database) *IdentityService {
+	return &IdentityService{
+		db:    db,
+		ttl:   envDuration("IDENTITY_CACHE_TTL", 10*time.Minute),
+		cache: map[string]cachedIdentity{},
+	}
+}
+
+// ResolveIdentity returns the profile id linked to the token's issuer and
+// subject, creating the profile and link on first sign-in
+func (s *IdentityService) ResolveIdentity(ctx context.Context, claims models.IdentityClaims) (string, error) {
+	if claims.Issuer == "" || claims.Subject == "" {
+		return "", fmt.Errorf("issuer and subject are required")
+	}
+
+	key := claims.Issuer + "\x00" + claims.Subject
+	if userID, ok := s.cached(key); ok {
+		return userID, nil
+	}
+
+	userID, err := s.touch(ctx, claims)
+	if err == pgx.ErrNoRows {
+		userID, err = s.provision(ctx, claims)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s.store(key, userID)
+	return userID, nil
+}
+
+// touch looks up an existing link and records the sign-in
+func (s *IdentityService) touch(ctx context.Context, claims models.IdentityClaims) (string, error) {
+	var userID string
+	err := s.db.Pool.QueryRow(ctx, `
+		UPDATE auth_identities
+		SET last_seen_at = NOW(), email = COALESCE(NULLIF($3, ''), email)
+		WHERE issuer = $1 AND subject = $2
+		RETURNING user_id
+	`, claims.Issuer, claims.Subject, claims.Email).Scan(&userID)
+
+	if err != nil && err != pgx.ErrNoRows {
+		return "", fmt.Errorf("failed to look up identity: %w", err)
+	}
+	return userID, err
+}
+
+// provision links a new subject to its profile, creating the profile unless
+// the issuer vouches for an existing one
+func (s *IdentityService) provision(ctx context.Context, claims models.IdentityClaims) (string, error) {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Concurrent first requests for the same subject wait here, then find
+	// the link the first one created
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1 || ' ' || $2, 0))`,
+		claims.Issuer, claims.Subject)
+	if err != nil {
+		return "", fmt.Errorf("failed to lock identity: %w", err)
+	}
+
+	var userID string
+	err = tx.QueryRow(ctx, `
+		SELECT user_id FROM auth_identities WHERE issuer = $1 AND subject = $2
+	`, claims.Issuer, claims.Subject).Scan(&userID)
+	if err == nil {
+		return userID, nil
+	}
+	if err != pgx.ErrNoRows {
+		return "", fmt.Errorf("failed to look up identity: %w", err)
+	}
+
+	username := ""
+	if IsUUID(claims.ProfileID) {
+		err = tx.QueryRow(ctx, `SELECT id, username FROM profiles WHERE id = $1`, claims.ProfileID).Scan(&userID, &username)
+		if err != nil && err != pgx.ErrNoRows {
+			return "", fmt.Errorf("failed to look up profile: %w", err)
+		}
+	}
+
+	if userID == "" {
+		username, userID, err = insertProvisionedProfile(ctx, tx, claims)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO auth_identities (issuer, subject, user_id, email)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+	`, claims.Issuer, claims.Subject, userID, claims.Email)
+	if err != nil {
+		return "", fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("issuer", claims.Issuer).
+		Str("username", username).
+		Msg("Identity linked")
+
+	return userID, nil
+}
+
+// insertProvisionedProfile creates the profile under the first free username
+// derived from the claims
+func insertProvisionedProfile(ctx context.Context, tx pgx.Tx, claims models.IdentityClaims) (string, string, error) {
+	base := usernameBase(claims)
+
+	for attempt := 0; attempt <= usernameAttempts+1; attempt++ {
+		var username string
+		switch {
+		case attempt == 0:
+			username = base
+		case attempt <= usernameAttempts:
+			username = fmt.Sprintf("%s_%s", base, randomHex(2))
+		default:
+			username = "user_" + randomHex(6)
+		}
+
+		var userID string
+		err := tx.QueryRow(ctx, `
+			INSERT INTO profiles (username, full_name, avatar_url)
+			VALUES ($1, NULLIF($2, ''), NULLIF($3, ''))
+			ON CONFLICT (username) DO NOTHING
+			RETURNING id
+		`, username, truncate(claims.Name, 100), claims.Picture).Scan(&userID)
+
+		if err == nil {
+			return username, userID, nil
+		}
+		if err != pgx.ErrNoRows {
+			return "", "", fmt.Errorf("failed to create profile: %w", err)
+		}
+	}
+
+	return "", "", fmt.Errorf("failed to find a free username")
+}
+
+// usernameBase derives a username from the nickname or email claims, leaving
+// room for a "_xxxx" suffix
+func usernameBase(claims models.IdentityClaims) string {
+	for _, candidate := range []string{claims.Nickname, emailLocalPart(claims.Email)} {
+		if name := sanitizeUsername(candidate); len(name) >= 3 {
+			return name
+		}
+	}
+	return "user"
+}
+
+// sanitizeUsername lowercases s and keeps letters, digits and single
+// underscores
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '_' || r == '.' || r == '-' || r == ' ' || r == '+':
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+		}
+	}
+
+	name := strings.Trim(b.String(), "_")
+	if len(name) > maxUsernameLength-5 {
+		name = strings.TrimRight(name[:maxUsernameLength-5], "_")
+	}
+	return name
+}
+
+func emailLocalPart(email string) string {
+	local, _, _ := strings.Cut(email, "@")
+	return local
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// IsUUID reports whether s is a hyphenated UUID
+func IsUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, r := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+func (s *IdentityService) cached(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.cache[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return "", false
+	}
+	return entry.userID, true
+}
+
+func (s *IdentityService) store(key, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Dropping everything keeps the bound simple; entries are cheap to refill
+	if len(s.cache) >= identityCacheSize {
+		s.cache = map[string]cachedIdentity{}
+	}
+	s.cache[key] = cachedIdentity{userID: userID, expiresAt: time.Now().Add(s.ttl)}
+}
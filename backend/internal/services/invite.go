@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"github.com/skip2/go-qrcode"
+)
+
+// InviteService mints and redeems signed friend invite tokens
+type InviteService struct {
+	db            *database.Database
+	friends       *FriendService
+	notifications *NotificationService
+	secret        []byte
+	baseURL       string
+}
+
+// NewInviteService creates a new invite service. Tokens are signed with secret
+// and links are built by appending the token to baseURL.
+func NewInviteService(db *database.Database, friends *FriendService, notifications *NotificationService, secret []byte, baseURL string) *InviteService {
+	return &InviteService{db: db, friends: friends, notifications: notifications, secret: secret, baseURL: baseURL}
+}
+
+// invitePayload is the signed part of an invite token
+type invitePayload struct {
+	InviteID  string `json:"i"`
+	InviterID string `json:"u"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// CreateInvite mints a new invite token for inviterID
+func (s *InviteService) CreateInvite(ctx context.Context, inviterID string, maxUses int, ttl time.Duration) (*models.FriendInvite, error) {
+	var invite models.FriendInvite
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO friend_invites (inviter_id, max_uses, expires_at)
+		VALUES ($1, $2, NOW() + make_interval(secs => $3))
+		RETURNING id, inviter_id, max_uses, use_count, expires_at, created_at
+	`, inviterID, maxUses, ttl.Seconds()).Scan(
+		&invite.ID,
+		&invite.InviterID,
+		&invite.MaxUses,
+		&invite.UseCount,
+		&invite.ExpiresAt,
+		&invite.CreatedAt,
+	)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create friend invite")
+		return nil, fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	token, err := s.sign(invitePayload{
+		InviteID:  invite.ID,
+		InviterID: invite.InviterID,
+		ExpiresAt: invite.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invite.Token = token
+	invite.URL = s.baseURL + token
+
+	log.Info().
+		Str("invite_id", invite.ID).
+		Str("inviter_id", inviterID).
+		Int("max_uses", maxUses).
+		Msg("Friend invite created")
+
+	return &invite, nil
+}
+
+// RevokeInvite revokes an invite so it can no longer be redeemed
+func (s *InviteService) RevokeInvite(ctx context.Context, inviteID, userID string) error {
+	var inviterID string
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT inviter_id FROM friend_invites WHERE id = $1
+	`, inviteID).Scan(&inviterID)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("invite not found")
+		}
+		return fmt.Errorf("failed to find invite: %w", err)
+	}
+
+	if inviterID != userID {
+		return fmt.Errorf("unauthorized: you did not create this invite")
+	}
+
+	_, err = s.db.Pool.Exec(ctx, `
+		UPDATE friend_invites SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1
+	`, inviteID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to revoke friend invite")
+		return fmt.Errorf("failed to revoke invite: %w", err)
+	}
+
+	log.Info().Str("invite_id", inviteID).Msg("Friend invite revoked")
+	return nil
+}
+
+// RedeemInvite verifies token and makes userID friends with the inviter
+func (s *InviteService) RedeemInvite(ctx context.Context, token, userID string) (*models.Friendship, error) {
+	payload, err := s.verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.InviterID == userID {
+		return nil, fmt.Errorf("cannot redeem your own invite")
+	}
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the invite so concurrent redemptions can't exceed max_uses
+	var invite models.FriendInvite
+	err = tx.QueryRow(ctx, `
+		SELECT id, inviter_id, max_uses, use_count, expires_at, revoked_at
+		FROM friend_invites
+		WHERE id = $1
+		FOR UPDATE
+	`, payload.InviteID).Scan(
+		&invite.ID,
+		&invite.InviterID,
+		&invite.MaxUses,
+		&invite.UseCount,
+		&invite.ExpiresAt,
+		&invite.RevokedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("invite not found")
+		}
+		return nil, fmt.Errorf("failed to find invite: %w", err)
+	}
+
+	if invite.RevokedAt != nil {
+		return nil, fmt.Errorf("invite has been revoked")
+	}
+	if time.Now().After(invite.ExpiresAt) {
+		return nil, fmt.Errorf("invite has expired")
+	}
+	if invite.UseCount >= invite.MaxUses {
+		return nil, fmt.Errorf("invite has already been used")
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO friend_invite_redemptions (invite_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, invite.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record redemption: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("invite has already been used")
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE friend_invites SET use_count = use_count + 1 WHERE id = $1
+	`, invite.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update invite: %w", err)
+	}
+
+	friendship, err := s.friends.CreateFriendship(ctx, tx, invite.InviterID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.notifications.NotifyFriendAccepted(ctx, tx, userID, invite.InviterID, friendship.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info().
+		Str("invite_id", invite.ID).
+		Str("inviter_id", invite.InviterID).
+		Str("user_id", userID).
+		Msg("Friend invite redeemed")
+
+	return friendship, nil
+}
+
+// RenderQRCode renders the invite link for token as a PNG QR code. Only the
+// inviter may render their own invites.
+func (s *InviteService) RenderQRCode(token, userID string, size int) ([]byte, error) {
+	payload, err := s.verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.InviterID != userID {
+		return nil, fmt.Errorf("unauthorized: you did not create this invite")
+	}
+
+	png, err := qrcode.Encode(s.baseURL+token, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %w", err)
+	}
+
+	return png, nil
+}
+
+// sign encodes and signs a payload as "<payload>.<signature>", both base64url
+func (s *InviteService) sign(payload invitePayload) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode invite: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// verify checks the token signature and expiry and returns its payload
+func (s *InviteService) verify(token string) (*invitePayload, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, fmt.Errorf("invalid invite token")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, s.mac(encoded)) {
+		return nil, fmt.Errorf("invalid invite token")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invite token")
+	}
+
+	var payload invitePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("invalid invite token")
+	}
+
+	if time.Now().Unix() > payload.ExpiresAt {
+		return nil, fmt.Errorf("invite has expired")
+	}
+
+	return &payload, nil
+}
+
+// mac computes the HMAC-SHA256 of data with the signing secret
+func (s *InviteService) mac(data string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
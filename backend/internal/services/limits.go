@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// LimitAction identifies a rate-limited social action
+type LimitAction string
+
+const (
+	LimitActionFriendRequest LimitAction = "friend_request"
+	LimitActionSpotSave      LimitAction = "spot_save"
+)
+
+// pendingRetryHint is returned as the retry-after when the pending limit is hit
+// and there is no natural expiry to wait for (friend requests never expire)
+const pendingRetryHint = time.Hour
+
+// Limits holds the limits for a single action. A zero value disables that limit.
+type Limits struct {
+	DailyQuota      int           `json:"daily_quota"`
+	MaxPending      int           `json:"max_pending"`
+	DeclineCooldown time.Duration `json:"decline_cooldown"`
+}
+
+// RateLimitError is returned when an action exceeds one of its limits
+type RateLimitError struct {
+	Action     LimitAction
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited: %s", e.Reason)
+}
+
+// LimitService enforces anti-spam limits on social requests
+type LimitService struct {
+	db       *database.Database
+	defaults map[LimitAction]Limits
+}
+
+// NewLimitService creates a new limit service with defaults read from the environment
+func NewLimitService(db *database.Database) *LimitService {
+	return &LimitService{
+		db: db,
+		defaults: map[LimitAction]Limits{
+			LimitActionFriendRequest: {
+				DailyQuota:      envInt("FRIEND_REQUEST_DAILY_LIMIT", 30),
+				MaxPending:      envInt("FRIEND_REQUEST_MAX_PENDING", 50),
+				DeclineCooldown: envDuration("FRIEND_REQUEST_DECLINE_COOLDOWN", 7*24*time.Hour),
+			},
+			LimitActionSpotSave: {
+				DailyQuota:      envInt("SPOT_SAVE_DAILY_LIMIT", 20),
+				MaxPending:      envInt("SPOT_SAVE_MAX_PENDING", 3),
+				DeclineCooldown: envDuration("SPOT_SAVE_DECLINE_COOLDOWN", 30*time.Minute),
+			},
+		},
+	}
+}
+
+// GetLimits returns the effective limits for an action, preferring overrides
+// stored in social_limits over the environment defaults
+func (s *LimitService) GetLimits(ctx context.Context, q pgx.Tx, action LimitAction) (Limits, error) {
+	var limits Limits
+	var cooldownSeconds int64
+	err := q.QueryRow(ctx, `
+		SELECT daily_quota, max_pending, EXTRACT(EPOCH FROM decline_cooldown)::bigint
+		FROM social_limits
+		WHERE action = $1
+	`, string(action)).Scan(&limits.DailyQuota, &limits.MaxPending, &cooldownSeconds)
+
+	if err == pgx.ErrNoRows {
+		return s.defaults[action], nil
+	} else if err != nil {
+		return Limits{}, fmt.Errorf("failed to load limits: %w", err)
+	}
+
+	limits.DeclineCooldown = time.Duration(cooldownSeconds) * time.Second
+	return limits, nil
+}
+
+// CheckAndRecord verifies that userID may send an action to targetID and, if so,
+// records it against their daily quota. It must run inside the transaction that
+// creates the request so concurrent requests from other replicas are serialized.
+func (s *LimitService) CheckAndRecord(ctx context.Context, tx pgx.Tx, action LimitAction, userID, targetID string) error {
+	// Serialize checks per user and action across all replicas
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, string(action)+":"+userID)
+	if err != nil {
+		return fmt.Errorf("failed to acquire limit lock: %w", err)
+	}
+
+	limits, err := s.GetLimits(ctx, tx, action)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	// 1. Per-pair cooldown after the target declined a previous request
+	if limits.DeclineCooldown > 0 {
+		var declinedAt *time.Time
+		err = tx.QueryRow(ctx, declinedAtQuery(action), userID, targetID).Scan(&declinedAt)
+		if err != nil {
+			return fmt.Errorf("failed to check decline cooldown: %w", err)
+		}
+
+		if declinedAt != nil && declinedAt.Add(limits.DeclineCooldown).After(now) {
+			return &RateLimitError{
+				Action:     action,
+				Reason:     "this user recently declined your request",
+				RetryAfter: declinedAt.Add(limits.DeclineCooldown).Sub(now),
+			}
+		}
+	}
+
+	// 2. Maximum outstanding pending requests
+	if limits.MaxPending > 0 {
+		var pending int
+		var nextExpiry *time.Time
+		err = tx.QueryRow(ctx, pendingQuery(action), userID).Scan(&pending, &nextExpiry)
+		if err != nil {
+			return fmt.Errorf("failed to count pending requests: %w", err)
+		}
+
+		if pending >= limits.MaxPending {
+			retryAfter := pendingRetryHint
+			if nextExpiry != nil {
+				retryAfter = nextExpiry.Sub(now)
+			}
+			return &RateLimitError{
+				Action:     action,
+				Reason:     fmt.Sprintf("too many pending requests (max %d)", limits.MaxPending),
+				RetryAfter: retryAfter,
+			}
+		}
+	}
+
+	// 3. Rolling 24 hour quota
+	if limits.DailyQuota > 0 {
+		var sent int
+		var oldest *time.Time
+		err = tx.QueryRow(ctx, `
+			SELECT COUNT(*), MIN(created_at)
+			FROM social_request_log
+			WHERE user_id = $1 AND action = $2 AND created_at > NOW() - INTERVAL '24 hours'
+		`, userID, string(action)).Scan(&sent, &oldest)
+		if err != nil {
+			return fmt.Errorf("failed to count daily requests: %w", err)
+		}
+
+		if sent >= limits.DailyQuota {
+			retryAfter := 24 * time.Hour
+			if oldest != nil {
+				retryAfter = oldest.Add(24 * time.Hour).Sub(now)
+			}
+			return &RateLimitError{
+				Action:     action,
+				Reason:     fmt.Sprintf("daily limit of %d requests reached", limits.DailyQuota),
+				RetryAfter: retryAfter,
+			}
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO social_request_log (user_id, target_id, action)
+		VALUES ($1, $2, $3)
+	`, userID, targetID, string(action))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to record social request")
+		return fmt.Errorf("failed to record request: %w", err)
+	}
+
+	return nil
+}
+
+// declinedAtQuery returns the most recent time targetID ($2) declined userID ($1)
+func declinedAtQuery(action LimitAction) string {
+	if action == LimitActionSpotSave {
+		return `
+			SELECT MAX(responded_at) FROM spot_save_requests
+			WHERE requester_id = $1 AND saver_id = $2 AND status = 'declined'
+		`
+	}
+	return `
+		SELECT MAX(responded_at) FROM friendships
+		WHERE user_id = $1 AND friend_id = $2 AND status = 'declined'
+	`
+}
+
+// pendingQuery returns the number of pending requests sent by $1 and,
+// where requests expire, the time the oldest one frees up a slot
+func pendingQuery(action LimitAction) string {
+	if action == LimitActionSpotSave {
+		return `
+			SELECT COUNT(*), MIN(expires_at) FROM spot_save_requests
+			WHERE requester_id = $1 AND status = 'pending' AND expires_at > NOW()
+		`
+	}
+	return `
+		SELECT COUNT(*), NULL::timestamptz FROM friendships
+		WHERE user_id = $1 AND status = 'pending'
+	`
+}
+
+// envInt reads an integer environment variable, falling back to def
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Warn().Str("key", key).Str("value", value).Msg("Invalid integer in environment, using default")
+		return def
+	}
+	return n
+}
+
+// envDuration reads a Go duration environment variable (e.g. "30m"), falling back to def
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Warn().Str("key", key).Str("value", value).Msg("Invalid duration in environment, using default")
+		return def
+	}
+	return d
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+// Notification types accepted by the notifications table
+const (
+	NotificationFriendRequest    = "friend_request"
+	NotificationFriendAccepted   = "friend_accepted"
+	NotificationSpotSaveRequest  = "spot_save_request"
+	NotificationSpotSaveResponse = "spot_save_response"
+	NotificationFriendNearby     = "friend_nearby"
+	NotificationSpotAvailable    = "spot_available"
+	NotificationWaitlistOffer    = "waitlist_offer"
+)
+
+// NotificationService manages the in-app notification inbox
+type NotificationService struct {
+	db *database.Database
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(db *database.Database) *NotificationService {
+	return &NotificationService{db: db}
+}
+
+// NewNotification describes a notification to enqueue. ActorID is the user
+// whose action caused it; recipients who muted a group containing the actor
+// don't receive it.
+type NewNotification struct {
+	UserID  string
+	ActorID string
+	Type    string
+	Title   string
+	Body    string
+	Data    models.JSONB
+}
+
+// NotificationsPage represents one page of a user's inbox
+type NotificationsPage struct {
+	Notifications []models.Notification `json:"notifications"`
+	UnreadCount   int                   `json:"unread_count"`
+	HasMore       bool                  `json:"has_more"`
+}
+
+// Enqueue writes a notification for later display and push delivery. It runs
+// on q so callers can write it in the same transaction as the triggering
+// change. Returns nil without error if the recipient turned off this type of
+// notification or muted the actor.
+func (s *NotificationService) Enqueue(ctx context.Context, q querier, n NewNotification) (*models.Notification, error) {
+	prefs, err := getPreferences(ctx, q, n.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !prefs.Allows(n.Type) {
+		log.Debug().
+			Str("user_id", n.UserID).
+			Str("type", n.Type).
+			Msg("Notification dropped by preferences")
+		return nil, nil
+	}
+
+	if n.ActorID != "" {
+		var muted bool
+		err := q.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM friend_groups g
+				JOIN friend_group_members m ON m.group_id = g.id
+				WHERE g.owner_id = $1 AND m.member_id = $2 AND g.notifications_muted
+			)
+		`, n.UserID, n.ActorID).Scan(&muted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check muted groups: %w", err)
+		}
+
+		if muted {
+			log.Debug().
+				Str("user_id", n.UserID).
+				Str("actor_id", n.ActorID).
+				Str("type", n.Type).
+				Msg("Notification suppressed by muted group")
+			return nil, nil
+		}
+	}
+
+	var notification models.Notification
+	err = q.QueryRow(ctx, `
+		INSERT INTO notifications (user_id, type, title, body, data)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, user_id, type, title, body, data, status, read, created_at
+	`, n.UserID, n.Type, n.Title, n.Body, n.Data).Scan(
+		&notification.ID,
+		&notification.UserID,
+		&notification.Type,
+		&notification.Title,
+		&notification.Body,
+		&notification.Data,
+		&notification.Status,
+		&notification.Read,
+		&notification.CreatedAt,
+	)
+
+	if err != nil {
+		log.Error().Err(err).Str("type", n.Type).Msg("Failed to enqueue notification")
+		return nil, fmt.Errorf("failed to enqueue notification: %w", err)
+	}
+
+	return &notification, nil
+}
+
+// NotifyFriendRequest tells toID that fromID sent them a friend request
+func (s *NotificationService) NotifyFriendRequest(ctx context.Context, q querier, fromID, toID, friendshipID string) error {
+	name, err := displayName(ctx, q, fromID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Enqueue(ctx, q, NewNotification{
+		UserID:  toID,
+		ActorID: fromID,
+		Type:    NotificationFriendRequest,
+		Title:   "New friend request",
+		Body:    fmt.Sprintf("%s wants to be your friend", name),
+		Data:    models.JSONB{"friendship_id": friendshipID, "user_id": fromID},
+	})
+	return err
+}
+
+// NotifyFriendAccepted tells toID that fromID is now their friend
+func (s *NotificationService) NotifyFriendAccepted(ctx context.Context, q querier, fromID, toID, friendshipID string) error {
+	name, err := displayName(ctx, q, fromID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Enqueue(ctx, q, NewNotification{
+		UserID:  toID,
+		ActorID: fromID,
+		Type:    NotificationFriendAccepted,
+		Title:   "Friend request accepted",
+		Body:    fmt.Sprintf("You and %s are now friends", name),
+		Data:    models.JSONB{"friendship_id": friendshipID, "user_id": fromID},
+	})
+	return err
+}
+
+// NotifySpotSaveRequest tells the saver that a friend asked them to save a spot
+func (s *NotificationService) NotifySpotSaveRequest(ctx context.Context, q querier, request *models.SpotSaveRequest) error {
+	name, err := displayName(ctx, q, request.RequesterID)
+	if err != nil {
+		return err
+	}
+
+	spot, err := spotName(ctx, q, request.SpotID)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("%s asked you to save a spot at %s", name, spot)
+	if request.Message != "" {
+		body += ": " + request.Message
+	}
+
+	_, err = s.Enqueue(ctx, q, NewNotification{
+		UserID:  request.SaverID,
+		ActorID: request.RequesterID,
+		Type:    NotificationSpotSaveRequest,
+		Title:   "Can you save a spot?",
+		Body:    body,
+		Data: models.JSONB{
+			"request_id": request.ID,
+			"spot_id":    request.SpotID,
+			"user_id":    request.RequesterID,
+			"expires_at": request.ExpiresAt,
+		},
+	})
+	return err
+}
+
+// NotifySpotSaveResponse tells the requester how the saver responded
+func (s *NotificationService) NotifySpotSaveResponse(ctx context.Context, q querier, requestID, requesterID, saverID, spotID, response string) error {
+	name, err := displayName(ctx, q, saverID)
+	if err != nil {
+		return err
+	}
+
+	spot, err := spotName(ctx, q, spotID)
+	if err != nil {
+		return err
+	}
+
+	title := "Spot saved!"
+	body := fmt.Sprintf("%s is saving you a spot at %s", name, spot)
+	if response != "accepted" {
+		title = "Spot save declined"
+		body = fmt.Sprintf("%s can't save you a spot at %s", name, spot)
+	}
+
+	_, err = s.Enqueue(ctx, q, NewNotification{
+		UserID:  requesterID,
+		ActorID: saverID,
+		Type:    NotificationSpotSaveResponse,
+		Title:   title,
+		Body:    body,
+		Data: models.JSONB{
+			"request_id": requestID,
+			"spot_id":    spotID,
+			"user_id":    saverID,
+			"response":   response,
+		},
+	})
+	return err
+}
+
+// List returns a page of the user's notifications, newest first
+func (s *NotificationService) List(ctx context.Context, userID string, limit, offset int, unreadOnly bool) (*NotificationsPage, error) {
+	// Fetch one extra row to know whether another page exists
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, user_id, type, title, body, data, status, sent_at, read, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read = false)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, userID, unreadOnly, limit+1, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query notifications")
+		return nil, fmt.Errorf("failed to get notifications: %w", err)
+	}
+	defer rows.Close()
+
+	page := &NotificationsPage{Notifications: []models.Notification{}}
+	for rows.Next() {
+		var n models.Notification
+		err := rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&n.Type,
+			&n.Title,
+			&n.Body,
+			&n.Data,
+			&n.Status,
+			&n.SentAt,
+			&n.Read,
+			&n.ReadAt,
+			&n.CreatedAt,
+		)
+
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan notification")
+			continue
+		}
+
+		page.Notifications = append(page.Notifications, n)
+	}
+
+	if len(page.Notifications) > limit {
+		page.Notifications = page.Notifications[:limit]
+		page.HasMore = true
+	}
+
+	page.UnreadCount, err = s.UnreadCount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// UnreadCount returns the number of unread notifications for the user
+func (s *NotificationService) UnreadCount(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read = false
+	`, userID).Scan(&count)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRead marks a single notification as read
+func (s *NotificationService) MarkRead(ctx context.Context, userID, notificationID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE notifications
+		SET read = true, read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2
+	`, notificationID, userID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to mark notification read")
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("notification not found")
+	}
+	return nil
+}
+
+// MarkAllRead marks the given notifications as read, or every unread
+// notification if ids is empty. Returns the number of rows updated.
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID string, ids []string) (int64, error) {
+	var tag pgconn.CommandTag
+	var err error
+	if len(ids) == 0 {
+		tag, err = s.db.Pool.Exec(ctx, `
+			UPDATE notifications
+			SET read = true, read_at = NOW()
+			WHERE user_id = $1 AND read = false
+		`, userID)
+	} else {
+		tag, err = s.db.Pool.Exec(ctx, `
+			UPDATE notifications
+			SET read = true, read_at = NOW()
+			WHERE user_id = $1 AND read = false AND id = ANY($2::uuid[])
+		`, userID, ids)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to mark notifications read")
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Delete removes a notification from the user's inbox
+func (s *NotificationService) Delete(ctx context.Context, userID, notificationID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM notifications WHERE id = $1 AND user_id = $2
+	`, notificationID, userID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete notification")
+		return fmt.Errorf("failed to delete notification: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("notification not found")
+	}
+	return nil
+}
+
+// displayName returns the name shown for a user in notification text
+func displayName(ctx context.Context, q querier, userID string) (string, error) {
+	var username string
+	var fullName *string
+	err := q.QueryRow(ctx, `
+		SELECT username, full_name FROM profiles WHERE id = $1
+	`, userID).Scan(&username, &fullName)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "Someone", nil
+		}
+		return "", fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	if fullName != nil && *fullName != "" {
+		return *fullName, nil
+	}
+	return username, nil
+}
+
+// spotName returns a spot's display name for notification text
+func spotName(ctx context.Context, q querier, spotID string) (string, error) {
+	var name string
+	err := q.QueryRow(ctx, `SELECT name FROM spots WHERE id = $1`, spotID).Scan(&name)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "a spot", nil
+		}
+		return "", fmt.Errorf("failed to get spot: %w", err)
+	}
+	return name, nil
+}
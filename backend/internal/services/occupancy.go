@@ -6,6 +6,7 @@ import (
 	"math"
 	"time"
 
+	"github.com/harrypall/havn-backend/internal/events"
 	"github.com/harrypall/havn-backend/pkg/database"
 	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
@@ -13,12 +14,25 @@ import (
 
 // OccupancyService handles occupancy tracking
 type OccupancyService struct {
-	db *database.Database
+	db                   *database.Database
+	proximity            *ProximityService
+	watches              *WatchService
+	waitlist             *WaitlistService
+	bus                  events.Bus
+	autoCheckoutInterval time.Duration
 }
 
-// NewOccupancyService creates a new occupancy service
-func NewOccupancyService(db *database.Database) *OccupancyService {
-	return &OccupancyService{db: db}
+// NewOccupancyService creates a new occupancy service. Expired sessions are
+// swept every AUTO_CHECKOUT_INTERVAL (default 1m).
+func NewOccupancyService(db *database.Database, proximity *ProximityService, watches *WatchService, waitlist *WaitlistService, bus events.Bus) *OccupancyService {
+	return &OccupancyService{
+		db:                   db,
+		proximity:            proximity,
+		watches:              watches,
+		waitlist:             waitlist,
+		bus:                  bus,
+		autoCheckoutInterval: envDuration("AUTO_CHECKOUT_INTERVAL", time.Minute),
+	}
 }
 
 // CheckInResponse represents the response from a check-in operation
@@ -59,17 +73,22 @@ func (s *OccupancyService) CheckIn(ctx context.Context, userID, spotID string, l
 		return nil, fmt.Errorf("failed to check existing check-in: %w", err)
 	}
 
-	// 2. Validate distance to spot (< 200m)
+	// 2. Validate distance to spot (< 200m). The row lock orders this check-in
+	// against waitlist offers for the same spot.
 	var spotLat, spotLon float64
 	var spotName string
+	var capacity, occupancy int
 	err = tx.QueryRow(ctx, `
 		SELECT 
 			ST_Y(location::geometry) as latitude,
 			ST_X(location::geometry) as longitude,
-			name
+			name,
+			capacity,
+			current_occupancy
 		FROM spots
 		WHERE id = $1
-	`, spotID).Scan(&spotLat, &spotLon, &spotName)
+		FOR UPDATE
+	`, spotID).Scan(&spotLat, &spotLon, &spotName, &capacity, &occupancy)
 	
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -84,6 +103,11 @@ func (s *OccupancyService) CheckIn(ctx context.Context, userID, spotID string, l
 		return nil, fmt.Errorf("too far from spot (%.0fm away, must be within 200m)", distance)
 	}
 
+	// Honour a waitlist claim, or refuse if the free seats are held for others
+	if err := s.waitlist.claimSeat(ctx, tx, userID, spotID, capacity, occupancy); err != nil {
+		return nil, err
+	}
+
 	// 3. Insert occupancy_log
 	var occupancyLogID string
 	err = tx.QueryRow(ctx, `
@@ -120,6 +144,14 @@ func (s *OccupancyService) CheckIn(ctx context.Context, userID, spotID string, l
 		return nil, fmt.Errorf("failed to update profile: %w", err)
 	}
 
+	// 6. Log and publish the change for realtime streams
+	if err := s.publishOccupancyChanged(ctx, tx, spotID, OccupancyCauseCheckIn); err != nil {
+		return nil, err
+	}
+	if err := publishPresenceChanged(ctx, s.bus, tx, userID); err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
@@ -131,8 +163,17 @@ func (s *OccupancyService) CheckIn(ctx context.Context, userID, spotID string, l
 		Float64("distance", distance).
 		Msg("User checked in successfully")
 
-	// Default auto-checkout after 4 hours
+	// Proximity alerts and watches are best effort and never fail the check-in.
+	// Alerts run in the background so check-in latency doesn't grow with the
+	// number of friends nearby.
+	go s.alertNearbyFriends(context.WithoutCancel(ctx), userID, spotID)
+	s.evaluateWatches(ctx, spotID)
+
+	// Auto-checkout after the user's preferred number of hours (default 4)
 	autoCheckoutAt := time.Now().Add(4 * time.Hour)
+	if prefs, err := getPreferences(ctx, s.db.Pool, userID); err == nil {
+		autoCheckoutAt = time.Now().Add(time.Duration(prefs.AutoCheckoutHours) * time.Hour)
+	}
 
 	return &CheckInResponse{
 		OccupancyLogID: occupancyLogID,
@@ -155,6 +196,12 @@ type CheckOutResponse struct {
 
 // CheckOut handles user check-out from a spot
 func (s *OccupancyService) CheckOut(ctx context.Context, userID string) (*CheckOutResponse, error) {
+	return s.checkOut(ctx, userID, "checked_out")
+}
+
+// checkOut ends the user's active check-in, recording status as either
+// 'checked_out' or 'auto_checkout'
+func (s *OccupancyService) checkOut(ctx context.Context, userID, status string) (*CheckOutResponse, error) {
 	// Start transaction
 	tx, err := s.db.Pool.Begin(ctx)
 	if err != nil {
@@ -162,7 +209,7 @@ func (s *OccupancyService) CheckOut(ctx context.Context, userID string) (*CheckO
 	}
 	defer tx.Rollback(ctx)
 
-	// 1. Find active check-in
+	// 1. Find and lock the active check-in
 	var occupancyLogID, spotID, spotName string
 	var checkedInAt time.Time
 	err = tx.QueryRow(ctx, `
@@ -171,6 +218,7 @@ func (s *OccupancyService) CheckOut(ctx context.Context, userID string) (*CheckO
 		JOIN spots s ON s.id = ol.spot_id
 		WHERE ol.user_id = $1 AND ol.checked_out_at IS NULL
 		LIMIT 1
+		FOR UPDATE OF ol
 	`, userID).Scan(&occupancyLogID, &spotID, &checkedInAt, &spotName)
 	
 	if err != nil {
@@ -184,18 +232,22 @@ func (s *OccupancyService) CheckOut(ctx context.Context, userID string) (*CheckO
 	now := time.Now()
 	duration := now.Sub(checkedInAt)
 
-	// 2. Update occupancy_log
-	_, err = tx.Exec(ctx, `
+	// 2. Update occupancy_log. The row is locked above, but only close it if
+	// it is still open so a concurrent checkout can never be counted twice.
+	result, err := tx.Exec(ctx, `
 		UPDATE occupancy_logs
 		SET checked_out_at = NOW(),
 		    session_duration = $1,
-		    status = 'checked_out'
-		WHERE id = $2
-	`, duration, occupancyLogID)
+		    status = $3
+		WHERE id = $2 AND checked_out_at IS NULL
+	`, duration, occupancyLogID, status)
 	
 	if err != nil {
 		return nil, fmt.Errorf("failed to update occupancy log: %w", err)
 	}
+	if result.RowsAffected() == 0 {
+		return nil, fmt.Errorf("no active check-in found")
+	}
 
 	// 3. Decrement spots.current_occupancy
 	var newOccupancy int
@@ -221,6 +273,23 @@ func (s *OccupancyService) CheckOut(ctx context.Context, userID string) (*CheckO
 		return nil, fmt.Errorf("failed to update profile: %w", err)
 	}
 
+	// 5. Offer the freed seat to the head of the spot's waitlist
+	if err := s.waitlist.offerNext(ctx, tx, spotID); err != nil {
+		return nil, err
+	}
+
+	// 6. Log and publish the change for realtime streams
+	cause := OccupancyCauseCheckOut
+	if status == "auto_checkout" {
+		cause = OccupancyCauseAutoCheckout
+	}
+	if err := s.publishOccupancyChanged(ctx, tx, spotID, cause); err != nil {
+		return nil, err
+	}
+	if err := publishPresenceChanged(ctx, s.bus, tx, userID); err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
@@ -229,9 +298,12 @@ func (s *OccupancyService) CheckOut(ctx context.Context, userID string) (*CheckO
 	log.Info().
 		Str("user_id", userID).
 		Str("spot_id", spotID).
+		Str("status", status).
 		Dur("duration", duration).
 		Msg("User checked out successfully")
 
+	s.evaluateWatches(ctx, spotID)
+
 	return &CheckOutResponse{
 		Spot: SpotInfo{
 			ID:               spotID,
@@ -243,6 +315,108 @@ func (s *OccupancyService) CheckOut(ctx context.Context, userID string) (*CheckO
 	}, nil
 }
 
+// RunAutoCheckout checks out expired sessions until ctx is cancelled
+func (s *OccupancyService) RunAutoCheckout(ctx context.Context) {
+	log.Info().Dur("interval", s.autoCheckoutInterval).Msg("Auto-checkout worker started")
+
+	ticker := time.NewTicker(s.autoCheckoutInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.AutoCheckoutExpired(ctx); err != nil {
+			log.Error().Err(err).Msg("Auto-checkout sweep failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Auto-checkout worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// AutoCheckoutExpired checks out every user whose session has outlasted their
+// auto_checkout_hours preference. Returns the number of sessions ended.
+func (s *OccupancyService) AutoCheckoutExpired(ctx context.Context) (int, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT ol.user_id
+		FROM occupancy_logs ol
+		JOIN profiles p ON p.id = ol.user_id
+		WHERE ol.checked_out_at IS NULL
+		AND ol.checked_in_at < NOW() - make_interval(hours => COALESCE(
+			CASE WHEN p.preferences->>'auto_checkout_hours' ~ '^[0-9]+$'
+			     THEN (p.preferences->>'auto_checkout_hours')::int END,
+			4
+		))
+		LIMIT 500
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired check-ins: %w", err)
+	}
+
+	userIDs := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			log.Error().Err(err).Msg("Failed to scan expired check-in")
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+
+	count := 0
+	for _, userID := range userIDs {
+		// Another replica or the user may have checked out in the meantime;
+		// checkOut locks the session, so the loser finds no active check-in
+		if _, err := s.checkOut(ctx, userID, "auto_checkout"); err != nil {
+			if err.Error() != "no active check-in found" {
+				log.Error().Err(err).Str("user_id", userID).Msg("Failed to auto-checkout")
+			}
+			continue
+		}
+		count++
+	}
+
+	if count > 0 {
+		log.Info().Int("count", count).Msg("Auto-checked out expired sessions")
+	}
+	return count, nil
+}
+
+// publishOccupancyChanged records the spot's new occupancy in the replay log
+// and publishes it, both as part of the caller's transaction
+func (s *OccupancyService) publishOccupancyChanged(ctx context.Context, tx pgx.Tx, spotID, cause string) error {
+	event, err := recordOccupancyEvent(ctx, tx, spotID, cause)
+	if err != nil {
+		return err
+	}
+	return publishEvent(ctx, s.bus, tx, events.SpotOccupancyChanged, events.AggregateSpot, spotID, event)
+}
+
+// nearbyAlertTimeout bounds the background proximity alerts for one check-in
+const nearbyAlertTimeout = 30 * time.Second
+
+// alertNearbyFriends sends proximity alerts for a check-in. It runs on its own
+// goroutine with a context detached from the request, so failures are logged.
+func (s *OccupancyService) alertNearbyFriends(ctx context.Context, userID, spotID string) {
+	ctx, cancel := context.WithTimeout(ctx, nearbyAlertTimeout)
+	defer cancel()
+
+	if err := s.proximity.AlertNearbyFriends(ctx, userID, spotID); err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to send friend nearby alerts")
+	}
+}
+
+// evaluateWatches runs spot watches after an occupancy change. Failures are
+// logged rather than failing the check-in or checkout.
+func (s *OccupancyService) evaluateWatches(ctx context.Context, spotID string) {
+	if err := s.watches.EvaluateSpot(ctx, spotID); err != nil {
+		log.Error().Err(err).Str("spot_id", spotID).Msg("Failed to evaluate spot watches")
+	}
+}
+
 // calculateDistance calculates the distance in meters between two lat/lon points
 func calculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	const earthRadius = 6371000 // meters
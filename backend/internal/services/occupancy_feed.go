@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/events"
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/rs/zerolog/log"
+)
+
+// Occupancy event causes
+const (
+	OccupancyCauseCheckIn      = "checkin"
+	OccupancyCauseCheckOut     = "checkout"
+	OccupancyCauseAutoCheckout = "auto_checkout"
+)
+
+// occupancyFeedBuffer is how many events a slow subscriber may fall behind
+// before it is dropped. The client reconnects and replays what it missed.
+const occupancyFeedBuffer = 64
+
+// maxOccupancyReplay caps how many events are replayed on resume
+const maxOccupancyReplay = 1000
+
+// OccupancyEvent is a change in a spot's occupancy
+type OccupancyEvent struct {
+	ID               int64     `json:"id"`
+	SpotID           string    `json:"spot_id"`
+	CurrentOccupancy int       `json:"current_occupancy"`
+	Capacity         int       `json:"capacity"`
+	OccupancyPercent int       `json:"occupancy_percentage"`
+	OccupancyStatus  string    `json:"occupancy_status"`
+	Cause            string    `json:"cause"`
+	Latitude         float64   `json:"latitude"`
+	Longitude        float64   `json:"longitude"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// BoundingBox is a latitude/longitude rectangle
+type BoundingBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+// Contains reports whether the point lies inside the box
+func (b BoundingBox) Contains(lat, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// OccupancyFilter selects the spots a subscriber receives events for: either
+// an explicit list of spot ids or everything inside a bounding box
+type OccupancyFilter struct {
+	SpotIDs map[string]bool
+	BBox    *BoundingBox
+}
+
+// Matches reports whether the event passes the filter
+func (f OccupancyFilter) Matches(event OccupancyEvent) bool {
+	if f.SpotIDs[event.SpotID] {
+		return true
+	}
+	return f.BBox != nil && f.BBox.Contains(event.Latitude, event.Longitude)
+}
+
+// OccupancySubscription receives live occupancy events. Events is closed when
+// the subscription ends, including when the subscriber falls too far behind.
+type OccupancySubscription struct {
+	Events <-chan OccupancyEvent
+	events chan OccupancyEvent
+	filter OccupancyFilter
+}
+
+// OccupancyFeed fans occupancy changes out to stream subscribers and keeps a
+// replay log so clients can resume from the last event they received
+type OccupancyFeed struct {
+	db        *database.Database
+	retention time.Duration
+
+	mu   sync.Mutex
+	subs map[*OccupancySubscription]struct{}
+}
+
+// NewOccupancyFeed creates a new occupancy feed fed by spot.occupancy_changed
+// events from every replica. Events older than OCCUPANCY_EVENT_RETENTION
+// (default 1h) can no longer be replayed.
+func NewOccupancyFeed(db *database.Database, bus events.Bus) *OccupancyFeed {
+	f := &OccupancyFeed{
+		db:        db,
+		retention: envDuration("OCCUPANCY_EVENT_RETENTION", time.Hour),
+		subs:      map[*OccupancySubscription]struct{}{},
+	}
+
+	bus.Subscribe(events.SpotOccupancyChanged, f.handleOccupancyChanged)
+	bus.Subscribe(events.BusReconnected, f.handleReconnected)
+
+	return f
+}
+
+// handleOccupancyChanged forwards bus events to local subscribers
+func (f *OccupancyFeed) handleOccupancyChanged(ctx context.Context, e events.Event) {
+	var event OccupancyEvent
+	if err := e.Decode(&event); err != nil {
+		log.Error().Err(err).Str("event_id", e.ID).Msg("Failed to decode occupancy event")
+		return
+	}
+	f.Publish(event)
+}
+
+// handleReconnected drops every subscriber after the bus lost its connection.
+// Clients reconnect with their Last-Event-ID and replay whatever was missed.
+func (f *OccupancyFeed) handleReconnected(ctx context.Context, e events.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subs {
+		delete(f.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Subscribe starts receiving live events that match the filter
+func (f *OccupancyFeed) Subscribe(filter OccupancyFilter) *OccupancySubscription {
+	events := make(chan OccupancyEvent, occupancyFeedBuffer)
+	sub := &OccupancySubscription{Events: events, events: events, filter: filter}
+
+	f.mu.Lock()
+	f.subs[sub] = struct{}{}
+	f.mu.Unlock()
+
+	return sub
+}
+
+// Unsubscribe stops a subscription. Safe to call more than once.
+func (f *OccupancyFeed) Unsubscribe(sub *OccupancySubscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subs[sub]; ok {
+		delete(f.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Publish delivers an event to every matching subscriber without blocking.
+// Subscribers whose buffer is full are dropped.
+func (f *OccupancyFeed) Publish(event OccupancyEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subs {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			log.Warn().Int64("event_id", event.ID).Msg("Occupancy subscriber too slow, dropping")
+			delete(f.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Replay returns the events after afterID that match the filter, oldest
+// first. complete is false when events after afterID have already been pruned,
+// or when more than maxOccupancyReplay events match, in which case the client
+// should refetch spots instead of relying on deltas.
+func (f *OccupancyFeed) Replay(ctx context.Context, afterID int64, filter OccupancyFilter) (events []OccupancyEvent, complete bool, err error) {
+	var oldest *int64
+	err = f.db.Pool.QueryRow(ctx, `SELECT MIN(id) FROM spot_occupancy_events`).Scan(&oldest)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get oldest occupancy event: %w", err)
+	}
+	complete = oldest == nil || *oldest <= afterID+1
+
+	// Filter in SQL so the replay limit only counts events the client wants
+	spotIDs := []string{}
+	for id := range filter.SpotIDs {
+		if IsUUID(id) {
+			spotIDs = append(spotIDs, id)
+		}
+	}
+	var minLat, minLon, maxLat, maxLon *float64
+	if b := filter.BBox; b != nil {
+		minLat, minLon, maxLat, maxLon = &b.MinLat, &b.MinLon, &b.MaxLat, &b.MaxLon
+	}
+
+	rows, err := f.db.Pool.Query(ctx, `
+		SELECT e.id, e.spot_id, e.current_occupancy, e.capacity, e.cause, e.created_at,
+		       ST_Y(s.location::geometry), ST_X(s.location::geometry)
+		FROM spot_occupancy_events e
+		JOIN spots s ON s.id = e.spot_id
+		WHERE e.id > $1
+		AND (
+			e.spot_id = ANY($3::uuid[])
+			OR ($4::float8 IS NOT NULL AND ST_Intersects(
+				s.location::geometry,
+				ST_MakeEnvelope($5::float8, $4::float8, $7::float8, $6::float8, 4326)
+			))
+		)
+		ORDER BY e.id
+		LIMIT $2
+	`, afterID, maxOccupancyReplay+1, spotIDs, minLat, minLon, maxLat, maxLon)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to replay occupancy events: %w", err)
+	}
+	defer rows.Close()
+
+	events = []OccupancyEvent{}
+	for rows.Next() {
+		var event OccupancyEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.SpotID,
+			&event.CurrentOccupancy,
+			&event.Capacity,
+			&event.Cause,
+			&event.CreatedAt,
+			&event.Latitude,
+			&event.Longitude,
+		)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan occupancy event: %w", err)
+		}
+		event.computeStatus()
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to replay occupancy events: %w", err)
+	}
+
+	if len(events) > maxOccupancyReplay {
+		events = events[:maxOccupancyReplay]
+		complete = false
+	}
+
+	return events, complete, nil
+}
+
+// Run prunes events past the retention period until ctx is cancelled
+func (f *OccupancyFeed) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.retention / 4)
+	defer ticker.Stop()
+
+	for {
+		tag, err := f.db.Pool.Exec(ctx, `
+			DELETE FROM spot_occupancy_events WHERE created_at < NOW() - make_interval(secs => $1)
+		`, f.retention.Seconds())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to prune occupancy events")
+		} else if tag.RowsAffected() > 0 {
+			log.Info().Int64("count", tag.RowsAffected()).Msg("Pruned occupancy events")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordOccupancyEvent logs the spot's current occupancy as part of the
+// caller's transaction. Event ids are handed out under a transaction-level
+// lock held until commit, so they become visible in id order and a client
+// resuming after an id can't skip an event that committed late with a lower
+// one. The caller should record the event as late as possible.
+func recordOccupancyEvent(ctx context.Context, q querier, spotID, cause string) (*OccupancyEvent, error) {
+	_, err := q.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('spot_occupancy_events'))`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock occupancy events: %w", err)
+	}
+
+	var event OccupancyEvent
+	err = q.QueryRow(ctx, `
+		WITH e AS (
+			INSERT INTO spot_occupancy_events (spot_id, current_occupancy, capacity, cause)
+			SELECT id, current_occupancy, capacity, $2 FROM spots WHERE id = $1
+			RETURNING id, spot_id, current_occupancy, capacity, cause, created_at
+		)
+		SELECT e.id, e.spot_id, e.current_occupancy, e.capacity, e.cause, e.created_at,
+		       ST_Y(s.location::geometry), ST_X(s.location::geometry)
+		FROM e
+		JOIN spots s ON s.id = e.spot_id
+	`, spotID, cause).Scan(
+		&event.ID,
+		&event.SpotID,
+		&event.CurrentOccupancy,
+		&event.Capacity,
+		&event.Cause,
+		&event.CreatedAt,
+		&event.Latitude,
+		&event.Longitude,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record occupancy event: %w", err)
+	}
+
+	event.computeStatus()
+	return &event, nil
+}
+
+// computeStatus fills in the percentage and status the same way as GET /spots
+func (e *OccupancyEvent) computeStatus() {
+	spot := models.Spot{Capacity: e.Capacity, CurrentOccupancy: e.CurrentOccupancy}
+	spot.CalculateOccupancyStatus()
+	e.OccupancyPercent = spot.OccupancyPercent
+	e.OccupancyStatus = spot.OccupancyStatus
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/events"
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// OutboxHandler performs a side effect for an outbox event. Events are
+// delivered at least once, so handlers must be idempotent (event.ID is stable
+// across retries). Returning an error schedules a retry.
+type OutboxHandler func(ctx context.Context, event events.Event) error
+
+// outboxClaimLease is how long a claimed event is hidden from other relays.
+// If a replica dies mid-delivery the event becomes due again after it.
+const outboxClaimLease = 2 * time.Minute
+
+// outboxPruneInterval is how often delivered events past retention are deleted
+const outboxPruneInterval = time.Hour
+
+// OutboxRelay delivers outbox events to registered handlers. Events for the
+// same aggregate are delivered strictly in order: a later event waits while an
+// earlier one is being retried, until the earlier one is dead-lettered.
+type OutboxRelay struct {
+	db          *database.Database
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	retention   time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string][]namedOutboxHandler
+}
+
+type namedOutboxHandler struct {
+	name    string
+	handler OutboxHandler
+}
+
+// NewOutboxRelay creates an outbox relay with settings read from the environment
+func NewOutboxRelay(db *database.Database) *OutboxRelay {
+	return &OutboxRelay{
+		db:          db,
+		interval:    envDuration("OUTBOX_RELAY_INTERVAL", time.Second),
+		batchSize:   envInt("OUTBOX_RELAY_BATCH_SIZE", 100),
+		maxAttempts: envInt("OUTBOX_MAX_ATTEMPTS", 10),
+		baseBackoff: envDuration("OUTBOX_RETRY_BACKOFF", 10*time.Second),
+		maxBackoff:  envDuration("OUTBOX_RETRY_MAX_BACKOFF", time.Hour),
+		retention:   envDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		handlers:    map[string][]namedOutboxHandler{},
+	}
+}
+
+// Register adds a handler for an event type, or events.AllEvents. The name
+// identifies the handler in logs and dead-letter errors.
+func (r *OutboxRelay) Register(eventType, name string, handler OutboxHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[eventType] = append(r.handlers[eventType], namedOutboxHandler{name: name, handler: handler})
+}
+
+// Run relays events until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	log.Info().Dur("interval", r.interval).Msg("Outbox relay started")
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		// Keep draining while full batches are being claimed
+		for {
+			n, err := r.RelayPending(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("Outbox relay failed")
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPrune) > outboxPruneInterval {
+			r.pruneDelivered(ctx)
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimedOutboxEvent is an event claimed for delivery
+type claimedOutboxEvent struct {
+	ID       int64
+	Event    events.Event
+	Attempts int
+}
+
+// RelayPending claims the due head event of each aggregate and delivers it.
+// Returns the number of events claimed.
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	claimed, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, c := range claimed {
+		if err := r.deliver(ctx, c.Event); err != nil {
+			r.retryOrDeadLetter(ctx, c, err.Error())
+			continue
+		}
+		r.markDelivered(ctx, c.ID)
+	}
+
+	return len(claimed), nil
+}
+
+// claim leases the oldest pending event of each aggregate, if it is due and
+// not already leased. Concurrent relays may both pick the same head; the
+// locked_until recheck lets only one of them take it.
+func (r *OutboxRelay) claim(ctx context.Context) ([]claimedOutboxEvent, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH heads AS (
+			SELECT DISTINCT ON (aggregate_type, aggregate_id) id, next_attempt_at, locked_until
+			FROM outbox_events
+			WHERE status = 'pending'
+			ORDER BY aggregate_type, aggregate_id, id
+		), due AS (
+			SELECT id FROM heads
+			WHERE next_attempt_at <= NOW()
+			AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY id
+			LIMIT $1
+		)
+		UPDATE outbox_events o
+		SET attempts = o.attempts + 1,
+		    locked_until = NOW() + make_interval(secs => $2)
+		FROM due
+		WHERE o.id = due.id
+		AND o.status = 'pending'
+		AND (o.locked_until IS NULL OR o.locked_until < NOW())
+		RETURNING o.id, o.event_id, o.event_type, o.aggregate_type, o.aggregate_id,
+		          o.payload, o.occurred_at, o.attempts
+	`, r.batchSize, outboxClaimLease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	claimed := []claimedOutboxEvent{}
+	for rows.Next() {
+		var c claimedOutboxEvent
+		var payload []byte
+		err := rows.Scan(
+			&c.ID,
+			&c.Event.ID,
+			&c.Event.Type,
+			&c.Event.AggregateType,
+			&c.Event.AggregateID,
+			&payload,
+			&c.Event.OccurredAt,
+			&c.Attempts,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan outbox event")
+			continue
+		}
+		c.Event.Payload = payload
+		claimed = append(claimed, c)
+	}
+
+	return claimed, rows.Err()
+}
+
+// deliver calls every handler registered for the event. All handlers run
+// again on retry, so one failing handler causes duplicates for the others.
+func (r *OutboxRelay) deliver(ctx context.Context, event events.Event) error {
+	r.mu.RLock()
+	handlers := append(append([]namedOutboxHandler{}, r.handlers[event.Type]...), r.handlers[events.AllEvents]...)
+	r.mu.RUnlock()
+
+	for _, h := range handlers {
+		if err := r.call(ctx, h, event); err != nil {
+			return fmt.Errorf("%s: %w", h.name, err)
+		}
+	}
+	return nil
+}
+
+// call runs one handler, turning a panic into an error
+func (r *OutboxRelay) call(ctx context.Context, h namedOutboxHandler, event events.Event) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return h.handler(ctx, event)
+}
+
+// backoff returns the delay before the next attempt, doubling each time
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := r.baseBackoff
+	for i := 1; i < attempts && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.maxBackoff)
+}
+
+func (r *OutboxRelay) markDelivered(ctx context.Context, id int64) {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE outbox_events
+		SET status = 'delivered', delivered_at = NOW(), locked_until = NULL
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		log.Error().Err(err).Int64("outbox_id", id).Msg("Failed to mark outbox event delivered")
+	}
+}
+
+// retryOrDeadLetter schedules another attempt, or dead-letters the event once
+// it has used all its attempts
+func (r *OutboxRelay) retryOrDeadLetter(ctx context.Context, c claimedOutboxEvent, reason string) {
+	if c.Attempts >= r.maxAttempts {
+		_, err := r.db.Pool.Exec(ctx, `
+			UPDATE outbox_events
+			SET status = 'dead', last_error = $2, locked_until = NULL
+			WHERE id = $1
+		`, c.ID, reason)
+		if err != nil {
+			log.Error().Err(err).Int64("outbox_id", c.ID).Msg("Failed to dead-letter outbox event")
+			return
+		}
+
+		log.Warn().
+			Int64("outbox_id", c.ID).
+			Str("event_type", c.Event.Type).
+			Str("error", reason).
+			Msg("Outbox event dead-lettered")
+		return
+	}
+
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE outbox_events
+		SET next_attempt_at = NOW() + make_interval(secs => $2), last_error = $3, locked_until = NULL
+		WHERE id = $1
+	`, c.ID, r.backoff(c.Attempts).Seconds(), reason)
+	if err != nil {
+		log.Error().Err(err).Int64("outbox_id", c.ID).Msg("Failed to schedule outbox retry")
+	}
+}
+
+// pruneDelivered deletes delivered events past the retention period
+func (r *OutboxRelay) pruneDelivered(ctx context.Context) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM outbox_events
+		WHERE status = 'delivered' AND delivered_at < NOW() - make_interval(secs => $1)
+	`, r.retention.Seconds())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to prune outbox events")
+		return
+	}
+
+	if tag.RowsAffected() > 0 {
+		log.Info().Int64("count", tag.RowsAffected()).Msg("Pruned delivered outbox events")
+	}
+}
+
+// OutboxStats summarises the outbox for operators
+type OutboxStats struct {
+	Pending          int        `json:"pending"`
+	Delivered        int        `json:"delivered"`
+	Dead             int        `json:"dead"`
+	OldestPendingAt  *time.Time `json:"oldest_pending_at"`
+	OldestDeadLetter *time.Time `json:"oldest_dead_letter_at"`
+}
+
+// Stats counts events by status
+func (r *OutboxRelay) Stats(ctx context.Context) (*OutboxStats, error) {
+	var stats OutboxStats
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'pending'),
+			COUNT(*) FILTER (WHERE status = 'delivered'),
+			COUNT(*) FILTER (WHERE status = 'dead'),
+			MIN(occurred_at) FILTER (WHERE status = 'pending'),
+			MIN(occurred_at) FILTER (WHERE status = 'dead')
+		FROM outbox_events
+	`).Scan(&stats.Pending, &stats.Delivered, &stats.Dead, &stats.OldestPendingAt, &stats.OldestDeadLetter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// ListEvents lists outbox events with the given status, newest first
+func (r *OutboxRelay) ListEvents(ctx context.Context, status string, limit, offset int) ([]models.OutboxEvent, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, event_id, event_type, aggregate_type, aggregate_id, payload, occurred_at,
+		       status, attempts, next_attempt_at, last_error, delivered_at, created_at
+		FROM outbox_events
+		WHERE status = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query outbox events")
+		return nil, fmt.Errorf("failed to list outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.OutboxEvent{}
+	for rows.Next() {
+		var e models.OutboxEvent
+		var payload []byte
+		err := rows.Scan(
+			&e.ID,
+			&e.EventID,
+			&e.EventType,
+			&e.AggregateType,
+			&e.AggregateID,
+			&payload,
+			&e.OccurredAt,
+			&e.Status,
+			&e.Attempts,
+			&e.NextAttemptAt,
+			&e.LastError,
+			&e.DeliveredAt,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan outbox event")
+			continue
+		}
+		e.Payload = payload
+		list = append(list, e)
+	}
+
+	return list, rows.Err()
+}
+
+// GetEvent returns a single outbox event
+func (r *OutboxRelay) GetEvent(ctx context.Context, id int64) (*models.OutboxEvent, error) {
+	var e models.OutboxEvent
+	var payload []byte
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT id, event_id, event_type, aggregate_type, aggregate_id, payload, occurred_at,
+		       status, attempts, next_attempt_at, last_error, delivered_at, created_at
+		FROM outbox_events
+		WHERE id = $1
+	`, id).Scan(
+		&e.ID,
+		&e.EventID,
+		&e.EventType,
+		&e.AggregateType,
+		&e.AggregateID,
+		&payload,
+		&e.OccurredAt,
+		&e.Status,
+		&e.Attempts,
+		&e.NextAttemptAt,
+		&e.LastError,
+		&e.DeliveredAt,
+		&e.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("outbox event not found")
+		}
+		return nil, fmt.Errorf("failed to get outbox event: %w", err)
+	}
+
+	e.Payload = payload
+	return &e, nil
+}
+
+// RetryDeadLetter puts a dead-lettered event back in the queue with fresh
+// attempts. Being older than anything still pending for its aggregate, it is
+// delivered first; events delivered while it was dead have already overtaken it.
+func (r *OutboxRelay) RetryDeadLetter(ctx context.Context, id int64) error {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE outbox_events
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), locked_until = NULL
+		WHERE id = $1 AND status = 'dead'
+	`, id)
+	if err != nil {
+		log.Error().Err(err).Int64("outbox_id", id).Msg("Failed to retry outbox event")
+		return fmt.Errorf("failed to retry outbox event: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("dead-lettered event not found")
+	}
+
+	log.Info().Int64("outbox_id", id).Msg("Dead-lettered outbox event requeued")
+	return nil
+}
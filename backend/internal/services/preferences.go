@@ -0,0 +1,322 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Preferences is the typed schema stored in profiles.preferences. Stored
+// documents are decoded over DefaultPreferences, so missing keys take defaults.
+type Preferences struct {
+	NoiseTolerance       string                  `json:"noise_tolerance"`
+	OutletRequired       bool                    `json:"outlet_required"`
+	AutoCheckoutHours    int                     `json:"auto_checkout_hours"`
+	NotificationsEnabled bool                    `json:"notifications_enabled"`
+	Notifications        NotificationPreferences `json:"notifications"`
+	QuietHours           QuietHours              `json:"quiet_hours"`
+	DailyPushCap         int                     `json:"daily_push_cap"`
+}
+
+// NotificationPreferences toggles each kind of notification. Disabled kinds
+// are dropped entirely, from both the inbox and push. Friend nearby alerts
+// reveal where friends are studying, so users opt in to them.
+type NotificationPreferences struct {
+	FriendRequests   bool `json:"friend_requests"`
+	SpotSaves        bool `json:"spot_saves"`
+	FriendNearby     bool `json:"friend_nearby"`
+	SpotAvailability bool `json:"spot_availability"`
+}
+
+// QuietHours is a daily window, in the user's timezone, during which pushes
+// are either deferred until the window ends or dropped
+type QuietHours struct {
+	Enabled  bool   `json:"enabled"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+	Mode     string `json:"mode"`
+}
+
+// Quiet hours modes
+const (
+	QuietHoursDefer = "defer"
+	QuietHoursDrop  = "drop"
+)
+
+// DefaultPreferences returns the preferences of a user who has never changed them
+func DefaultPreferences() Preferences {
+	return Preferences{
+		NoiseTolerance:       "moderate",
+		OutletRequired:       false,
+		AutoCheckoutHours:    4,
+		NotificationsEnabled: true,
+		Notifications: NotificationPreferences{
+			FriendRequests:   true,
+			SpotSaves:        true,
+			FriendNearby:     false,
+			SpotAvailability: true,
+		},
+		QuietHours: QuietHours{
+			Enabled:  false,
+			Start:    "22:00",
+			End:      "07:00",
+			Timezone: "America/Los_Angeles",
+			Mode:     QuietHoursDefer,
+		},
+		DailyPushCap: 0,
+	}
+}
+
+// Validate checks that every preference has an allowed value
+func (p Preferences) Validate() error {
+	switch p.NoiseTolerance {
+	case "quiet", "moderate", "loud":
+	default:
+		return fmt.Errorf("noise_tolerance must be one of: quiet, moderate, loud")
+	}
+
+	if p.AutoCheckoutHours < 1 || p.AutoCheckoutHours > 12 {
+		return fmt.Errorf("auto_checkout_hours must be between 1 and 12")
+	}
+
+	if p.DailyPushCap < 0 || p.DailyPushCap > 1000 {
+		return fmt.Errorf("daily_push_cap must be between 0 (unlimited) and 1000")
+	}
+
+	q := p.QuietHours
+	if _, err := parseClock(q.Start); err != nil {
+		return fmt.Errorf("quiet_hours.start must be HH:MM")
+	}
+	if _, err := parseClock(q.End); err != nil {
+		return fmt.Errorf("quiet_hours.end must be HH:MM")
+	}
+	if _, err := time.LoadLocation(q.Timezone); err != nil || q.Timezone == "" {
+		return fmt.Errorf("quiet_hours.timezone must be an IANA timezone such as America/Los_Angeles")
+	}
+	if q.Mode != QuietHoursDefer && q.Mode != QuietHoursDrop {
+		return fmt.Errorf("quiet_hours.mode must be 'defer' or 'drop'")
+	}
+
+	return nil
+}
+
+// Allows reports whether notifications of the given type are enabled
+func (p Preferences) Allows(notificationType string) bool {
+	switch notificationType {
+	case NotificationFriendRequest, NotificationFriendAccepted:
+		return p.Notifications.FriendRequests
+	case NotificationSpotSaveRequest, NotificationSpotSaveResponse:
+		return p.Notifications.SpotSaves
+	case NotificationFriendNearby:
+		return p.Notifications.FriendNearby
+	case NotificationSpotAvailable:
+		return p.Notifications.SpotAvailability
+	default:
+		return true
+	}
+}
+
+// Location returns the user's timezone, falling back to UTC
+func (q QuietHours) Location() *time.Location {
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Until reports whether now falls inside quiet hours and, if so, when they end.
+// Windows may wrap past midnight, e.g. 22:00-07:00.
+func (q QuietHours) Until(now time.Time) (bool, time.Time) {
+	if !q.Enabled {
+		return false, time.Time{}
+	}
+
+	start, err1 := parseClock(q.Start)
+	end, err2 := parseClock(q.End)
+	if err1 != nil || err2 != nil || start == end {
+		return false, time.Time{}
+	}
+
+	// Compare wall-clock times, so windows keep their local times on days
+	// when daylight saving time starts or ends
+	local := now.In(q.Location())
+	clock := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second + time.Duration(local.Nanosecond())
+
+	if start < end {
+		if clock >= start && clock < end {
+			return true, clockOn(local, 0, end)
+		}
+		return false, time.Time{}
+	}
+
+	// Wrapping window: quiet from start until midnight, and from midnight until end
+	if clock >= start {
+		return true, clockOn(local, 1, end)
+	}
+	if clock < end {
+		return true, clockOn(local, 0, end)
+	}
+	return false, time.Time{}
+}
+
+// clockOn returns the given time of day, days after day, in day's location
+func clockOn(day time.Time, days int, clock time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day()+days,
+		int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// parseClock parses "HH:MM" into a duration since midnight
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// decodePreferences decodes a stored preferences document over the defaults.
+// Unknown keys from before the schema was typed are ignored.
+func decodePreferences(raw []byte) Preferences {
+	prefs := DefaultPreferences()
+	if len(raw) > 0 {
+		// A malformed document keeps whatever decoded cleanly plus defaults
+		_ = json.Unmarshal(raw, &prefs)
+	}
+	return prefs
+}
+
+// getPreferences loads a user's typed preferences
+func getPreferences(ctx context.Context, q querier, userID string) (Preferences, error) {
+	var raw []byte
+	err := q.QueryRow(ctx, `SELECT preferences FROM profiles WHERE id = $1`, userID).Scan(&raw)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return DefaultPreferences(), nil
+		}
+		return Preferences{}, fmt.Errorf("failed to load preferences: %w", err)
+	}
+	return decodePreferences(raw), nil
+}
+
+// mergePatch applies an RFC 7386 JSON merge patch to target. Objects are
+// merged recursively and null values delete keys.
+func mergePatch(target map[string]interface{}, patch map[string]interface{}) map[string]interface{} {
+	if target == nil {
+		target = map[string]interface{}{}
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+
+		patchObj, ok := value.(map[string]interface{})
+		if !ok {
+			target[key] = value
+			continue
+		}
+
+		targetObj, _ := target[key].(map[string]interface{})
+		target[key] = mergePatch(targetObj, patchObj)
+	}
+
+	return target
+}
+
+// applyPreferencesPatch merges patch into current and strictly decodes the
+// result, rejecting keys that aren't part of the schema
+func applyPreferencesPatch(current Preferences, patch map[string]interface{}) (Preferences, error) {
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return Preferences{}, fmt.Errorf("failed to encode preferences: %w", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(currentJSON, &doc); err != nil {
+		return Preferences{}, fmt.Errorf("failed to encode preferences: %w", err)
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return Preferences{}, fmt.Errorf("failed to encode preferences: %w", err)
+	}
+
+	// Keys removed with null fall back to their defaults
+	prefs := DefaultPreferences()
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&prefs); err != nil {
+		return Preferences{}, fmt.Errorf("invalid preferences: %w", err)
+	}
+
+	if err := prefs.Validate(); err != nil {
+		return Preferences{}, fmt.Errorf("invalid preferences: %w", err)
+	}
+
+	return prefs, nil
+}
+
+// GetPreferences returns the user's typed preferences
+func (s *UserService) GetPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	prefs, err := getPreferences(ctx, s.db.Pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// UpdatePreferences applies a JSON merge patch to the user's preferences,
+// validates the result and stores it
+func (s *UserService) UpdatePreferences(ctx context.Context, userID string, patch map[string]interface{}) (*Preferences, error) {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the row so concurrent patches don't overwrite each other
+	var raw []byte
+	err = tx.QueryRow(ctx, `
+		SELECT preferences FROM profiles WHERE id = $1 FOR UPDATE
+	`, userID).Scan(&raw)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("profile not found")
+		}
+		return nil, fmt.Errorf("failed to load preferences: %w", err)
+	}
+
+	prefs, err := applyPreferencesPatch(decodePreferences(raw), patch)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(prefs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preferences: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE profiles SET preferences = $2, updated_at = NOW() WHERE id = $1
+	`, userID, encoded)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to update preferences")
+		return nil, fmt.Errorf("failed to update preferences: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info().Str("user_id", userID).Msg("Preferences updated")
+	return &prefs, nil
+}
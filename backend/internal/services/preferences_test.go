@@ -0,0 +1,133 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietHoursUntil(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	at := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, la)
+	}
+
+	overnight := QuietHours{Enabled: true, Start: "22:00", End: "07:00", Timezone: "America/Los_Angeles"}
+	daytime := QuietHours{Enabled: true, Start: "09:00", End: "17:30", Timezone: "America/Los_Angeles"}
+
+	tests := []struct {
+		name      string
+		q         QuietHours
+		now       time.Time
+		wantQuiet bool
+		wantUntil time.Time
+	}{
+		{"before wrapping window", overnight, at(2026, 6, 10, 21, 59), false, time.Time{}},
+		{"wrapping window start", overnight, at(2026, 6, 10, 22, 0), true, at(2026, 6, 11, 7, 0)},
+		{"wrapping window before midnight", overnight, at(2026, 6, 10, 23, 45), true, at(2026, 6, 11, 7, 0)},
+		{"wrapping window after midnight", overnight, at(2026, 6, 11, 3, 0), true, at(2026, 6, 11, 7, 0)},
+		{"wrapping window end", overnight, at(2026, 6, 11, 7, 0), false, time.Time{}},
+		{"wrapping window across month", overnight, at(2026, 6, 30, 23, 0), true, at(2026, 7, 1, 7, 0)},
+		{"daytime window", daytime, at(2026, 6, 10, 12, 0), true, at(2026, 6, 10, 17, 30)},
+		{"daytime window start", daytime, at(2026, 6, 10, 9, 0), true, at(2026, 6, 10, 17, 30)},
+		{"daytime window end", daytime, at(2026, 6, 10, 17, 30), false, time.Time{}},
+		{"outside daytime window", daytime, at(2026, 6, 10, 8, 0), false, time.Time{}},
+
+		// DST starts 2026-03-08 at 02:00 and ends 2026-11-01 at 02:00 in Los Angeles
+		{"spring forward", overnight, at(2026, 3, 8, 1, 30), true, at(2026, 3, 8, 7, 0)},
+		{"spring forward after end", overnight, at(2026, 3, 8, 7, 30), false, time.Time{}},
+		{"fall back", overnight, at(2026, 11, 1, 1, 30), true, at(2026, 11, 1, 7, 0)},
+		{"fall back before end", overnight, at(2026, 11, 1, 6, 30), true, at(2026, 11, 1, 7, 0)},
+
+		{"disabled", QuietHours{Start: "00:00", End: "23:59", Timezone: "UTC"}, at(2026, 6, 10, 12, 0), false, time.Time{}},
+		{"empty window", QuietHours{Enabled: true, Start: "08:00", End: "08:00", Timezone: "UTC"}, at(2026, 6, 10, 8, 0), false, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quiet, until := tt.q.Until(tt.now)
+			if quiet != tt.wantQuiet {
+				t.Fatalf("quiet = %v, want %v", quiet, tt.wantQuiet)
+			}
+			if !until.Equal(tt.wantUntil) {
+				t.Errorf("until = %v, want %v", until, tt.wantUntil)
+			}
+		})
+	}
+}
+
+func TestQuietHoursUntilUsesUserTimezone(t *testing.T) {
+	q := QuietHours{Enabled: true, Start: "22:00", End: "07:00", Timezone: "Asia/Tokyo"}
+
+	// 14:00 UTC is 23:00 in Tokyo
+	now := time.Date(2026, 6, 10, 14, 0, 0, 0, time.UTC)
+	quiet, until := q.Until(now)
+	if !quiet {
+		t.Fatal("expected quiet hours in Tokyo")
+	}
+	if want := time.Date(2026, 6, 10, 22, 0, 0, 0, time.UTC); !until.Equal(want) {
+		t.Errorf("until = %v, want %v", until, want)
+	}
+}
+
+func TestApplyPreferencesPatch(t *testing.T) {
+	current := DefaultPreferences()
+	current.QuietHours.Enabled = true
+	current.DailyPushCap = 20
+
+	prefs, err := applyPreferencesPatch(current, map[string]interface{}{
+		"noise_tolerance": "quiet",
+		"quiet_hours":     map[string]interface{}{"start": "23:00"},
+		"daily_push_cap":  nil,
+	})
+	if err != nil {
+		t.Fatalf("applyPreferencesPatch: %v", err)
+	}
+
+	if prefs.NoiseTolerance != "quiet" {
+		t.Errorf("noise_tolerance = %q", prefs.NoiseTolerance)
+	}
+	// Nested objects are merged, not replaced
+	if prefs.QuietHours.Start != "23:00" || prefs.QuietHours.End != "07:00" || !prefs.QuietHours.Enabled {
+		t.Errorf("quiet_hours = %+v", prefs.QuietHours)
+	}
+	// null resets a key to its default
+	if prefs.DailyPushCap != 0 {
+		t.Errorf("daily_push_cap = %d, want default 0", prefs.DailyPushCap)
+	}
+	// The current preferences are left untouched
+	if current.NoiseTolerance != "moderate" || current.DailyPushCap != 20 {
+		t.Errorf("current was modified: %+v", current)
+	}
+}
+
+func TestApplyPreferencesPatchRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch map[string]interface{}
+		want  string
+	}{
+		{"unknown key", map[string]interface{}{"theme": "dark"}, "unknown field"},
+		{"unknown nested key", map[string]interface{}{"notifications": map[string]interface{}{"email": true}}, "unknown field"},
+		{"wrong type", map[string]interface{}{"outlet_required": "yes"}, "invalid preferences"},
+		{"noise tolerance", map[string]interface{}{"noise_tolerance": "silent"}, "noise_tolerance"},
+		{"auto checkout", map[string]interface{}{"auto_checkout_hours": float64(13)}, "auto_checkout_hours"},
+		{"push cap", map[string]interface{}{"daily_push_cap": float64(-1)}, "daily_push_cap"},
+		{"clock", map[string]interface{}{"quiet_hours": map[string]interface{}{"start": "25:00"}}, "quiet_hours.start"},
+		{"timezone", map[string]interface{}{"quiet_hours": map[string]interface{}{"timezone": "Mars/Olympus"}}, "quiet_hours.timezone"},
+		{"empty timezone", map[string]interface{}{"quiet_hours": map[string]interface{}{"timezone": ""}}, "quiet_hours.timezone"},
+		{"mode", map[string]interface{}{"quiet_hours": map[string]interface{}{"mode": "snooze"}}, "quiet_hours.mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := applyPreferencesPatch(DefaultPreferences(), tt.patch)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
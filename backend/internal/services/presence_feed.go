@@ -0,0 +1,473 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/events"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/rs/zerolog/log"
+)
+
+// Presence update types, sent as the SSE event name
+const (
+	PresenceUpdated        = "presence"
+	PresenceLocationHidden = "location_hidden"
+	PresenceFriendRemoved  = "friend_removed"
+)
+
+// Presence changes carried by PresenceUpdated
+const (
+	PresenceChangeCheckedIn       = "checked_in"
+	PresenceChangeCheckedOut      = "checked_out"
+	PresenceChangeMoved           = "moved"
+	PresenceChangeLocationVisible = "location_visible"
+	PresenceChangePrecision       = "precision_changed"
+	PresenceChangeFriendAdded     = "friend_added"
+)
+
+// presenceFeedBuffer is how many updates a slow subscriber may fall behind
+// before it is dropped. The client reconnects and gets a fresh snapshot.
+const presenceFeedBuffer = 64
+
+// PresenceUpdate is a change in what a subscriber can see of one friend.
+// Friend is the friend as GetFriends would now return them; it is omitted
+// for location_hidden and friend_removed.
+type PresenceUpdate struct {
+	Type   string              `json:"type"`
+	Change string              `json:"change,omitempty"`
+	UserID string              `json:"user_id"`
+	Friend *FriendWithLocation `json:"friend,omitempty"`
+	At     time.Time           `json:"at"`
+}
+
+// presenceState is what a subscriber last saw of a friend
+type presenceState struct {
+	level     string
+	precision string
+	place     string
+}
+
+// PresenceSubscription receives presence updates for one user's friends.
+// Updates is closed when the subscription ends, including when the
+// subscriber falls too far behind.
+type PresenceSubscription struct {
+	UserID  string
+	Updates <-chan PresenceUpdate
+	updates chan PresenceUpdate
+
+	mu    sync.Mutex
+	ready bool
+	dirty bool
+	seen  map[string]presenceState
+}
+
+// PresenceFeed pushes friends' check-ins, checkouts and visibility changes to
+// stream subscribers. Each user.presence_changed event is re-evaluated against
+// location_visibility() for every local subscriber, so the stream applies the
+// same sharing, grant, group and block rules as GetFriends. Time-based changes
+// such as expiring share grants are caught by a periodic resync.
+type PresenceFeed struct {
+	db             *database.Database
+	resyncInterval time.Duration
+
+	mu        sync.Mutex
+	subs      map[*PresenceSubscription]struct{}
+	changed   map[string]bool
+	resyncAll bool
+	wake      chan struct{}
+}
+
+// NewPresenceFeed creates a new presence feed fed by user.presence_changed
+// events from every replica. Subscribers are fully resynced every
+// PRESENCE_RESYNC_INTERVAL (default 1m).
+func NewPresenceFeed(db *database.Database, bus events.Bus) *PresenceFeed {
+	f := &PresenceFeed{
+		db:             db,
+		resyncInterval: envDuration("PRESENCE_RESYNC_INTERVAL", time.Minute),
+		subs:           map[*PresenceSubscription]struct{}{},
+		changed:        map[string]bool{},
+		wake:           make(chan struct{}, 1),
+	}
+
+	bus.Subscribe(events.PresenceChanged, f.handlePresenceChanged)
+	bus.Subscribe(events.BusReconnected, f.handleReconnected)
+
+	return f
+}
+
+// handlePresenceChanged queues the user for evaluation. The bus dispatches
+// from its listener, so the database work happens in Run.
+func (f *PresenceFeed) handlePresenceChanged(ctx context.Context, e events.Event) {
+	var event PresenceChangedEvent
+	if err := e.Decode(&event); err != nil {
+		log.Error().Err(err).Str("event_id", e.ID).Msg("Failed to decode presence event")
+		return
+	}
+
+	f.mu.Lock()
+	f.changed[event.UserID] = true
+	f.mu.Unlock()
+	f.signal()
+}
+
+// handleReconnected resyncs every subscriber, since changes published while
+// the bus was down were missed
+func (f *PresenceFeed) handleReconnected(ctx context.Context, e events.Event) {
+	f.mu.Lock()
+	f.resyncAll = true
+	f.mu.Unlock()
+	f.signal()
+}
+
+func (f *PresenceFeed) signal() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Subscribe starts receiving presence updates for the user's friends. Call
+// Snapshot next; updates are held back until it has been taken.
+func (f *PresenceFeed) Subscribe(userID string) *PresenceSubscription {
+	updates := make(chan PresenceUpdate, presenceFeedBuffer)
+	sub := &PresenceSubscription{
+		UserID:  userID,
+		Updates: updates,
+		updates: updates,
+		seen:    map[string]presenceState{},
+	}
+
+	f.mu.Lock()
+	f.subs[sub] = struct{}{}
+	f.mu.Unlock()
+
+	return sub
+}
+
+// Unsubscribe stops a subscription. Safe to call more than once.
+func (f *PresenceFeed) Unsubscribe(sub *PresenceSubscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subs[sub]; ok {
+		delete(f.subs, sub)
+		close(sub.updates)
+	}
+}
+
+// Snapshot returns the subscriber's friends as currently visible to them and
+// starts live updates from that state. Changes that happened while the
+// snapshot was taken are sent as updates.
+func (f *PresenceFeed) Snapshot(ctx context.Context, sub *PresenceSubscription) ([]FriendWithLocation, error) {
+	rows, err := f.query(ctx, []string{sub.UserID}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	friends := []FriendWithLocation{}
+	sub.mu.Lock()
+	for _, row := range rows {
+		friends = append(friends, *row.friend)
+		sub.seen[row.friend.ID] = row.state()
+	}
+	sub.ready = true
+	dirty := sub.dirty
+	sub.dirty = false
+	sub.mu.Unlock()
+
+	if dirty {
+		f.resync(ctx, []*PresenceSubscription{sub})
+	}
+
+	return friends, nil
+}
+
+// Run evaluates queued presence changes and periodically resyncs every
+// subscriber until ctx is cancelled
+func (f *PresenceFeed) Run(ctx context.Context) {
+	log.Info().Dur("resync_interval", f.resyncInterval).Msg("Presence feed started")
+
+	ticker := time.NewTicker(f.resyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Presence feed stopped")
+			return
+		case <-f.wake:
+		case <-ticker.C:
+			f.mu.Lock()
+			f.resyncAll = true
+			f.mu.Unlock()
+		}
+
+		f.mu.Lock()
+		changed, resyncAll := f.changed, f.resyncAll
+		f.changed, f.resyncAll = map[string]bool{}, false
+		f.mu.Unlock()
+
+		if resyncAll {
+			f.resync(ctx, f.subscribers())
+			continue
+		}
+
+		for userID := range changed {
+			f.evaluate(ctx, userID)
+		}
+	}
+}
+
+// subscribers returns the current subscriptions
+func (f *PresenceFeed) subscribers() []*PresenceSubscription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	subs := make([]*PresenceSubscription, 0, len(f.subs))
+	for sub := range f.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// evaluate sends updates about one user to every subscriber who could see them
+func (f *PresenceFeed) evaluate(ctx context.Context, subjectID string) {
+	subs := f.subscribers()
+	if len(subs) == 0 {
+		return
+	}
+
+	rows, err := f.query(ctx, viewerIDs(subs), subjectID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", subjectID).Msg("Failed to evaluate presence change")
+		return
+	}
+
+	byViewer := make(map[string]*presenceRow, len(rows))
+	for i := range rows {
+		byViewer[rows[i].viewerID] = &rows[i]
+	}
+
+	for _, sub := range subs {
+		if sub.UserID == subjectID {
+			continue
+		}
+
+		sub.mu.Lock()
+		if !sub.ready {
+			sub.dirty = true
+			sub.mu.Unlock()
+			continue
+		}
+		update := f.apply(sub, subjectID, byViewer[sub.UserID])
+		sub.mu.Unlock()
+
+		if update != nil {
+			f.send(sub, *update)
+		}
+	}
+}
+
+// resync compares each subscriber's view of all their friends with what they
+// were last sent, and sends the differences
+func (f *PresenceFeed) resync(ctx context.Context, subs []*PresenceSubscription) {
+	if len(subs) == 0 {
+		return
+	}
+
+	rows, err := f.query(ctx, viewerIDs(subs), "")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to resync presence")
+		return
+	}
+
+	byViewer := map[string]map[string]*presenceRow{}
+	for i := range rows {
+		row := &rows[i]
+		if byViewer[row.viewerID] == nil {
+			byViewer[row.viewerID] = map[string]*presenceRow{}
+		}
+		byViewer[row.viewerID][row.friend.ID] = row
+	}
+
+	for _, sub := range subs {
+		sub.mu.Lock()
+		if !sub.ready {
+			sub.dirty = true
+			sub.mu.Unlock()
+			continue
+		}
+
+		current := byViewer[sub.UserID]
+		friendIDs := map[string]bool{}
+		for id := range sub.seen {
+			friendIDs[id] = true
+		}
+		for id := range current {
+			friendIDs[id] = true
+		}
+
+		updates := []PresenceUpdate{}
+		for id := range friendIDs {
+			if update := f.apply(sub, id, current[id]); update != nil {
+				updates = append(updates, *update)
+			}
+		}
+		sub.mu.Unlock()
+
+		for _, update := range updates {
+			f.send(sub, update)
+		}
+	}
+}
+
+// apply records a friend's current presence for the subscriber and returns
+// the update to send, if anything visible changed. row is nil when the user
+// is no longer the subscriber's friend. Caller holds sub.mu.
+func (f *PresenceFeed) apply(sub *PresenceSubscription, friendID string, row *presenceRow) *PresenceUpdate {
+	prev, known := sub.seen[friendID]
+	update := &PresenceUpdate{UserID: friendID, At: time.Now().UTC()}
+
+	if row == nil {
+		if !known {
+			return nil
+		}
+		delete(sub.seen, friendID)
+		update.Type = PresenceFriendRemoved
+		return update
+	}
+
+	cur := row.state()
+	sub.seen[friendID] = cur
+
+	if cur.level == VisibilityHidden {
+		switch {
+		case !known:
+			update.Type = PresenceUpdated
+			update.Change = PresenceChangeFriendAdded
+			update.Friend = row.friend
+			return update
+		case prev.level != VisibilityHidden:
+			update.Type = PresenceLocationHidden
+			return update
+		}
+		return nil
+	}
+
+	update.Type = PresenceUpdated
+	update.Friend = row.friend
+
+	switch {
+	case !known:
+		update.Change = PresenceChangeFriendAdded
+	case prev.level == VisibilityHidden:
+		update.Change = PresenceChangeLocationVisible
+	case prev.place == "" && cur.place != "":
+		update.Change = PresenceChangeCheckedIn
+	case prev.place != "" && cur.place == "":
+		update.Change = PresenceChangeCheckedOut
+	case prev.precision != cur.precision:
+		update.Change = PresenceChangePrecision
+	case prev.place != cur.place:
+		update.Change = PresenceChangeMoved
+	default:
+		return nil
+	}
+
+	return update
+}
+
+// send delivers an update without blocking. Subscribers whose buffer is full
+// are dropped.
+func (f *PresenceFeed) send(sub *PresenceSubscription, update PresenceUpdate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subs[sub]; !ok {
+		return
+	}
+
+	select {
+	case sub.updates <- update:
+	default:
+		log.Warn().Str("user_id", sub.UserID).Msg("Presence subscriber too slow, dropping")
+		delete(f.subs, sub)
+		close(sub.updates)
+	}
+}
+
+// presenceRow is one friend as seen by one viewer
+type presenceRow struct {
+	viewerID string
+	level    string
+	friend   *FriendWithLocation
+}
+
+func (r *presenceRow) state() presenceState {
+	state := presenceState{level: r.level}
+	if spot := r.friend.CurrentSpot; spot != nil {
+		state.precision = spot.Precision
+		state.place = spot.ID
+		if spot.Precision == VisibilityBuilding {
+			state.place = spot.BuildingName
+		}
+	}
+	return state
+}
+
+// query returns the accepted friends of each viewer, with their location as
+// visible to that viewer. If subjectID is set only that friend is returned.
+func (f *PresenceFeed) query(ctx context.Context, viewerIDs []string, subjectID string) ([]presenceRow, error) {
+	rows, err := f.db.Pool.Query(ctx, `
+		SELECT
+			p.id, p.username, p.full_name, p.avatar_url,
+			v.level,
+			CASE WHEN v.level = 'hidden' THEN NULL ELSE p.checked_in_at END AS checked_in_at,
+			s.id, s.name, s.building_name,
+			ST_Y(loc.point) as latitude,
+			ST_X(loc.point) as longitude,
+			v.level,
+			viewer.id
+		FROM unnest($1::uuid[]) AS viewer(id)
+		JOIN friendships f ON (f.user_id = viewer.id OR f.friend_id = viewer.id) AND f.status = 'accepted'
+		JOIN profiles p ON p.id = CASE WHEN f.user_id = viewer.id THEN f.friend_id ELSE f.user_id END
+		CROSS JOIN LATERAL (SELECT location_visibility(p.id, viewer.id) AS level) v
+		LEFT JOIN spots s ON s.id = p.current_spot_id AND v.level != 'hidden'
+		LEFT JOIN LATERAL (`+friendLocationPointSQL+`) loc ON true
+		WHERE $2 = '' OR p.id::text = $2
+	`, viewerIDs, subjectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query presence: %w", err)
+	}
+	defer rows.Close()
+
+	result := []presenceRow{}
+	for rows.Next() {
+		var row presenceRow
+		friend, err := scanFriendWithLocation(rows, &row.level, &row.viewerID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan presence")
+			continue
+		}
+		row.friend = friend
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+// viewerIDs returns the distinct users behind the subscriptions
+func viewerIDs(subs []*PresenceSubscription) []string {
+	seen := map[string]bool{}
+	ids := []string{}
+	for _, sub := range subs {
+		if !seen[sub.UserID] {
+			seen[sub.UserID] = true
+			ids = append(ids, sub.UserID)
+		}
+	}
+	return ids
+}
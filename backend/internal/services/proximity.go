@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/rs/zerolog/log"
+)
+
+// ProximityService alerts friends when they are studying near each other
+type ProximityService struct {
+	db            *database.Database
+	notifications *NotificationService
+	radius        int
+}
+
+// NewProximityService creates a new proximity service. Friends within
+// FRIEND_NEARBY_RADIUS_METERS (default 400m) of a check-in are alerted.
+func NewProximityService(db *database.Database, notifications *NotificationService) *ProximityService {
+	return &ProximityService{
+		db:            db,
+		notifications: notifications,
+		radius:        envInt("FRIEND_NEARBY_RADIUS_METERS", 400),
+	}
+}
+
+// nearbyFriend is a checked-in friend near a check-in, with what each side
+// is allowed to see of the other
+type nearbyFriend struct {
+	ID           string
+	Name         string
+	SpotName     string
+	BuildingName *string
+	Distance     float64
+	TheySeeMe    string
+	ISeeThem     string
+}
+
+// AlertNearbyFriends runs after userID checks in at spotID. Each checked-in
+// friend within range is told the user arrived, and the user is told about
+// each of them, as far as location sharing allows in each direction, if
+// they have opted in to friend nearby alerts.
+// Alerts are sent at most once per pair per day and skipped during the
+// recipient's quiet hours, since a deferred "nearby" alert would be stale.
+func (s *ProximityService) AlertNearbyFriends(ctx context.Context, userID, spotID string) error {
+	me, err := displayName(ctx, s.db.Pool, userID)
+	if err != nil {
+		return err
+	}
+
+	var mySpot string
+	var myBuilding *string
+	err = s.db.Pool.QueryRow(ctx, `
+		SELECT name, building_name FROM spots WHERE id = $1
+	`, spotID).Scan(&mySpot, &myBuilding)
+	if err != nil {
+		return fmt.Errorf("failed to get spot: %w", err)
+	}
+
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT
+			p.id, COALESCE(NULLIF(p.full_name, ''), p.username),
+			fs.name, fs.building_name,
+			ST_Distance(fs.location::geography, ms.location::geography) AS distance_meters,
+			location_visibility($1, p.id), location_visibility(p.id, $1)
+		FROM friendships f
+		JOIN profiles p ON p.id = CASE WHEN f.user_id = $1 THEN f.friend_id ELSE f.user_id END
+		JOIN spots fs ON fs.id = p.current_spot_id
+		JOIN spots ms ON ms.id = $2
+		WHERE (f.user_id = $1 OR f.friend_id = $1) AND f.status = 'accepted'
+		AND ST_DWithin(fs.location::geography, ms.location::geography, $3)
+		ORDER BY distance_meters
+		LIMIT 50
+	`, userID, spotID, s.radius)
+	if err != nil {
+		return fmt.Errorf("failed to find nearby friends: %w", err)
+	}
+
+	friends := []nearbyFriend{}
+	for rows.Next() {
+		var f nearbyFriend
+		err := rows.Scan(&f.ID, &f.Name, &f.SpotName, &f.BuildingName, &f.Distance, &f.TheySeeMe, &f.ISeeThem)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan nearby friend")
+			continue
+		}
+		friends = append(friends, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find nearby friends: %w", err)
+	}
+
+	for _, f := range friends {
+		// Tell the friend that the user arrived nearby
+		if f.TheySeeMe != VisibilityHidden {
+			body := nearbyBody(me, mySpot, myBuilding, f.Distance, f.TheySeeMe)
+			if err := s.alert(ctx, f.ID, userID, body, spotID, f.TheySeeMe); err != nil {
+				log.Error().Err(err).Str("recipient_id", f.ID).Msg("Failed to send friend nearby alert")
+			}
+		}
+
+		// Tell the user who is already studying nearby
+		if f.ISeeThem != VisibilityHidden {
+			body := nearbyBody(f.Name, f.SpotName, f.BuildingName, f.Distance, f.ISeeThem)
+			if err := s.alert(ctx, userID, f.ID, body, "", f.ISeeThem); err != nil {
+				log.Error().Err(err).Str("recipient_id", userID).Msg("Failed to send friend nearby alert")
+			}
+		}
+	}
+
+	return nil
+}
+
+// alert enqueues one friend_nearby notification unless the pair was already
+// alerted today or the recipient is in quiet hours or has opted out
+func (s *ProximityService) alert(ctx context.Context, recipientID, subjectID, body, spotID, visibility string) error {
+	prefs, err := getPreferences(ctx, s.db.Pool, recipientID)
+	if err != nil {
+		return err
+	}
+
+	if !prefs.NotificationsEnabled || !prefs.Allows(NotificationFriendNearby) {
+		return nil
+	}
+	if quiet, _ := prefs.QuietHours.Until(time.Now()); quiet {
+		return nil
+	}
+
+	today := time.Now().In(prefs.QuietHours.Location()).Format("2006-01-02")
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO friend_nearby_alerts (recipient_id, subject_id, alert_date)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, recipientID, subjectID, today)
+	if err != nil {
+		return fmt.Errorf("failed to record nearby alert: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	data := models.JSONB{"user_id": subjectID, "precision": visibility}
+	if spotID != "" && visibility == VisibilityExact {
+		data["spot_id"] = spotID
+	}
+
+	_, err = s.notifications.Enqueue(ctx, tx, NewNotification{
+		UserID:  recipientID,
+		ActorID: subjectID,
+		Type:    NotificationFriendNearby,
+		Title:   "A friend is nearby",
+		Body:    body,
+		Data:    data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// nearbyBody describes where a friend is studying, at spot or building
+// precision, e.g. "Maya is studying at Odegaard Reading Room, 2 min away"
+func nearbyBody(name, spot string, building *string, distance float64, visibility string) string {
+	if visibility == VisibilityBuilding {
+		distance = math.Round(distance/50) * 50
+		if building != nil && *building != "" {
+			return fmt.Sprintf("%s is studying in %s, about %d min away", name, *building, walkingMinutes(distance))
+		}
+		return fmt.Sprintf("%s is studying nearby, about %d min away", name, walkingMinutes(distance))
+	}
+	return fmt.Sprintf("%s is studying at %s, %d min away", name, spot, walkingMinutes(distance))
+}
@@ -0,0 +1,450 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/internal/push"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/rs/zerolog/log"
+)
+
+// PushSender delivers push messages to devices. Send returns one ticket per
+// message in order; a non-nil error means the whole batch failed.
+type PushSender interface {
+	Send(ctx context.Context, messages []push.Message) ([]push.Ticket, error)
+	MaxBatchSize() int
+}
+
+// pushClaimLease is how long a claimed notification is hidden from other
+// dispatchers. If a replica dies mid-send the row becomes due again after it.
+const pushClaimLease = 2 * time.Minute
+
+// devicePruneInterval is how often the dispatcher prunes stale devices
+const devicePruneInterval = time.Hour
+
+// PushDispatcher drains pending notifications and delivers them as pushes to
+// each of the recipient's active devices
+type PushDispatcher struct {
+	db          *database.Database
+	devices     *DeviceService
+	sender      PushSender
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+// NewPushDispatcher creates a push dispatcher with settings read from the environment
+func NewPushDispatcher(db *database.Database, devices *DeviceService, sender PushSender) *PushDispatcher {
+	return &PushDispatcher{
+		db:          db,
+		devices:     devices,
+		sender:      sender,
+		interval:    envDuration("PUSH_DISPATCH_INTERVAL", 5*time.Second),
+		batchSize:   envInt("PUSH_DISPATCH_BATCH_SIZE", 500),
+		maxAttempts: envInt("PUSH_MAX_ATTEMPTS", 5),
+		baseBackoff: envDuration("PUSH_RETRY_BACKOFF", 30*time.Second),
+		maxBackoff:  envDuration("PUSH_RETRY_MAX_BACKOFF", time.Hour),
+	}
+}
+
+// pendingPush is a claimed notification awaiting delivery
+type pendingPush struct {
+	ID       string
+	UserID   string
+	Type     string
+	Title    string
+	Body     string
+	Data     models.JSONB
+	Attempts int
+}
+
+// pushTarget is one notification addressed to one device
+type pushTarget struct {
+	push   *pendingPush
+	device activeDevice
+}
+
+// pushOutcome accumulates the per-device results for a notification
+type pushOutcome struct {
+	delivered bool
+	retryable bool
+	lastError string
+}
+
+// Run dispatches pending notifications until ctx is cancelled
+func (d *PushDispatcher) Run(ctx context.Context) {
+	log.Info().Dur("interval", d.interval).Msg("Push dispatcher started")
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		// Keep draining while full batches are being claimed
+		for {
+			n, err := d.DispatchPending(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("Push dispatch failed")
+				break
+			}
+			if n < d.batchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPrune) > devicePruneInterval {
+			if _, err := d.devices.PruneStale(ctx); err != nil {
+				log.Error().Err(err).Msg("Device pruning failed")
+			}
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Push dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchPending claims one batch of due notifications, sends them to every
+// active device of each recipient and records the outcome. A notification is
+// sent once any device accepts it; it is only retried if no device accepted
+// it and at least one failure was transient. Returns the number claimed.
+func (d *PushDispatcher) DispatchPending(ctx context.Context) (int, error) {
+	pending, err := d.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	userIDs := make([]string, 0, len(pending))
+	for _, p := range pending {
+		userIDs = append(userIDs, p.UserID)
+	}
+
+	// On error the claim lease expires and the batch is retried
+	devices, err := d.devices.activeDevices(ctx, userIDs)
+	if err != nil {
+		return len(pending), err
+	}
+
+	prefs, err := d.loadPreferences(ctx, userIDs)
+	if err != nil {
+		return len(pending), err
+	}
+
+	sentToday, err := d.sentToday(ctx, prefs)
+	if err != nil {
+		return len(pending), err
+	}
+
+	// Notifications without a device stay in the inbox but can't be pushed
+	now := time.Now()
+	targets := []pushTarget{}
+	outcomes := make(map[string]*pushOutcome, len(pending))
+	for i := range pending {
+		p := &pending[i]
+		userPrefs := prefs[p.UserID]
+
+		if !userPrefs.NotificationsEnabled {
+			d.markSuppressed(ctx, p.ID, "push notifications disabled")
+			continue
+		}
+
+		if quiet, until := userPrefs.QuietHours.Until(now); quiet {
+			if userPrefs.QuietHours.Mode == QuietHoursDrop {
+				d.markSuppressed(ctx, p.ID, "quiet hours")
+			} else {
+				d.deferUntil(ctx, p.ID, until)
+			}
+			continue
+		}
+
+		if userPrefs.DailyPushCap > 0 && sentToday[p.UserID] >= userPrefs.DailyPushCap {
+			d.markSuppressed(ctx, p.ID, "daily push cap reached")
+			continue
+		}
+		sentToday[p.UserID]++
+
+		outcomes[p.ID] = &pushOutcome{lastError: "no active devices"}
+		for _, device := range devices[p.UserID] {
+			targets = append(targets, pushTarget{push: p, device: device})
+		}
+	}
+
+	for _, deviceID := range deliver(ctx, d.sender, targets, outcomes) {
+		d.devices.removeDevice(ctx, deviceID)
+	}
+
+	for i := range pending {
+		p := pending[i]
+		outcome, ok := outcomes[p.ID]
+		if !ok {
+			continue
+		}
+
+		switch outcome.resolve(p.Attempts, d.maxAttempts) {
+		case pushSent:
+			d.markSent(ctx, p.ID)
+		case pushRetry:
+			d.scheduleRetry(ctx, p, outcome.lastError)
+		default:
+			d.markFailed(ctx, p.ID, outcome.lastError)
+		}
+	}
+
+	return len(pending), nil
+}
+
+// What happens to a notification after a dispatch attempt
+const (
+	pushSent   = "sent"
+	pushRetry  = "retry"
+	pushFailed = "failed"
+)
+
+// resolve decides the notification's next state once every device has been
+// tried: sent if any device accepted it, retried if a failure was transient
+// and attempts remain, failed otherwise
+func (o *pushOutcome) resolve(attempts, maxAttempts int) string {
+	switch {
+	case o.delivered:
+		return pushSent
+	case o.retryable && attempts < maxAttempts:
+		return pushRetry
+	default:
+		return pushFailed
+	}
+}
+
+// claim leases a batch of due pending notifications to this dispatcher
+func (d *PushDispatcher) claim(ctx context.Context) ([]pendingPush, error) {
+	rows, err := d.db.Pool.Query(ctx, `
+		WITH due AS (
+			SELECT id FROM notifications
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE notifications n
+		SET attempts = n.attempts + 1,
+		    next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due
+		WHERE n.id = due.id
+		RETURNING n.id, n.user_id, n.type, n.title, n.body, n.data, n.attempts
+	`, d.batchSize, pushClaimLease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim notifications: %w", err)
+	}
+	defer rows.Close()
+
+	pending := []pendingPush{}
+	for rows.Next() {
+		var p pendingPush
+		err := rows.Scan(&p.ID, &p.UserID, &p.Type, &p.Title, &p.Body, &p.Data, &p.Attempts)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan claimed notification")
+			continue
+		}
+		pending = append(pending, p)
+	}
+
+	return pending, rows.Err()
+}
+
+// deliver sends targets in sender-sized batches and records each result in
+// outcomes. It returns the devices the sender reported as no longer
+// registered, which must be removed.
+func deliver(ctx context.Context, sender PushSender, targets []pushTarget, outcomes map[string]*pushOutcome) []string {
+	unregistered := []string{}
+	batchSize := sender.MaxBatchSize()
+	for start := 0; start < len(targets); start += batchSize {
+		end := min(start+batchSize, len(targets))
+		unregistered = append(unregistered, sendBatch(ctx, sender, targets[start:end], outcomes)...)
+	}
+	return unregistered
+}
+
+// sendBatch sends one sender-sized batch and records each result
+func sendBatch(ctx context.Context, sender PushSender, batch []pushTarget, outcomes map[string]*pushOutcome) []string {
+	messages := make([]push.Message, len(batch))
+	for i, t := range batch {
+		data := map[string]interface{}{}
+		for k, v := range t.push.Data {
+			data[k] = v
+		}
+		data["notification_id"] = t.push.ID
+		data["type"] = t.push.Type
+
+		messages[i] = push.Message{
+			To:    t.device.PushToken,
+			Title: t.push.Title,
+			Body:  t.push.Body,
+			Data:  data,
+			Sound: "default",
+		}
+	}
+
+	tickets, err := sender.Send(ctx, messages)
+	if err != nil {
+		log.Warn().Err(err).Int("batch_size", len(batch)).Msg("Push batch failed")
+		for _, t := range batch {
+			outcome := outcomes[t.push.ID]
+			outcome.retryable = true
+			outcome.lastError = err.Error()
+		}
+		return nil
+	}
+
+	unregistered := []string{}
+	for i, t := range batch {
+		ticket := tickets[i]
+		outcome := outcomes[t.push.ID]
+		switch {
+		case ticket.Status == push.StatusOK:
+			outcome.delivered = true
+		case ticket.Error == push.ErrorDeviceNotRegistered:
+			unregistered = append(unregistered, t.device.ID)
+			outcome.lastError = ticket.Error + ": " + ticket.Message
+		default:
+			outcome.retryable = true
+			outcome.lastError = ticket.Error + ": " + ticket.Message
+		}
+	}
+	return unregistered
+}
+
+// backoff returns the delay before the given retry attempt
+func (d *PushDispatcher) backoff(attempts int) time.Duration {
+	delay := d.baseBackoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
+
+// scheduleRetry makes the notification due again after its backoff
+func (d *PushDispatcher) scheduleRetry(ctx context.Context, p pendingPush, reason string) {
+	_, err := d.db.Pool.Exec(ctx, `
+		UPDATE notifications
+		SET next_attempt_at = NOW() + make_interval(secs => $2), error_message = $3
+		WHERE id = $1
+	`, p.ID, d.backoff(p.Attempts).Seconds(), reason)
+	if err != nil {
+		log.Error().Err(err).Str("notification_id", p.ID).Msg("Failed to schedule push retry")
+	}
+}
+
+// loadPreferences returns the typed preferences of the given users
+func (d *PushDispatcher) loadPreferences(ctx context.Context, userIDs []string) (map[string]Preferences, error) {
+	rows, err := d.db.Pool.Query(ctx, `
+		SELECT id, preferences FROM profiles WHERE id = ANY($1::uuid[])
+	`, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load preferences: %w", err)
+	}
+	defer rows.Close()
+
+	prefs := make(map[string]Preferences, len(userIDs))
+	for _, id := range userIDs {
+		prefs[id] = DefaultPreferences()
+	}
+
+	for rows.Next() {
+		var id string
+		var raw []byte
+		if err := rows.Scan(&id, &raw); err != nil {
+			log.Error().Err(err).Msg("Failed to scan preferences")
+			continue
+		}
+		prefs[id] = decodePreferences(raw)
+	}
+
+	return prefs, rows.Err()
+}
+
+// sentToday counts pushes already sent today, in each user's timezone, for
+// users who have a daily cap
+func (d *PushDispatcher) sentToday(ctx context.Context, prefs map[string]Preferences) (map[string]int, error) {
+	counts := map[string]int{}
+	for userID, p := range prefs {
+		if p.DailyPushCap == 0 {
+			continue
+		}
+
+		local := time.Now().In(p.QuietHours.Location())
+		startOfDay := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+
+		var count int
+		err := d.db.Pool.QueryRow(ctx, `
+			SELECT COUNT(*) FROM notifications
+			WHERE user_id = $1 AND status = 'sent' AND sent_at >= $2
+		`, userID, startOfDay).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count sent pushes: %w", err)
+		}
+		counts[userID] = count
+	}
+
+	return counts, nil
+}
+
+// deferUntil postpones a notification until quiet hours end. The claim
+// doesn't count as a delivery attempt.
+func (d *PushDispatcher) deferUntil(ctx context.Context, notificationID string, until time.Time) {
+	_, err := d.db.Pool.Exec(ctx, `
+		UPDATE notifications
+		SET next_attempt_at = $2, attempts = GREATEST(attempts - 1, 0)
+		WHERE id = $1
+	`, notificationID, until)
+	if err != nil {
+		log.Error().Err(err).Str("notification_id", notificationID).Msg("Failed to defer push")
+	}
+}
+
+// markSuppressed records that the recipient's preferences blocked the push.
+// The notification stays in their inbox.
+func (d *PushDispatcher) markSuppressed(ctx context.Context, notificationID, reason string) {
+	_, err := d.db.Pool.Exec(ctx, `
+		UPDATE notifications
+		SET status = 'suppressed', error_message = $2
+		WHERE id = $1
+	`, notificationID, reason)
+	if err != nil {
+		log.Error().Err(err).Str("notification_id", notificationID).Msg("Failed to mark push suppressed")
+	}
+}
+
+// markSent records a successful delivery
+func (d *PushDispatcher) markSent(ctx context.Context, notificationID string) {
+	_, err := d.db.Pool.Exec(ctx, `
+		UPDATE notifications
+		SET status = 'sent', sent_at = NOW(), error_message = NULL
+		WHERE id = $1
+	`, notificationID)
+	if err != nil {
+		log.Error().Err(err).Str("notification_id", notificationID).Msg("Failed to mark push sent")
+	}
+}
+
+// markFailed records a permanent delivery failure
+func (d *PushDispatcher) markFailed(ctx context.Context, notificationID, reason string) {
+	_, err := d.db.Pool.Exec(ctx, `
+		UPDATE notifications
+		SET status = 'failed', error_message = $2
+		WHERE id = $1
+	`, notificationID, reason)
+	if err != nil {
+		log.Error().Err(err).Str("notification_id", notificationID).Msg("Failed to mark push failed")
+	}
+}
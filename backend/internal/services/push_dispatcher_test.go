@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/push"
+)
+
+// pushFixture builds one target per device for each notification, with fresh
+// outcomes as DispatchPending would
+func pushFixture(devicesPerPush map[string][]string) ([]pushTarget, map[string]*pushOutcome) {
+	targets := []pushTarget{}
+	outcomes := map[string]*pushOutcome{}
+	for id, tokens := range devicesPerPush {
+		p := &pendingPush{ID: id, UserID: "user-" + id, Type: "friend_request", Title: "Hi", Body: "There"}
+		outcomes[id] = &pushOutcome{lastError: "no active devices"}
+		for _, token := range tokens {
+			targets = append(targets, pushTarget{
+				push:   p,
+				device: activeDevice{ID: "device-" + token, UserID: p.UserID, PushToken: token},
+			})
+		}
+	}
+	return targets, outcomes
+}
+
+func TestDeliverBatchesBySenderLimit(t *testing.T) {
+	sender := push.NewFakeSender()
+	sender.SetMaxBatchSize(2)
+
+	tokens := []string{}
+	for i := 0; i < 5; i++ {
+		tokens = append(tokens, fmt.Sprintf("ExponentPushToken[%d]", i))
+	}
+	targets, outcomes := pushFixture(map[string][]string{"n1": tokens})
+
+	unregistered := deliver(context.Background(), sender, targets, outcomes)
+
+	if got := sender.BatchSizes(); !reflect.DeepEqual(got, []int{2, 2, 1}) {
+		t.Errorf("batch sizes = %v, want [2 2 1]", got)
+	}
+	if len(unregistered) != 0 {
+		t.Errorf("unregistered = %v, want none", unregistered)
+	}
+
+	sent := sender.Sent()
+	if len(sent) != 5 {
+		t.Fatalf("sent %d messages, want 5", len(sent))
+	}
+	if sent[0].Data["notification_id"] != "n1" || sent[0].Data["type"] != "friend_request" {
+		t.Errorf("message data = %v", sent[0].Data)
+	}
+	if got := outcomes["n1"].resolve(1, 5); got != pushSent {
+		t.Errorf("resolve = %q, want %q", got, pushSent)
+	}
+}
+
+func TestDeliverPrunesUnregisteredDevices(t *testing.T) {
+	sender := push.NewFakeSender()
+	sender.FailToken("dead", push.ErrorDeviceNotRegistered)
+
+	targets, outcomes := pushFixture(map[string][]string{
+		"partial": {"dead", "alive"},
+		"gone":    {"dead"},
+	})
+
+	unregistered := deliver(context.Background(), sender, targets, outcomes)
+
+	if !reflect.DeepEqual(unregistered, []string{"device-dead", "device-dead"}) {
+		t.Errorf("unregistered = %v, want device-dead for each failed message", unregistered)
+	}
+
+	// One live device is enough for the notification to count as sent
+	if got := outcomes["partial"].resolve(1, 5); got != pushSent {
+		t.Errorf("partial resolve = %q, want %q", got, pushSent)
+	}
+
+	// An unregistered token is permanent, so the notification isn't retried
+	if got := outcomes["gone"].resolve(1, 5); got != pushFailed {
+		t.Errorf("gone resolve = %q, want %q", got, pushFailed)
+	}
+	if outcomes["gone"].lastError == "" {
+		t.Error("expected the failure to be recorded for error_message")
+	}
+}
+
+func TestDeliverRetriesTransientFailures(t *testing.T) {
+	sender := push.NewFakeSender()
+	sender.FailToken("flaky", "MessageRateExceeded")
+
+	targets, outcomes := pushFixture(map[string][]string{"n1": {"flaky"}})
+	deliver(context.Background(), sender, targets, outcomes)
+
+	outcome := outcomes["n1"]
+	if got := outcome.resolve(1, 3); got != pushRetry {
+		t.Errorf("resolve after attempt 1 = %q, want %q", got, pushRetry)
+	}
+	if got := outcome.resolve(3, 3); got != pushFailed {
+		t.Errorf("resolve after last attempt = %q, want %q", got, pushFailed)
+	}
+	if outcome.lastError != "MessageRateExceeded: fake failure" {
+		t.Errorf("lastError = %q", outcome.lastError)
+	}
+}
+
+func TestDeliverRetriesFailedBatches(t *testing.T) {
+	sender := push.NewFakeSender()
+	sender.FailAll(errors.New("push service returned status 503"))
+
+	targets, outcomes := pushFixture(map[string][]string{"n1": {"a"}, "n2": {"b"}})
+	unregistered := deliver(context.Background(), sender, targets, outcomes)
+
+	if len(unregistered) != 0 {
+		t.Errorf("unregistered = %v, want none", unregistered)
+	}
+	for id, outcome := range outcomes {
+		if got := outcome.resolve(1, 5); got != pushRetry {
+			t.Errorf("%s resolve = %q, want %q", id, got, pushRetry)
+		}
+		if outcome.lastError != "push service returned status 503" {
+			t.Errorf("%s lastError = %q", id, outcome.lastError)
+		}
+	}
+}
+
+func TestPushWithoutDevicesFails(t *testing.T) {
+	_, outcomes := pushFixture(map[string][]string{"n1": nil})
+
+	outcome := outcomes["n1"]
+	if got := outcome.resolve(1, 5); got != pushFailed {
+		t.Errorf("resolve = %q, want %q", got, pushFailed)
+	}
+	if outcome.lastError != "no active devices" {
+		t.Errorf("lastError = %q", outcome.lastError)
+	}
+}
+
+func TestPushBackoff(t *testing.T) {
+	d := &PushDispatcher{baseBackoff: 30 * time.Second, maxBackoff: 5 * time.Minute}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{50, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverThroughExpo(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		var messages []push.Message
+		if err := json.NewDecoder(r.Body).Decode(&messages); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+
+		data := []map[string]interface{}{}
+		for _, m := range messages {
+			if m.To == "ExponentPushToken[dead]" {
+				data = append(data, map[string]interface{}{
+					"status":  "error",
+					"message": "not registered",
+					"details": map[string]string{"error": push.ErrorDeviceNotRegistered},
+				})
+				continue
+			}
+			data = append(data, map[string]interface{}{"status": "ok", "id": "ticket"})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	sender := push.NewExpoSender(server.URL, "")
+
+	// More targets than Expo accepts in one request
+	tokens := []string{"ExponentPushToken[dead]"}
+	for i := 0; i < sender.MaxBatchSize(); i++ {
+		tokens = append(tokens, fmt.Sprintf("ExponentPushToken[%d]", i))
+	}
+	targets, outcomes := pushFixture(map[string][]string{"n1": tokens, "n2": {"ExponentPushToken[dead]"}})
+
+	unregistered := deliver(context.Background(), sender, targets, outcomes)
+
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+	if len(unregistered) != 2 {
+		t.Errorf("unregistered = %v, want the dead device once per notification", unregistered)
+	}
+	if got := outcomes["n1"].resolve(1, 5); got != pushSent {
+		t.Errorf("n1 resolve = %q, want %q", got, pushSent)
+	}
+	if got := outcomes["n2"].resolve(1, 5); got != pushFailed {
+		t.Errorf("n2 resolve = %q, want %q", got, pushFailed)
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/internal/rbac"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// RoleService manages roles granted inside havn. Every grant and revocation
+// is written to role_audit_log in the same transaction.
+type RoleService struct {
+	db *database.Database
+}
+
+// NewRoleService creates a new role service
+func NewRoleService(db *database.Database) *RoleService {
+	return &RoleService{db: db}
+}
+
+// UserRoles returns the role names granted to a user in user_roles
+func (s *RoleService) UserRoles(ctx context.Context, userID string) ([]string, error) {
+	rows, err := s.db.Pool.Query(ctx, `SELECT role FROM user_roles WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to scan user role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// ListGrants returns the roles granted to a user, with who granted them
+func (s *RoleService) ListGrants(ctx context.Context, userID string) ([]models.UserRole, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT user_id, role, granted_by, granted_at
+		FROM user_roles
+		WHERE user_id = $1
+		ORDER BY granted_at
+	`, userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to query user roles")
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+	defer rows.Close()
+
+	grants := []models.UserRole{}
+	for rows.Next() {
+		var g models.UserRole
+		if err := rows.Scan(&g.UserID, &g.Role, &g.GrantedBy, &g.GrantedAt); err != nil {
+			log.Error().Err(err).Msg("Failed to scan user role")
+			continue
+		}
+		grants = append(grants, g)
+	}
+
+	return grants, rows.Err()
+}
+
+// GrantRole grants role to userID on behalf of actorID, who holds actorRoles
+func (s *RoleService) GrantRole(ctx context.Context, actorID string, actorRoles []rbac.Role, userID string, role rbac.Role, reason string) (*models.UserRole, error) {
+	if !role.Assignable() {
+		return nil, fmt.Errorf("invalid role")
+	}
+	if !rbac.CanGrant(actorRoles, role) {
+		return nil, fmt.Errorf("not allowed to manage this role")
+	}
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	grant := models.UserRole{UserID: userID, Role: string(role), GrantedBy: &actorID}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO user_roles (user_id, role, granted_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, role) DO NOTHING
+		RETURNING granted_at
+	`, userID, role, actorID).Scan(&grant.GrantedAt)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("role already granted")
+		}
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("user not found")
+		}
+		log.Error().Err(err).Msg("Failed to grant role")
+		return nil, fmt.Errorf("failed to grant role: %w", err)
+	}
+
+	if err := auditRole(ctx, tx, userID, role, "grant", &actorID, reason); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info().
+		Str("actor_id", actorID).
+		Str("user_id", userID).
+		Str("role", string(role)).
+		Msg("Role granted")
+
+	return &grant, nil
+}
+
+// RevokeRole removes role from userID on behalf of actorID. Super admins
+// can't revoke their own role, so the last one can't lock everyone out.
+func (s *RoleService) RevokeRole(ctx context.Context, actorID string, actorRoles []rbac.Role, userID string, role rbac.Role, reason string) error {
+	if !role.Assignable() {
+		return fmt.Errorf("invalid role")
+	}
+	if !rbac.CanGrant(actorRoles, role) {
+		return fmt.Errorf("not allowed to manage this role")
+	}
+	if actorID == userID && role == rbac.SuperAdmin {
+		return fmt.Errorf("cannot revoke your own super_admin role")
+	}
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		DELETE FROM user_roles WHERE user_id = $1 AND role = $2
+	`, userID, role)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to revoke role")
+		return fmt.Errorf("failed to revoke role: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("role not granted")
+	}
+
+	if err := auditRole(ctx, tx, userID, role, "revoke", &actorID, reason); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info().
+		Str("actor_id", actorID).
+		Str("user_id", userID).
+		Str("role", string(role)).
+		Msg("Role revoked")
+
+	return nil
+}
+
+// ListAudit returns role changes, newest first, optionally for one user
+func (s *RoleService) ListAudit(ctx context.Context, userID string, limit, offset int) ([]models.RoleAuditEntry, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, user_id, role, action, actor_id, reason, created_at
+		FROM role_audit_log
+		WHERE ($1 = '' OR user_id::text = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query role audit log")
+		return nil, fmt.Errorf("failed to get role audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.RoleAuditEntry{}
+	for rows.Next() {
+		var e models.RoleAuditEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Role, &e.Action, &e.ActorID, &e.Reason, &e.CreatedAt); err != nil {
+			log.Error().Err(err).Msg("Failed to scan role audit entry")
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// BootstrapSuperAdmins grants super_admin to existing profiles among
+// userIDs, so a fresh deployment has someone who can grant roles. Existing
+// grants are left alone; new ones are audited without an actor.
+func (s *RoleService) BootstrapSuperAdmins(ctx context.Context, userIDs []string) error {
+	ids := []string{}
+	for _, id := range userIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if IsUUID(id) {
+			ids = append(ids, id)
+		} else {
+			log.Warn().Str("user_id", id).Msg("Ignoring bootstrap admin that isn't a profile UUID")
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	result, err := s.db.Pool.Exec(ctx, `
+		WITH granted AS (
+			INSERT INTO user_roles (user_id, role)
+			SELECT id, 'super_admin' FROM profiles WHERE id = ANY($1::uuid[])
+			ON CONFLICT (user_id, role) DO NOTHING
+			RETURNING user_id
+		)
+		INSERT INTO role_audit_log (user_id, role, action, reason)
+		SELECT user_id, 'super_admin', 'grant', 'bootstrap from ADMIN_USER_IDS' FROM granted
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to bootstrap super admins: %w", err)
+	}
+
+	if n := result.RowsAffected(); n > 0 {
+		log.Info().Int64("count", n).Msg("Granted super_admin from ADMIN_USER_IDS")
+	}
+	return nil
+}
+
+func auditRole(ctx context.Context, q querier, userID string, role rbac.Role, action string, actorID *string, reason string) error {
+	_, err := q.Exec(ctx, `
+		INSERT INTO role_audit_log (user_id, role, action, actor_id, reason)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+	`, userID, role, action, actorID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to audit role change: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/events"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ShareService handles time-boxed location share grants
+type ShareService struct {
+	db  *database.Database
+	bus events.Bus
+}
+
+// NewShareService creates a new share service
+func NewShareService(db *database.Database, bus events.Bus) *ShareService {
+	return &ShareService{db: db, bus: bus}
+}
+
+// LocationShare represents an active location share grant with the other user's details
+type LocationShare struct {
+	ID        string    `json:"id"`
+	User      UserInfo  `json:"user"` // Grantee for granted shares, owner for received shares
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SharesResponse represents a user's active share grants
+type SharesResponse struct {
+	Granted  []LocationShare `json:"granted"`
+	Received []LocationShare `json:"received"`
+}
+
+// GetShares retrieves active share grants the user has given and received
+func (s *ShareService) GetShares(ctx context.Context, userID string) (*SharesResponse, error) {
+	response := &SharesResponse{
+		Granted:  []LocationShare{},
+		Received: []LocationShare{},
+	}
+
+	// Granted: the other user is the grantee
+	granted, err := s.queryShares(ctx, `
+		SELECT ls.id, p.id, p.username, p.full_name, p.avatar_url, ls.expires_at, ls.created_at
+		FROM location_shares ls
+		JOIN profiles p ON p.id = ls.grantee_id
+		WHERE ls.owner_id = $1 AND ls.revoked_at IS NULL AND ls.expires_at > NOW()
+		ORDER BY ls.expires_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get granted shares: %w", err)
+	}
+	response.Granted = granted
+
+	// Received: the other user is the owner
+	received, err := s.queryShares(ctx, `
+		SELECT ls.id, p.id, p.username, p.full_name, p.avatar_url, ls.expires_at, ls.created_at
+		FROM location_shares ls
+		JOIN profiles p ON p.id = ls.owner_id
+		WHERE ls.grantee_id = $1 AND ls.revoked_at IS NULL AND ls.expires_at > NOW()
+		ORDER BY ls.expires_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get received shares: %w", err)
+	}
+	response.Received = received
+
+	return response, nil
+}
+
+// queryShares runs a share query and scans the results
+func (s *ShareService) queryShares(ctx context.Context, query, userID string) ([]LocationShare, error) {
+	rows, err := s.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query location shares")
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []LocationShare{}
+	for rows.Next() {
+		var share LocationShare
+		err := rows.Scan(
+			&share.ID,
+			&share.User.ID,
+			&share.User.Username,
+			&share.User.FullName,
+			&share.User.AvatarURL,
+			&share.ExpiresAt,
+			&share.CreatedAt,
+		)
+
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan location share")
+			continue
+		}
+
+		shares = append(shares, share)
+	}
+
+	return shares, nil
+}
+
+// CreateShare shares the owner's exact location with a friend for duration.
+// An existing active grant to the same friend is extended rather than duplicated.
+func (s *ShareService) CreateShare(ctx context.Context, ownerID, granteeID string, duration time.Duration) (*LocationShare, error) {
+	if ownerID == granteeID {
+		return nil, fmt.Errorf("cannot share location with yourself")
+	}
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var friendshipID string
+	err = tx.QueryRow(ctx, `
+		SELECT id FROM friendships
+		WHERE ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+		AND status = 'accepted'
+		LIMIT 1
+	`, ownerID, granteeID).Scan(&friendshipID)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("user is not your friend")
+		}
+		return nil, fmt.Errorf("failed to check friendship: %w", err)
+	}
+
+	var share LocationShare
+	err = tx.QueryRow(ctx, `
+		UPDATE location_shares
+		SET expires_at = NOW() + make_interval(secs => $3)
+		WHERE owner_id = $1 AND grantee_id = $2
+		AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING id, expires_at, created_at
+	`, ownerID, granteeID, duration.Seconds()).Scan(&share.ID, &share.ExpiresAt, &share.CreatedAt)
+
+	if err == pgx.ErrNoRows {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO location_shares (owner_id, grantee_id, expires_at)
+			VALUES ($1, $2, NOW() + make_interval(secs => $3))
+			RETURNING id, expires_at, created_at
+		`, ownerID, granteeID, duration.Seconds()).Scan(&share.ID, &share.ExpiresAt, &share.CreatedAt)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create location share")
+		return nil, fmt.Errorf("failed to create location share: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT id, username, full_name, avatar_url FROM profiles WHERE id = $1
+	`, granteeID).Scan(&share.User.ID, &share.User.Username, &share.User.FullName, &share.User.AvatarURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get grantee profile: %w", err)
+	}
+
+	if err := publishPresenceChanged(ctx, s.bus, tx, ownerID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info().
+		Str("owner_id", ownerID).
+		Str("grantee_id", granteeID).
+		Time("expires_at", share.ExpiresAt).
+		Msg("Location share created")
+
+	return &share, nil
+}
+
+// RevokeShare ends a share grant early. Either the owner or the grantee may revoke it.
+func (s *ShareService) RevokeShare(ctx context.Context, shareID, userID string) error {
+	var ownerID, granteeID string
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT owner_id, grantee_id FROM location_shares
+		WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	`, shareID).Scan(&ownerID, &granteeID)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("share not found or already ended")
+		}
+		return fmt.Errorf("failed to find share: %w", err)
+	}
+
+	if ownerID != userID && granteeID != userID {
+		return fmt.Errorf("unauthorized: this share does not involve you")
+	}
+
+	_, err = s.db.Pool.Exec(ctx, `
+		UPDATE location_shares SET revoked_at = NOW() WHERE id = $1
+	`, shareID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to revoke location share")
+		return fmt.Errorf("failed to revoke share: %w", err)
+	}
+
+	if err := publishPresenceChanged(ctx, s.bus, s.db.Pool, ownerID); err != nil {
+		log.Error().Err(err).Str("share_id", shareID).Msg("Failed to publish presence change")
+	}
+
+	log.Info().Str("share_id", shareID).Str("user_id", userID).Msg("Location share revoked")
+	return nil
+}
+
+// revokeSharesBetween ends all active share grants between two users in either direction
+func revokeSharesBetween(ctx context.Context, q querier, userID, otherID string) error {
+	_, err := q.Exec(ctx, `
+		UPDATE location_shares
+		SET revoked_at = NOW()
+		WHERE ((owner_id = $1 AND grantee_id = $2) OR (owner_id = $2 AND grantee_id = $1))
+		AND revoked_at IS NULL AND expires_at > NOW()
+	`, userID, otherID)
+
+	if err != nil {
+		return fmt.Errorf("failed to revoke location shares: %w", err)
+	}
+	return nil
+}
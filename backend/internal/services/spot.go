@@ -3,9 +3,11 @@ package services
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/harrypall/havn-backend/internal/models"
 	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
 )
 
@@ -187,9 +189,98 @@ func (s *SpotService) GetSpotByID(ctx context.Context, id string, userID string)
 	// Calculate occupancy status
 	spot.CalculateOccupancyStatus()
 
-	// TODO: Get friends at this spot
-	// This would require querying profiles and occupancy_logs
+	// Get friends at this spot who let the user see their exact location
+	friendsHere, err := s.getFriendsAtSpot(ctx, id, userID)
+	if err != nil {
+		log.Error().Err(err).Str("spot_id", id).Msg("Failed to get friends at spot")
+	} else {
+		spot.FriendsHere = friendsHere
+	}
 
 	return &spot, nil
 }
 
+// getFriendsAtSpot retrieves the user's friends checked in at a spot. Friends
+// sharing only building-level location (or nothing) are left out.
+func (s *SpotService) getFriendsAtSpot(ctx context.Context, spotID, userID string) ([]models.FriendAtSpot, error) {
+	query := `
+		SELECT p.id, p.username, p.full_name, p.avatar_url, p.checked_in_at
+		FROM friendships f
+		JOIN profiles p ON (
+			CASE 
+				WHEN f.user_id = $2 THEN p.id = f.friend_id
+				ELSE p.id = f.user_id
+			END
+		)
+		WHERE (f.user_id = $2 OR f.friend_id = $2) AND f.status = 'accepted'
+		AND p.current_spot_id = $1
+		AND location_visibility(p.id, $2) = 'exact'
+		ORDER BY p.checked_in_at DESC
+	`
+
+	rows, err := s.db.Pool.Query(ctx, query, spotID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query friends at spot: %w", err)
+	}
+	defer rows.Close()
+
+	friends := []models.FriendAtSpot{}
+	for rows.Next() {
+		var friend models.FriendAtSpot
+		err := rows.Scan(
+			&friend.UserID,
+			&friend.Username,
+			&friend.FullName,
+			&friend.AvatarURL,
+			&friend.CheckedInAt,
+		)
+
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan friend at spot")
+			continue
+		}
+
+		friends = append(friends, friend)
+	}
+
+	return friends, nil
+}
+
+
+// SpotVerification is a spot's verification state
+type SpotVerification struct {
+	SpotID     string     `json:"spot_id"`
+	IsVerified bool       `json:"is_verified"`
+	VerifiedBy *string    `json:"verified_by,omitempty"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+}
+
+// SetVerified marks a spot as verified by a moderator, or clears it
+func (s *SpotService) SetVerified(ctx context.Context, spotID, moderatorID string, verified bool) (*SpotVerification, error) {
+	var v SpotVerification
+	err := s.db.Pool.QueryRow(ctx, `
+		UPDATE spots
+		SET is_verified = $2,
+			verified_by = CASE WHEN $2 THEN $3::uuid END,
+			verified_at = CASE WHEN $2 THEN NOW() END,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING id, is_verified, verified_by, verified_at
+	`, spotID, verified, moderatorID).Scan(&v.SpotID, &v.IsVerified, &v.VerifiedBy, &v.VerifiedAt)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("spot not found")
+		}
+		log.Error().Err(err).Str("spot_id", spotID).Msg("Failed to update spot verification")
+		return nil, fmt.Errorf("failed to update spot verification: %w", err)
+	}
+
+	log.Info().
+		Str("spot_id", spotID).
+		Str("moderator_id", moderatorID).
+		Bool("verified", verified).
+		Msg("Spot verification updated")
+
+	return &v, nil
+}
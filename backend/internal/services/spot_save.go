@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/harrypall/havn-backend/internal/events"
 	"github.com/harrypall/havn-backend/internal/models"
 	"github.com/harrypall/havn-backend/pkg/database"
 	"github.com/jackc/pgx/v5"
@@ -13,12 +14,16 @@ import (
 
 // SpotSaveService handles spot save requests
 type SpotSaveService struct {
-	db *database.Database
+	db            *database.Database
+	limits        *LimitService
+	groups        *FriendGroupService
+	notifications *NotificationService
+	bus           events.Bus
 }
 
 // NewSpotSaveService creates a new spot save service
-func NewSpotSaveService(db *database.Database) *SpotSaveService {
-	return &SpotSaveService{db: db}
+func NewSpotSaveService(db *database.Database, limits *LimitService, groups *FriendGroupService, notifications *NotificationService, bus events.Bus) *SpotSaveService {
+	return &SpotSaveService{db: db, limits: limits, groups: groups, notifications: notifications, bus: bus}
 }
 
 // SpotSaveRequestWithDetails represents a spot save request with full details
@@ -186,6 +191,15 @@ func (s *SpotSaveService) CreateRequest(ctx context.Context, requesterID, saverI
 		return nil, fmt.Errorf("failed to check friendship: %w", err)
 	}
 
+	// Blocks hide the relationship entirely, so report them the same way
+	blocked, err := blockExists(ctx, s.db.Pool, requesterID, saverID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, fmt.Errorf("saver is not your friend")
+	}
+
 	// 2. Validate saver is within 2km of the spot
 	var saverCurrentSpotID *string
 	err = s.db.Pool.QueryRow(ctx, `
@@ -246,9 +260,19 @@ func (s *SpotSaveService) CreateRequest(ctx context.Context, requesterID, saverI
 		log.Info().Str("saver_id", saverID).Msg("Saver not currently checked in, allowing request anyway")
 	}
 
-	// 3. Create the spot save request
+	// 3. Enforce anti-spam limits and create the spot save request
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.limits.CheckAndRecord(ctx, tx, LimitActionSpotSave, requesterID, saverID); err != nil {
+		return nil, err
+	}
+
 	var request models.SpotSaveRequest
-	err = s.db.Pool.QueryRow(ctx, `
+	err = tx.QueryRow(ctx, `
 		INSERT INTO spot_save_requests (requester_id, saver_id, spot_id, message, status, expires_at)
 		VALUES ($1, $2, $3, $4, 'pending', NOW() + INTERVAL '30 minutes')
 		RETURNING id, requester_id, saver_id, spot_id, status, message, requested_at, expires_at, created_at, updated_at
@@ -270,17 +294,76 @@ func (s *SpotSaveService) CreateRequest(ctx context.Context, requesterID, saverI
 		return nil, fmt.Errorf("failed to create spot save request: %w", err)
 	}
 
+	if err := s.notifications.NotifySpotSaveRequest(ctx, tx, &request); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	log.Info().
 		Str("requester_id", requesterID).
 		Str("saver_id", saverID).
 		Str("spot_id", spotID).
 		Msg("Spot save request created")
 
-	// TODO: Send push notification to saver
-
 	return &request, nil
 }
 
+// SkippedSaver records a group member who could not be sent a spot save request
+type SkippedSaver struct {
+	SaverID string `json:"saver_id"`
+	Reason  string `json:"reason"`
+}
+
+// GroupSpotSaveResult represents the outcome of a spot save request sent to a group
+type GroupSpotSaveResult struct {
+	Created []models.SpotSaveRequest `json:"created"`
+	Skipped []SkippedSaver           `json:"skipped"`
+}
+
+// CreateGroupRequest sends a spot save request to every member of one of the
+// requester's friend groups. Each member goes through the same checks and
+// limits as CreateRequest; members that fail them are reported as skipped.
+func (s *SpotSaveService) CreateGroupRequest(ctx context.Context, requesterID, groupID, spotID, message string) (*GroupSpotSaveResult, error) {
+	memberIDs, err := s.groups.GetMemberIDs(ctx, requesterID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(memberIDs) == 0 {
+		return nil, fmt.Errorf("group has no members")
+	}
+
+	result := &GroupSpotSaveResult{
+		Created: []models.SpotSaveRequest{},
+		Skipped: []SkippedSaver{},
+	}
+
+	for _, saverID := range memberIDs {
+		request, err := s.CreateRequest(ctx, requesterID, saverID, spotID, message)
+		if err != nil {
+			// The spot is the same for every member, so there is no point continuing
+			if err.Error() == "spot not found" {
+				return nil, err
+			}
+			result.Skipped = append(result.Skipped, SkippedSaver{SaverID: saverID, Reason: err.Error()})
+			continue
+		}
+		result.Created = append(result.Created, *request)
+	}
+
+	log.Info().
+		Str("requester_id", requesterID).
+		Str("group_id", groupID).
+		Int("created", len(result.Created)).
+		Int("skipped", len(result.Skipped)).
+		Msg("Group spot save request created")
+
+	return result, nil
+}
+
 // Respond responds to a spot save request
 func (s *SpotSaveService) Respond(ctx context.Context, requestID, userID, response string) error {
 	// Validate response
@@ -289,12 +372,12 @@ func (s *SpotSaveService) Respond(ctx context.Context, requestID, userID, respon
 	}
 
 	// Verify the user is the saver
-	var saverID string
+	var requesterID, saverID, spotID string
 	var status string
 	err := s.db.Pool.QueryRow(ctx, `
-		SELECT saver_id, status FROM spot_save_requests
+		SELECT requester_id, saver_id, spot_id, status FROM spot_save_requests
 		WHERE id = $1
-	`, requestID).Scan(&saverID, &status)
+	`, requestID).Scan(&requesterID, &saverID, &spotID, &status)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -329,24 +412,50 @@ func (s *SpotSaveService) Respond(ctx context.Context, requestID, userID, respon
 		return fmt.Errorf("request has expired")
 	}
 
-	// Update request status
-	_, err = s.db.Pool.Exec(ctx, `
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Update request status, unless a concurrent response got there first
+	result, err := tx.Exec(ctx, `
 		UPDATE spot_save_requests
 		SET status = $1, responded_at = NOW(), updated_at = NOW()
-		WHERE id = $2
+		WHERE id = $2 AND status = 'pending'
 	`, response, requestID)
 
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to update spot save request")
 		return fmt.Errorf("failed to respond to spot save request: %w", err)
 	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("request already responded or expired")
+	}
+
+	err = publishEvent(ctx, s.bus, tx, events.SpotSaveResponded, events.AggregateSpotSaveRequest, requestID, SpotSaveRespondedEvent{
+		RequestID:   requestID,
+		RequesterID: requesterID,
+		SaverID:     saverID,
+		SpotID:      spotID,
+		Status:      response,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
 	log.Info().
 		Str("request_id", requestID).
 		Str("response", response).
 		Msg("Spot save request responded")
 
-	// TODO: Send push notification to requester
+	if err := s.notifications.NotifySpotSaveResponse(ctx, s.db.Pool, requestID, requesterID, saverID, spotID, response); err != nil {
+		log.Error().Err(err).Str("request_id", requestID).Msg("Failed to notify spot save response")
+	}
 
 	return nil
 }
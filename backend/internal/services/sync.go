@@ -0,0 +1,455 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// syncTokenVersion prefixes sync tokens so their format can change later
+const syncTokenVersion = "1"
+
+// SyncService computes what changed in a user's offline cache since a sync token
+type SyncService struct {
+	db        *database.Database
+	retention time.Duration
+}
+
+// NewSyncService creates a new sync service. Tombstones are kept for
+// SYNC_TOMBSTONE_RETENTION (default 30 days); older tokens get a full sync.
+func NewSyncService(db *database.Database) *SyncService {
+	return &SyncService{
+		db:        db,
+		retention: envDuration("SYNC_TOMBSTONE_RETENTION", 30*24*time.Hour),
+	}
+}
+
+// SyncFriendship is a friendship or friend request from the caller's side
+type SyncFriendship struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Direction   string     `json:"direction"` // incoming|outgoing
+	User        UserInfo   `json:"user"`
+	RequestedAt time.Time  `json:"requested_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// SyncDeleted lists the ids of rows the client should drop from its cache
+type SyncDeleted struct {
+	Spots            []string `json:"spots"`
+	Friendships      []string `json:"friendships"`
+	SpotSaveRequests []string `json:"spot_save_requests"`
+	Notifications    []string `json:"notifications"`
+}
+
+// SyncResponse holds every change since the token. When Full is set the
+// client should replace its cache instead of merging.
+type SyncResponse struct {
+	Token            string                       `json:"token"`
+	Full             bool                         `json:"full"`
+	Spots            []models.Spot                `json:"spots"`
+	Friendships      []SyncFriendship             `json:"friendships"`
+	SpotSaveRequests []SpotSaveRequestWithDetails `json:"spot_save_requests"`
+	Notifications    []models.Notification        `json:"notifications"`
+	Deleted          SyncDeleted                  `json:"deleted"`
+}
+
+// syncToken is the position a client has synced up to
+type syncToken struct {
+	xmin     uint64
+	issuedAt time.Time
+}
+
+func (t syncToken) encode() string {
+	raw := fmt.Sprintf("%s:%d:%d", syncTokenVersion, t.xmin, t.issuedAt.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseSyncToken(token string) (*syncToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sync token")
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || parts[0] != syncTokenVersion {
+		return nil, fmt.Errorf("invalid sync token")
+	}
+
+	xmin, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sync token")
+	}
+	issued, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sync token")
+	}
+
+	return &syncToken{xmin: xmin, issuedAt: time.Unix(issued, 0)}, nil
+}
+
+// Sync returns the caller's spots, friendships, spot save requests and
+// notifications that changed since the token, plus tombstones for deleted
+// ones. An empty token, or one older than the tombstone retention, returns
+// everything with Full set. Rows may be repeated across syncs, so clients
+// should upsert by id.
+func (s *SyncService) Sync(ctx context.Context, userID, token string) (*SyncResponse, error) {
+	var since *syncToken
+	if token != "" {
+		var err error
+		if since, err = parseSyncToken(token); err != nil {
+			return nil, err
+		}
+		if time.Since(since.issuedAt) > s.retention {
+			since = nil
+		}
+	}
+
+	// One snapshot for every read, so the new token matches what was returned
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var xmin string
+	err = tx.QueryRow(ctx, `SELECT pg_snapshot_xmin(pg_current_snapshot())::text`).Scan(&xmin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync position: %w", err)
+	}
+	next, err := strconv.ParseUint(xmin, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync position: %w", err)
+	}
+
+	// A full sync matches every row; xid8 values are never below 0
+	var after uint64
+	if since != nil {
+		after = since.xmin
+	}
+
+	response := &SyncResponse{
+		Token:            syncToken{xmin: next, issuedAt: time.Now()}.encode(),
+		Full:             since == nil,
+		Spots:            []models.Spot{},
+		Friendships:      []SyncFriendship{},
+		SpotSaveRequests: []SpotSaveRequestWithDetails{},
+		Notifications:    []models.Notification{},
+		Deleted: SyncDeleted{
+			Spots:            []string{},
+			Friendships:      []string{},
+			SpotSaveRequests: []string{},
+			Notifications:    []string{},
+		},
+	}
+
+	if err := s.syncSpots(ctx, tx, after, response); err != nil {
+		return nil, err
+	}
+	if err := s.syncFriendships(ctx, tx, userID, after, response); err != nil {
+		return nil, err
+	}
+	if err := s.syncSpotSaveRequests(ctx, tx, userID, after, response); err != nil {
+		return nil, err
+	}
+	if err := s.syncNotifications(ctx, tx, userID, after, response); err != nil {
+		return nil, err
+	}
+	if since != nil {
+		if err := s.syncTombstones(ctx, tx, userID, after, response); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// syncSpots adds spots changed since the position
+func (s *SyncService) syncSpots(ctx context.Context, tx pgx.Tx, after uint64, response *SyncResponse) error {
+	rows, err := tx.Query(ctx, `
+		SELECT
+			id, name, COALESCE(building_name, ''), COALESCE(floor_number, ''),
+			ST_X(location::geometry) as longitude,
+			ST_Y(location::geometry) as latitude,
+			COALESCE(address, ''), spot_type, capacity, current_occupancy,
+			amenities, hours, photo_urls, is_verified, avg_rating, total_reviews,
+			created_at, updated_at
+		FROM spots
+		WHERE sync_xid >= $1::text::xid8
+	`, strconv.FormatUint(after, 10))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query spot changes")
+		return fmt.Errorf("failed to sync spots: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var spot models.Spot
+		var amenities, hours []byte
+		err := rows.Scan(
+			&spot.ID,
+			&spot.Name,
+			&spot.BuildingName,
+			&spot.FloorNumber,
+			&spot.Longitude,
+			&spot.Latitude,
+			&spot.Address,
+			&spot.SpotType,
+			&spot.Capacity,
+			&spot.CurrentOccupancy,
+			&amenities,
+			&hours,
+			&spot.PhotoURLs,
+			&spot.IsVerified,
+			&spot.AvgRating,
+			&spot.TotalReviews,
+			&spot.CreatedAt,
+			&spot.UpdatedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan spot")
+			continue
+		}
+
+		if err := spot.Amenities.Scan(amenities); err != nil {
+			log.Error().Err(err).Msg("Failed to parse amenities")
+		}
+		if err := spot.Hours.Scan(hours); err != nil {
+			log.Error().Err(err).Msg("Failed to parse hours")
+		}
+		spot.CalculateOccupancyStatus()
+
+		response.Spots = append(response.Spots, spot)
+	}
+
+	return rows.Err()
+}
+
+// syncFriendships adds friendships changed since the position. Rows the
+// caller can no longer see, such as a declined request they sent or a block
+// placed on them, are reported as deleted so nothing is revealed.
+func (s *SyncService) syncFriendships(ctx context.Context, tx pgx.Tx, userID string, after uint64, response *SyncResponse) error {
+	rows, err := tx.Query(ctx, `
+		SELECT
+			f.id, f.status, f.user_id = $1 AS outgoing,
+			f.requested_at, f.responded_at, f.updated_at,
+			p.id, p.username, p.full_name, p.avatar_url,
+			f.status IN ('accepted', 'pending') OR (f.status = 'blocked' AND f.user_id = $1) AS visible
+		FROM friendships f
+		JOIN profiles p ON p.id = CASE WHEN f.user_id = $1 THEN f.friend_id ELSE f.user_id END
+		WHERE (f.user_id = $1 OR f.friend_id = $1)
+		AND f.sync_xid >= $2::text::xid8
+	`, userID, strconv.FormatUint(after, 10))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query friendship changes")
+		return fmt.Errorf("failed to sync friendships: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f SyncFriendship
+		var outgoing, visible bool
+		err := rows.Scan(
+			&f.ID,
+			&f.Status,
+			&outgoing,
+			&f.RequestedAt,
+			&f.RespondedAt,
+			&f.UpdatedAt,
+			&f.User.ID,
+			&f.User.Username,
+			&f.User.FullName,
+			&f.User.AvatarURL,
+			&visible,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan friendship")
+			continue
+		}
+
+		if !visible {
+			if !response.Full {
+				response.Deleted.Friendships = append(response.Deleted.Friendships, f.ID)
+			}
+			continue
+		}
+
+		f.Direction = "incoming"
+		if outgoing {
+			f.Direction = "outgoing"
+		}
+		response.Friendships = append(response.Friendships, f)
+	}
+
+	return rows.Err()
+}
+
+// syncSpotSaveRequests adds spot save requests the caller sent or received
+// that changed since the position. Expired requests are reported as deleted,
+// matching GetRequests.
+func (s *SyncService) syncSpotSaveRequests(ctx context.Context, tx pgx.Tx, userID string, after uint64, response *SyncResponse) error {
+	rows, err := tx.Query(ctx, `
+		SELECT
+			ssr.id, ssr.status, COALESCE(ssr.message, ''), ssr.requested_at, ssr.expires_at, ssr.responded_at,
+			requester.id, requester.username, requester.full_name, requester.avatar_url,
+			saver.id, saver.username, saver.full_name, saver.avatar_url,
+			s.id, s.name
+		FROM spot_save_requests ssr
+		JOIN profiles requester ON requester.id = ssr.requester_id
+		JOIN profiles saver ON saver.id = ssr.saver_id
+		JOIN spots s ON s.id = ssr.spot_id
+		WHERE (ssr.requester_id = $1 OR ssr.saver_id = $1)
+		AND ssr.sync_xid >= $2::text::xid8
+	`, userID, strconv.FormatUint(after, 10))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query spot save request changes")
+		return fmt.Errorf("failed to sync spot save requests: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var req SpotSaveRequestWithDetails
+		err := rows.Scan(
+			&req.ID,
+			&req.Status,
+			&req.Message,
+			&req.RequestedAt,
+			&req.ExpiresAt,
+			&req.RespondedAt,
+			&req.Requester.ID,
+			&req.Requester.Username,
+			&req.Requester.FullName,
+			&req.Requester.AvatarURL,
+			&req.Saver.ID,
+			&req.Saver.Username,
+			&req.Saver.FullName,
+			&req.Saver.AvatarURL,
+			&req.Spot.ID,
+			&req.Spot.Name,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan spot save request")
+			continue
+		}
+
+		if req.Status == "expired" {
+			if !response.Full {
+				response.Deleted.SpotSaveRequests = append(response.Deleted.SpotSaveRequests, req.ID)
+			}
+			continue
+		}
+
+		response.SpotSaveRequests = append(response.SpotSaveRequests, req)
+	}
+
+	return rows.Err()
+}
+
+// syncNotifications adds the caller's notifications changed since the position
+func (s *SyncService) syncNotifications(ctx context.Context, tx pgx.Tx, userID string, after uint64, response *SyncResponse) error {
+	rows, err := tx.Query(ctx, `
+		SELECT id, user_id, type, title, body, data, status, sent_at, read, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND sync_xid >= $2::text::xid8
+		ORDER BY created_at DESC, id DESC
+	`, userID, strconv.FormatUint(after, 10))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query notification changes")
+		return fmt.Errorf("failed to sync notifications: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n models.Notification
+		err := rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&n.Type,
+			&n.Title,
+			&n.Body,
+			&n.Data,
+			&n.Status,
+			&n.SentAt,
+			&n.Read,
+			&n.ReadAt,
+			&n.CreatedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan notification")
+			continue
+		}
+
+		response.Notifications = append(response.Notifications, n)
+	}
+
+	return rows.Err()
+}
+
+// syncTombstones adds rows deleted since the position
+func (s *SyncService) syncTombstones(ctx context.Context, tx pgx.Tx, userID string, after uint64, response *SyncResponse) error {
+	rows, err := tx.Query(ctx, `
+		SELECT entity_type, entity_id
+		FROM sync_tombstones
+		WHERE sync_xid >= $2::text::xid8
+		AND (user_ids IS NULL OR $1::uuid = ANY(user_ids))
+	`, userID, strconv.FormatUint(after, 10))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query sync tombstones")
+		return fmt.Errorf("failed to sync deletions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entityType, entityID string
+		if err := rows.Scan(&entityType, &entityID); err != nil {
+			log.Error().Err(err).Msg("Failed to scan sync tombstone")
+			continue
+		}
+
+		deleted := &response.Deleted
+		switch entityType {
+		case "spot":
+			deleted.Spots = append(deleted.Spots, entityID)
+		case "friendship":
+			deleted.Friendships = append(deleted.Friendships, entityID)
+		case "spot_save_request":
+			deleted.SpotSaveRequests = append(deleted.SpotSaveRequests, entityID)
+		case "notification":
+			deleted.Notifications = append(deleted.Notifications, entityID)
+		}
+	}
+
+	return rows.Err()
+}
+
+// Run prunes tombstones past the retention period until ctx is cancelled
+func (s *SyncService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		tag, err := s.db.Pool.Exec(ctx, `
+			DELETE FROM sync_tombstones WHERE deleted_at < NOW() - make_interval(secs => $1)
+		`, s.retention.Seconds())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to prune sync tombstones")
+		} else if tag.RowsAffected() > 0 {
+			log.Info().Int64("count", tag.RowsAffected()).Msg("Pruned sync tombstones")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/harrypall/havn-backend/internal/events"
 	"github.com/harrypall/havn-backend/internal/models"
 	"github.com/harrypall/havn-backend/pkg/database"
 	"github.com/rs/zerolog/log"
@@ -11,12 +12,13 @@ import (
 
 // UserService handles user-related operations
 type UserService struct {
-	db *database.Database
+	db  *database.Database
+	bus events.Bus
 }
 
 // NewUserService creates a new user service
-func NewUserService(db *database.Database) *UserService {
-	return &UserService{db: db}
+func NewUserService(db *database.Database, bus events.Bus) *UserService {
+	return &UserService{db: db, bus: bus}
 }
 
 // GetProfile retrieves a user's profile
@@ -27,8 +29,8 @@ func (s *UserService) GetProfile(ctx context.Context, userID string) (*models.Pr
 		SELECT 
 			id, username, full_name, avatar_url, university_id,
 			graduation_year, major, bio, location_sharing,
-			current_spot_id, checked_in_at, push_token, preferences,
-			created_at, updated_at
+			current_spot_id, checked_in_at, colocation_suggestions,
+			preferences, created_at, updated_at
 		FROM profiles
 		WHERE id = $1
 	`
@@ -46,7 +48,7 @@ func (s *UserService) GetProfile(ctx context.Context, userID string) (*models.Pr
 		&profile.LocationSharing,
 		&profile.CurrentSpotID,
 		&profile.CheckedInAt,
-		&profile.PushToken,
+		&profile.ColocationSuggestions,
 		&preferences,
 		&profile.CreatedAt,
 		&profile.UpdatedAt,
@@ -89,12 +91,20 @@ func (s *UserService) UpdateProfile(ctx context.Context, userID string, updates
 		log.Error().Err(err).Str("user_id", userID).Msg("Failed to update profile")
 		return fmt.Errorf("failed to update profile: %w", err)
 	}
+
+	// Friends' presence streams must hide the location as soon as sharing is turned off
+	if _, ok := updates["location_sharing"]; ok {
+		if err := publishPresenceChanged(ctx, s.bus, s.db.Pool, userID); err != nil {
+			log.Error().Err(err).Str("user_id", userID).Msg("Failed to publish presence change")
+		}
+	}
 	
 	log.Info().Str("user_id", userID).Msg("Profile updated successfully")
 	return nil
 }
 
-// Search searches for users by username or email
+// Search searches for users by username or email. Users who have blocked
+// the searcher are never returned.
 func (s *UserService) Search(ctx context.Context, query string, currentUserID string) ([]models.Profile, error) {
 	searchQuery := `
 		SELECT 
@@ -111,6 +121,10 @@ func (s *UserService) Search(ctx context.Context, query string, currentUserID st
 			(f.friend_id = $1 AND f.user_id = p.id)
 		)
 		WHERE p.id != $1 
+		AND NOT EXISTS (
+			SELECT 1 FROM friendships b
+			WHERE b.user_id = p.id AND b.friend_id = $1 AND b.status = 'blocked'
+		)
 		AND (
 			p.username ILIKE '%' || $2 || '%' OR
 			p.full_name ILIKE '%' || $2 || '%'
@@ -0,0 +1,436 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// WaitlistService manages first-in, first-out waitlists for full spots.
+// When a checkout frees a seat, the head of the queue is offered an exclusive
+// claim on it for a short window; unclaimed offers pass to the next person.
+type WaitlistService struct {
+	db            *database.Database
+	notifications *NotificationService
+	claimWindow   time.Duration
+	rateWindow    time.Duration
+	sweepInterval time.Duration
+}
+
+// NewWaitlistService creates a new waitlist service. Offers last
+// WAITLIST_CLAIM_WINDOW (default 5m) and are swept every
+// WAITLIST_SWEEP_INTERVAL (default 15s). Wait estimates use the checkout rate
+// over the last WAITLIST_RATE_WINDOW (default 1h).
+func NewWaitlistService(db *database.Database, notifications *NotificationService) *WaitlistService {
+	return &WaitlistService{
+		db:            db,
+		notifications: notifications,
+		claimWindow:   envDuration("WAITLIST_CLAIM_WINDOW", 5*time.Minute),
+		rateWindow:    envDuration("WAITLIST_RATE_WINDOW", time.Hour),
+		sweepInterval: envDuration("WAITLIST_SWEEP_INTERVAL", 15*time.Second),
+	}
+}
+
+// WaitlistStatus describes a user's place in a spot's waitlist. Position is 0
+// while the user holds a claim on a seat.
+type WaitlistStatus struct {
+	Entry                models.WaitlistEntry `json:"entry"`
+	Position             int                  `json:"position"`
+	QueueLength          int                  `json:"queue_length"`
+	EstimatedWaitMinutes *int                 `json:"estimated_wait_minutes"`
+}
+
+// Join adds the user to the back of a spot's waitlist. Only spots with high
+// occupancy have a waitlist. Joining again returns the existing place.
+func (s *WaitlistService) Join(ctx context.Context, userID, spotID string) (*WaitlistStatus, error) {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the spot so joins are ordered against offers and check-ins
+	spot := models.Spot{}
+	err = tx.QueryRow(ctx, `
+		SELECT capacity, current_occupancy FROM spots WHERE id = $1 FOR UPDATE
+	`, spotID).Scan(&spot.Capacity, &spot.CurrentOccupancy)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("spot not found")
+		}
+		return nil, fmt.Errorf("failed to get spot: %w", err)
+	}
+
+	var checkedIn bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM occupancy_logs
+			WHERE user_id = $1 AND spot_id = $2 AND checked_out_at IS NULL
+		)
+	`, userID, spotID).Scan(&checkedIn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing check-in: %w", err)
+	}
+	if checkedIn {
+		return nil, fmt.Errorf("already checked in at this spot")
+	}
+
+	var onWaitlist bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM spot_waitlist_entries
+			WHERE spot_id = $1 AND user_id = $2 AND status IN ('waiting', 'offered')
+		)
+	`, spotID, userID).Scan(&onWaitlist)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check waitlist: %w", err)
+	}
+
+	if !onWaitlist {
+		spot.CalculateOccupancyStatus()
+		if spot.OccupancyStatus != "high" {
+			return nil, fmt.Errorf("spot is not full")
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO spot_waitlist_entries (spot_id, user_id) VALUES ($1, $2)
+		`, spotID, userID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to join waitlist")
+			return nil, fmt.Errorf("failed to join waitlist: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if !onWaitlist {
+		log.Info().Str("user_id", userID).Str("spot_id", spotID).Msg("User joined waitlist")
+	}
+
+	return s.GetStatus(ctx, userID, spotID)
+}
+
+// GetStatus returns the user's position and estimated wait in a spot's waitlist
+func (s *WaitlistService) GetStatus(ctx context.Context, userID, spotID string) (*WaitlistStatus, error) {
+	var status WaitlistStatus
+	entry := &status.Entry
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT id, spot_id, user_id, status, joined_at, offered_at, claim_expires_at, resolved_at, created_at
+		FROM spot_waitlist_entries
+		WHERE spot_id = $1 AND user_id = $2 AND status IN ('waiting', 'offered')
+	`, spotID, userID).Scan(
+		&entry.ID,
+		&entry.SpotID,
+		&entry.UserID,
+		&entry.Status,
+		&entry.JoinedAt,
+		&entry.OfferedAt,
+		&entry.ClaimExpiresAt,
+		&entry.ResolvedAt,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("not on waitlist")
+		}
+		return nil, fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+
+	err = s.db.Pool.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE (joined_at, id) <= ($2, $3::uuid)),
+			COUNT(*)
+		FROM spot_waitlist_entries
+		WHERE spot_id = $1 AND status = 'waiting'
+	`, spotID, entry.JoinedAt, entry.ID).Scan(&status.Position, &status.QueueLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get waitlist position: %w", err)
+	}
+
+	if entry.Status == "offered" {
+		status.Position = 0
+		return &status, nil
+	}
+
+	status.EstimatedWaitMinutes, err = s.estimateWait(ctx, spotID, status.Position)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// estimateWait extrapolates the spot's recent checkout rate to the given
+// queue position. Returns nil when nobody has checked out recently.
+func (s *WaitlistService) estimateWait(ctx context.Context, spotID string, position int) (*int, error) {
+	var checkouts int
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM occupancy_logs
+		WHERE spot_id = $1 AND checked_out_at > NOW() - make_interval(secs => $2)
+	`, spotID, s.rateWindow.Seconds()).Scan(&checkouts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checkout rate: %w", err)
+	}
+
+	if checkouts == 0 {
+		return nil, nil
+	}
+
+	minutes := int(math.Ceil(float64(position) * s.rateWindow.Minutes() / float64(checkouts)))
+	return &minutes, nil
+}
+
+// Leave removes the user from a spot's waitlist. A claim the user was holding
+// passes to the next person in line.
+func (s *WaitlistService) Leave(ctx context.Context, userID, spotID string) error {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM spots WHERE id = $1 FOR UPDATE`, spotID); err != nil {
+		return fmt.Errorf("failed to lock spot: %w", err)
+	}
+
+	var entryID, status string
+	err = tx.QueryRow(ctx, `
+		SELECT id, status FROM spot_waitlist_entries
+		WHERE spot_id = $1 AND user_id = $2 AND status IN ('waiting', 'offered')
+		FOR UPDATE
+	`, spotID, userID).Scan(&entryID, &status)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("not on waitlist")
+		}
+		return fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE spot_waitlist_entries SET status = 'left', resolved_at = NOW() WHERE id = $1
+	`, entryID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to leave waitlist")
+		return fmt.Errorf("failed to leave waitlist: %w", err)
+	}
+
+	if status == "offered" {
+		if err := s.offerNext(ctx, tx, spotID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info().Str("user_id", userID).Str("spot_id", spotID).Msg("User left waitlist")
+	return nil
+}
+
+// offerNext gives the head of the spot's queue an exclusive claim on a freed
+// seat and notifies them. Does nothing if nobody is waiting. Callers must hold
+// the spot's row lock.
+func (s *WaitlistService) offerNext(ctx context.Context, q querier, spotID string) error {
+	var entryID, userID string
+	var claimExpiresAt time.Time
+	err := q.QueryRow(ctx, `
+		UPDATE spot_waitlist_entries
+		SET status = 'offered',
+		    offered_at = NOW(),
+		    claim_expires_at = NOW() + make_interval(secs => $2)
+		WHERE id = (
+			SELECT id FROM spot_waitlist_entries
+			WHERE spot_id = $1 AND status = 'waiting'
+			ORDER BY joined_at, id
+			LIMIT 1
+		)
+		RETURNING id, user_id, claim_expires_at
+	`, spotID, s.claimWindow.Seconds()).Scan(&entryID, &userID, &claimExpiresAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("failed to offer seat: %w", err)
+	}
+
+	name, err := spotName(ctx, q, spotID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.notifications.Enqueue(ctx, q, NewNotification{
+		UserID: userID,
+		Type:   NotificationWaitlistOffer,
+		Title:  fmt.Sprintf("A seat opened at %s", name),
+		Body:   fmt.Sprintf("It's yours if you check in within %d minutes", int(math.Ceil(s.claimWindow.Minutes()))),
+		Data: models.JSONB{
+			"spot_id":           spotID,
+			"waitlist_entry_id": entryID,
+			"claim_expires_at":  claimExpiresAt,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("spot_id", spotID).
+		Time("claim_expires_at", claimExpiresAt).
+		Msg("Waitlist seat offered")
+
+	return nil
+}
+
+// claimSeat is called during check-in, with the spot's row locked. A user
+// holding an unexpired offer claims it even if the spot is full; anyone else is
+// refused if every free seat is held for the waitlist.
+func (s *WaitlistService) claimSeat(ctx context.Context, q querier, userID, spotID string, capacity, occupancy int) error {
+	tag, err := q.Exec(ctx, `
+		UPDATE spot_waitlist_entries
+		SET status = 'claimed', resolved_at = NOW()
+		WHERE spot_id = $1 AND user_id = $2 AND status = 'offered' AND claim_expires_at > NOW()
+	`, spotID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to claim waitlist seat: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		log.Info().Str("user_id", userID).Str("spot_id", spotID).Msg("Waitlist seat claimed")
+		return nil
+	}
+
+	var held int
+	err = q.QueryRow(ctx, `
+		SELECT COUNT(*) FROM spot_waitlist_entries
+		WHERE spot_id = $1 AND status = 'offered' AND claim_expires_at > NOW()
+	`, spotID).Scan(&held)
+	if err != nil {
+		return fmt.Errorf("failed to count held seats: %w", err)
+	}
+
+	if held > 0 && occupancy+held >= capacity {
+		return fmt.Errorf("spot is held for the waitlist")
+	}
+
+	// Someone still waiting who found a free seat anyway leaves the queue
+	_, err = q.Exec(ctx, `
+		UPDATE spot_waitlist_entries
+		SET status = 'claimed', resolved_at = NOW()
+		WHERE spot_id = $1 AND user_id = $2 AND status = 'waiting'
+	`, spotID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update waitlist entry: %w", err)
+	}
+
+	return nil
+}
+
+// Run expires unclaimed offers until ctx is cancelled
+func (s *WaitlistService) Run(ctx context.Context) {
+	log.Info().Dur("interval", s.sweepInterval).Msg("Waitlist worker started")
+
+	ticker := time.NewTicker(s.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.ExpireOffers(ctx); err != nil {
+			log.Error().Err(err).Msg("Waitlist sweep failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Waitlist worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireOffers expires claims that ran out and passes each seat to the next
+// person in line. Returns the number of offers expired.
+func (s *WaitlistService) ExpireOffers(ctx context.Context) (int, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, spot_id FROM spot_waitlist_entries
+		WHERE status = 'offered' AND claim_expires_at <= NOW()
+		ORDER BY claim_expires_at
+		LIMIT 100
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired offers: %w", err)
+	}
+
+	type expiredOffer struct {
+		ID     string
+		SpotID string
+	}
+	expired := []expiredOffer{}
+	for rows.Next() {
+		var offer expiredOffer
+		if err := rows.Scan(&offer.ID, &offer.SpotID); err != nil {
+			log.Error().Err(err).Msg("Failed to scan expired offer")
+			continue
+		}
+		expired = append(expired, offer)
+	}
+	rows.Close()
+
+	count := 0
+	for _, offer := range expired {
+		passed, err := s.expireOffer(ctx, offer.ID, offer.SpotID)
+		if err != nil {
+			log.Error().Err(err).Str("waitlist_entry_id", offer.ID).Msg("Failed to expire waitlist offer")
+			continue
+		}
+		if passed {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// expireOffer expires a single offer and passes its seat on. Reports false if
+// the offer was claimed or already expired by another replica.
+func (s *WaitlistService) expireOffer(ctx context.Context, entryID, spotID string) (bool, error) {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM spots WHERE id = $1 FOR UPDATE`, spotID); err != nil {
+		return false, fmt.Errorf("failed to lock spot: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE spot_waitlist_entries
+		SET status = 'expired', resolved_at = NOW()
+		WHERE id = $1 AND status = 'offered' AND claim_expires_at <= NOW()
+	`, entryID)
+	if err != nil {
+		return false, fmt.Errorf("failed to expire offer: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := s.offerNext(ctx, tx, spotID); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info().Str("waitlist_entry_id", entryID).Str("spot_id", spotID).Msg("Waitlist offer expired")
+	return true, nil
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// WatchService manages spot availability watches
+type WatchService struct {
+	db            *database.Database
+	notifications *NotificationService
+	hysteresis    int
+}
+
+// NewWatchService creates a new watch service. A watch that has fired re-arms
+// once occupancy rises SPOT_WATCH_HYSTERESIS_PERCENT (default 10) points
+// above its threshold, so a spot hovering at the threshold doesn't spam.
+func NewWatchService(db *database.Database, notifications *NotificationService) *WatchService {
+	return &WatchService{
+		db:            db,
+		notifications: notifications,
+		hysteresis:    envInt("SPOT_WATCH_HYSTERESIS_PERCENT", 10),
+	}
+}
+
+// GetWatch returns the user's watch on a spot
+func (s *WatchService) GetWatch(ctx context.Context, userID, spotID string) (*models.SpotWatch, error) {
+	var watch models.SpotWatch
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT id, user_id, spot_id, threshold_percent, expires_at, armed, last_notified_at, created_at, updated_at
+		FROM spot_watches
+		WHERE user_id = $1 AND spot_id = $2 AND expires_at > NOW()
+	`, userID, spotID).Scan(
+		&watch.ID,
+		&watch.UserID,
+		&watch.SpotID,
+		&watch.ThresholdPercent,
+		&watch.ExpiresAt,
+		&watch.Armed,
+		&watch.LastNotifiedAt,
+		&watch.CreatedAt,
+		&watch.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("watch not found")
+		}
+		return nil, fmt.Errorf("failed to get watch: %w", err)
+	}
+
+	return &watch, nil
+}
+
+// CreateWatch subscribes the user to a spot, replacing any existing watch.
+// The watch starts armed, so it fires on the next change if the spot is
+// already below the threshold.
+func (s *WatchService) CreateWatch(ctx context.Context, userID, spotID string, threshold int, ttl time.Duration) (*models.SpotWatch, error) {
+	var exists bool
+	err := s.db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM spots WHERE id = $1)`, spotID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find spot: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("spot not found")
+	}
+
+	var watch models.SpotWatch
+	err = s.db.Pool.QueryRow(ctx, `
+		INSERT INTO spot_watches (user_id, spot_id, threshold_percent, expires_at)
+		VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+		ON CONFLICT (user_id, spot_id) DO UPDATE
+		SET threshold_percent = EXCLUDED.threshold_percent,
+		    expires_at = EXCLUDED.expires_at,
+		    armed = true,
+		    last_notified_at = NULL,
+		    updated_at = NOW()
+		RETURNING id, user_id, spot_id, threshold_percent, expires_at, armed, last_notified_at, created_at, updated_at
+	`, userID, spotID, threshold, ttl.Seconds()).Scan(
+		&watch.ID,
+		&watch.UserID,
+		&watch.SpotID,
+		&watch.ThresholdPercent,
+		&watch.ExpiresAt,
+		&watch.Armed,
+		&watch.LastNotifiedAt,
+		&watch.CreatedAt,
+		&watch.UpdatedAt,
+	)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create spot watch")
+		return nil, fmt.Errorf("failed to create watch: %w", err)
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("spot_id", spotID).
+		Int("threshold_percent", threshold).
+		Msg("Spot watch created")
+
+	return &watch, nil
+}
+
+// UpdateWatch changes the threshold and/or extends the expiry of an active
+// watch. Nil arguments are left unchanged.
+func (s *WatchService) UpdateWatch(ctx context.Context, userID, spotID string, threshold *int, ttl *time.Duration) (*models.SpotWatch, error) {
+	var ttlSeconds *float64
+	if ttl != nil {
+		seconds := ttl.Seconds()
+		ttlSeconds = &seconds
+	}
+
+	var watch models.SpotWatch
+	err := s.db.Pool.QueryRow(ctx, `
+		UPDATE spot_watches
+		SET threshold_percent = COALESCE($3, threshold_percent),
+		    expires_at = COALESCE(NOW() + make_interval(secs => $4), expires_at),
+		    armed = CASE WHEN $3::int IS NULL THEN armed ELSE true END,
+		    updated_at = NOW()
+		WHERE user_id = $1 AND spot_id = $2 AND expires_at > NOW()
+		RETURNING id, user_id, spot_id, threshold_percent, expires_at, armed, last_notified_at, created_at, updated_at
+	`, userID, spotID, threshold, ttlSeconds).Scan(
+		&watch.ID,
+		&watch.UserID,
+		&watch.SpotID,
+		&watch.ThresholdPercent,
+		&watch.ExpiresAt,
+		&watch.Armed,
+		&watch.LastNotifiedAt,
+		&watch.CreatedAt,
+		&watch.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("watch not found")
+		}
+		log.Error().Err(err).Msg("Failed to update spot watch")
+		return nil, fmt.Errorf("failed to update watch: %w", err)
+	}
+
+	return &watch, nil
+}
+
+// DeleteWatch removes the user's watch on a spot
+func (s *WatchService) DeleteWatch(ctx context.Context, userID, spotID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM spot_watches WHERE user_id = $1 AND spot_id = $2
+	`, userID, spotID)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete spot watch")
+		return fmt.Errorf("failed to delete watch: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("watch not found")
+	}
+	return nil
+}
+
+// EvaluateSpot checks a spot's watches after its occupancy changed. Armed
+// watches whose threshold the spot is now below fire and disarm; disarmed
+// watches re-arm once occupancy is back above threshold plus the margin.
+func (s *WatchService) EvaluateSpot(ctx context.Context, spotID string) error {
+	var name string
+	var capacity, occupancy int
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT name, capacity, current_occupancy FROM spots WHERE id = $1
+	`, spotID).Scan(&name, &capacity, &occupancy)
+	if err != nil {
+		return fmt.Errorf("failed to get spot occupancy: %w", err)
+	}
+
+	if capacity <= 0 {
+		return nil
+	}
+
+	// Same rounding as Spot.CalculateOccupancyStatus
+	percent := occupancy * 100 / capacity
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE spot_watches
+		SET armed = true, updated_at = NOW()
+		WHERE spot_id = $1 AND NOT armed AND $2 >= threshold_percent + $3
+	`, spotID, percent, s.hysteresis)
+	if err != nil {
+		return fmt.Errorf("failed to re-arm watches: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE spot_watches
+		SET armed = false, last_notified_at = NOW(), updated_at = NOW()
+		WHERE spot_id = $1 AND armed AND expires_at > NOW() AND $2 < threshold_percent
+		RETURNING user_id, threshold_percent
+	`, spotID, percent)
+	if err != nil {
+		return fmt.Errorf("failed to fire watches: %w", err)
+	}
+
+	type firedWatch struct {
+		UserID    string
+		Threshold int
+	}
+	fired := []firedWatch{}
+	for rows.Next() {
+		var w firedWatch
+		if err := rows.Scan(&w.UserID, &w.Threshold); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan watch: %w", err)
+		}
+		fired = append(fired, w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to fire watches: %w", err)
+	}
+
+	for _, w := range fired {
+		_, err := s.notifications.Enqueue(ctx, tx, NewNotification{
+			UserID: w.UserID,
+			Type:   NotificationSpotAvailable,
+			Title:  fmt.Sprintf("%s has space", name),
+			Body:   fmt.Sprintf("%s is now %d%% full (below your %d%% alert)", name, percent, w.Threshold),
+			Data: models.JSONB{
+				"spot_id":              spotID,
+				"occupancy_percentage": percent,
+				"current_occupancy":    occupancy,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if len(fired) > 0 {
+		log.Info().
+			Str("spot_id", spotID).
+			Int("occupancy_percentage", percent).
+			Int("notified", len(fired)).
+			Msg("Spot watches fired")
+	}
+
+	return nil
+}
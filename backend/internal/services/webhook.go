@@ -0,0 +1,685 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/events"
+	"github.com/harrypall/havn-backend/internal/models"
+	"github.com/harrypall/havn-backend/internal/rbac"
+	"github.com/harrypall/havn-backend/internal/webhook"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// WebhookSender signs and posts webhook deliveries. Send returns an error only
+// when no response was received.
+type WebhookSender interface {
+	Send(ctx context.Context, req webhook.Request) (*webhook.Response, error)
+	ValidateURL(raw string) error
+}
+
+// webhookEventTypes lists the events partners may subscribe to. Only events
+// about spots are offered; social events carry users' private data.
+var webhookEventTypes = map[string]bool{
+	events.SpotOccupancyChanged: true,
+}
+
+// WebhookActor is the user managing webhooks. Admins manage every subscription;
+// partners manage their own, limited to the spots and buildings in their scopes.
+type WebhookActor struct {
+	UserID string
+	Admin  bool
+}
+
+// WebhookService manages partner webhook subscriptions and fans events out
+// into deliveries
+type WebhookService struct {
+	db     *database.Database
+	sender WebhookSender
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(db *database.Database, sender WebhookSender) *WebhookService {
+	return &WebhookService{db: db, sender: sender}
+}
+
+// WebhookSubscriptionInput holds the fields of a new subscription. OwnerID may
+// only be set by admins creating a subscription on a partner's behalf.
+type WebhookSubscriptionInput struct {
+	OwnerID       string
+	URL           string
+	Description   *string
+	EventTypes    []string
+	SpotIDs       []string
+	BuildingNames []string
+}
+
+// WebhookSubscriptionPatch holds the fields to change; nil fields are kept
+type WebhookSubscriptionPatch struct {
+	URL           *string
+	Description   *string
+	EventTypes    *[]string
+	SpotIDs       *[]string
+	BuildingNames *[]string
+	Active        *bool
+}
+
+// webhookPayload is the JSON body sent to receivers
+type webhookPayload struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// webhookOccupancyData is the data of a spot.occupancy_changed delivery
+type webhookOccupancyData struct {
+	SpotID           string  `json:"spot_id"`
+	SpotName         string  `json:"spot_name"`
+	BuildingName     *string `json:"building_name"`
+	CurrentOccupancy int     `json:"current_occupancy"`
+	Capacity         int     `json:"capacity"`
+	OccupancyPercent int     `json:"occupancy_percentage"`
+	OccupancyStatus  string  `json:"occupancy_status"`
+	Cause            string  `json:"cause"`
+}
+
+const webhookSubscriptionColumns = `
+	id, owner_id, url, description, secret, spot_ids::text[], building_names,
+	event_types, active, created_at, updated_at
+`
+
+func scanWebhookSubscription(row pgx.Row) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	err := row.Scan(
+		&sub.ID,
+		&sub.OwnerID,
+		&sub.URL,
+		&sub.Description,
+		&sub.Secret,
+		&sub.SpotIDs,
+		&sub.BuildingNames,
+		&sub.EventTypes,
+		&sub.Active,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ListSubscriptions lists the subscriptions the actor manages
+func (s *WebhookService) ListSubscriptions(ctx context.Context, actor WebhookActor) ([]models.WebhookSubscription, error) {
+	if err := s.checkPartner(ctx, actor); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+webhookSubscriptionColumns+`
+		FROM webhook_subscriptions
+		WHERE $2 OR owner_id = $1
+		ORDER BY created_at DESC
+	`, actor.UserID, actor.Admin)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query webhook subscriptions")
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan webhook subscription")
+			continue
+		}
+		subs = append(subs, *sub)
+	}
+
+	return subs, rows.Err()
+}
+
+// GetSubscription returns a subscription the actor manages
+func (s *WebhookService) GetSubscription(ctx context.Context, actor WebhookActor, id string) (*models.WebhookSubscription, error) {
+	if err := s.checkPartner(ctx, actor); err != nil {
+		return nil, err
+	}
+	return s.getSubscription(ctx, actor, id)
+}
+
+func (s *WebhookService) getSubscription(ctx context.Context, actor WebhookActor, id string) (*models.WebhookSubscription, error) {
+	sub, err := scanWebhookSubscription(s.db.Pool.QueryRow(ctx, `
+		SELECT `+webhookSubscriptionColumns+`
+		FROM webhook_subscriptions
+		WHERE id = $1 AND ($3 OR owner_id = $2)
+	`, id, actor.UserID, actor.Admin))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("webhook not found")
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return sub, nil
+}
+
+// CreateSubscription creates a subscription and returns it with its signing
+// secret, which is not shown again
+func (s *WebhookService) CreateSubscription(ctx context.Context, actor WebhookActor, input WebhookSubscriptionInput) (*models.WebhookSubscription, string, error) {
+	if err := s.checkPartner(ctx, actor); err != nil {
+		return nil, "", err
+	}
+
+	ownerID := actor.UserID
+	if input.OwnerID != "" && input.OwnerID != actor.UserID {
+		if !actor.Admin {
+			return nil, "", fmt.Errorf("only admins can create webhooks for other users")
+		}
+		ownerID = input.OwnerID
+	}
+
+	input.EventTypes = dedupeStrings(input.EventTypes)
+	input.SpotIDs = dedupeStrings(input.SpotIDs)
+	input.BuildingNames = dedupeStrings(input.BuildingNames)
+
+	if err := s.validate(ctx, actor, input.URL, input.EventTypes, input.SpotIDs, input.BuildingNames); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	sub, err := scanWebhookSubscription(s.db.Pool.QueryRow(ctx, `
+		INSERT INTO webhook_subscriptions (owner_id, url, description, secret, spot_ids, building_names, event_types)
+		VALUES ($1, $2, $3, $4, $5::uuid[], $6, $7)
+		RETURNING `+webhookSubscriptionColumns,
+		ownerID, input.URL, input.Description, secret, input.SpotIDs, input.BuildingNames, input.EventTypes))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create webhook subscription")
+		return nil, "", fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	log.Info().
+		Str("user_id", actor.UserID).
+		Str("owner_id", ownerID).
+		Str("webhook_id", sub.ID).
+		Msg("Webhook subscription created")
+
+	return sub, secret, nil
+}
+
+// UpdateSubscription applies a patch to a subscription the actor manages
+func (s *WebhookService) UpdateSubscription(ctx context.Context, actor WebhookActor, id string, patch WebhookSubscriptionPatch) (*models.WebhookSubscription, error) {
+	if err := s.checkPartner(ctx, actor); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.getSubscription(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.URL != nil {
+		sub.URL = *patch.URL
+	}
+	if patch.Description != nil {
+		sub.Description = patch.Description
+	}
+	if patch.EventTypes != nil {
+		sub.EventTypes = *patch.EventTypes
+	}
+	if patch.SpotIDs != nil {
+		sub.SpotIDs = *patch.SpotIDs
+	}
+	if patch.BuildingNames != nil {
+		sub.BuildingNames = *patch.BuildingNames
+	}
+	if patch.Active != nil {
+		sub.Active = *patch.Active
+	}
+	sub.EventTypes = dedupeStrings(sub.EventTypes)
+	sub.SpotIDs = dedupeStrings(sub.SpotIDs)
+	sub.BuildingNames = dedupeStrings(sub.BuildingNames)
+
+	if err := s.validate(ctx, actor, sub.URL, sub.EventTypes, sub.SpotIDs, sub.BuildingNames); err != nil {
+		return nil, err
+	}
+
+	updated, err := scanWebhookSubscription(s.db.Pool.QueryRow(ctx, `
+		UPDATE webhook_subscriptions
+		SET url = $2, description = $3, spot_ids = $4::uuid[], building_names = $5,
+		    event_types = $6, active = $7, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+webhookSubscriptionColumns,
+		id, sub.URL, sub.Description, sub.SpotIDs, sub.BuildingNames, sub.EventTypes, sub.Active))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("webhook not found")
+		}
+		log.Error().Err(err).Msg("Failed to update webhook subscription")
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteSubscription deletes a subscription and its delivery log
+func (s *WebhookService) DeleteSubscription(ctx context.Context, actor WebhookActor, id string) error {
+	if err := s.checkPartner(ctx, actor); err != nil {
+		return err
+	}
+
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM webhook_subscriptions WHERE id = $1 AND ($3 OR owner_id = $2)
+	`, id, actor.UserID, actor.Admin)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete webhook subscription")
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+
+	log.Info().Str("user_id", actor.UserID).Str("webhook_id", id).Msg("Webhook subscription deleted")
+	return nil
+}
+
+// RotateSecret replaces a subscription's signing secret and returns the new one
+func (s *WebhookService) RotateSecret(ctx context.Context, actor WebhookActor, id string) (string, error) {
+	if err := s.checkPartner(ctx, actor); err != nil {
+		return "", err
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return "", err
+	}
+
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE webhook_subscriptions SET secret = $4, updated_at = NOW()
+		WHERE id = $1 AND ($3 OR owner_id = $2)
+	`, id, actor.UserID, actor.Admin, secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate webhook secret: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return "", fmt.Errorf("webhook not found")
+	}
+
+	log.Info().Str("user_id", actor.UserID).Str("webhook_id", id).Msg("Webhook secret rotated")
+	return secret, nil
+}
+
+const webhookDeliveryColumns = `
+	id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	response_status, response_body, duration_ms, last_error, delivered_at, created_at, updated_at
+`
+
+func scanWebhookDelivery(row pgx.Row) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload []byte
+	err := row.Scan(
+		&d.ID,
+		&d.SubscriptionID,
+		&d.EventID,
+		&d.EventType,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.ResponseStatus,
+		&d.ResponseBody,
+		&d.DurationMs,
+		&d.LastError,
+		&d.DeliveredAt,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	return &d, nil
+}
+
+// ListDeliveries returns a subscription's delivery log, newest first. An empty
+// status lists every delivery.
+func (s *WebhookService) ListDeliveries(ctx context.Context, actor WebhookActor, subscriptionID, status string, limit, offset int) ([]models.WebhookDelivery, error) {
+	if err := s.checkPartner(ctx, actor); err != nil {
+		return nil, err
+	}
+	if _, err := s.getSubscription(ctx, actor, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`, subscriptionID, status, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query webhook deliveries")
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan webhook delivery")
+			continue
+		}
+		deliveries = append(deliveries, *d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ReplayDelivery queues a delivery to be sent again with fresh attempts. The
+// body is resent unchanged, so receivers can dedupe on its id.
+func (s *WebhookService) ReplayDelivery(ctx context.Context, actor WebhookActor, subscriptionID, deliveryID string) (*models.WebhookDelivery, error) {
+	if err := s.checkPartner(ctx, actor); err != nil {
+		return nil, err
+	}
+	if _, err := s.getSubscription(ctx, actor, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	d, err := scanWebhookDelivery(s.db.Pool.QueryRow(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND subscription_id = $2
+		RETURNING `+webhookDeliveryColumns,
+		deliveryID, subscriptionID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("delivery not found")
+		}
+		log.Error().Err(err).Msg("Failed to replay webhook delivery")
+		return nil, fmt.Errorf("failed to replay delivery: %w", err)
+	}
+
+	log.Info().
+		Str("user_id", actor.UserID).
+		Str("webhook_id", subscriptionID).
+		Str("delivery_id", deliveryID).
+		Msg("Webhook delivery replayed")
+
+	return d, nil
+}
+
+// EnqueueDeliveries is the outbox handler that turns a domain event into one
+// delivery per matching subscription. Safe to run more than once per event.
+func (s *WebhookService) EnqueueDeliveries(ctx context.Context, event events.Event) error {
+	if !webhookEventTypes[event.Type] {
+		return nil
+	}
+
+	var occupancy OccupancyEvent
+	if err := event.Decode(&occupancy); err != nil {
+		return err
+	}
+
+	data := webhookOccupancyData{
+		SpotID:           occupancy.SpotID,
+		CurrentOccupancy: occupancy.CurrentOccupancy,
+		Capacity:         occupancy.Capacity,
+		OccupancyPercent: occupancy.OccupancyPercent,
+		OccupancyStatus:  occupancy.OccupancyStatus,
+		Cause:            occupancy.Cause,
+	}
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT name, building_name FROM spots WHERE id = $1
+	`, occupancy.SpotID).Scan(&data.SpotName, &data.BuildingName)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			// Spot deleted since; its subscriptions' scopes no longer match it
+			return nil
+		}
+		return fmt.Errorf("failed to get spot: %w", err)
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		ID:         event.ID,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt,
+		Data:       data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	// Owners only receive spots still in their partner scope, unless they
+	// have been granted a role that manages partners
+	adminRoles := []string{}
+	for _, r := range rbac.RolesWith(rbac.PermPartnersManage) {
+		adminRoles = append(adminRoles, string(r))
+	}
+
+	tag, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT w.id, $1, $2, $3::jsonb
+		FROM webhook_subscriptions w
+		WHERE w.active
+		AND $2 = ANY(w.event_types)
+		AND ($4::uuid = ANY(w.spot_ids) OR $5::text = ANY(w.building_names))
+		AND (
+			EXISTS (
+				SELECT 1 FROM partner_scopes p
+				WHERE p.user_id = w.owner_id AND (p.spot_id = $4::uuid OR p.building_name = $5::text)
+			)
+			OR EXISTS (
+				SELECT 1 FROM user_roles r
+				WHERE r.user_id = w.owner_id AND r.role = ANY($6::text[])
+			)
+		)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`, event.ID, event.Type, string(body), occupancy.SpotID, data.BuildingName, adminRoles)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		log.Debug().
+			Str("event_id", event.ID).
+			Int64("deliveries", tag.RowsAffected()).
+			Msg("Webhook deliveries enqueued")
+	}
+	return nil
+}
+
+// checkPartner refuses users who are neither admins nor partners
+func (s *WebhookService) checkPartner(ctx context.Context, actor WebhookActor) error {
+	if actor.Admin {
+		return nil
+	}
+
+	var partner bool
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM partner_scopes WHERE user_id = $1)
+	`, actor.UserID).Scan(&partner)
+	if err != nil {
+		return fmt.Errorf("failed to check partner access: %w", err)
+	}
+
+	if !partner {
+		return fmt.Errorf("partner access required")
+	}
+	return nil
+}
+
+// validate checks a subscription's url, event types and scope. Partners may
+// only subscribe to spots and buildings granted to them.
+func (s *WebhookService) validate(ctx context.Context, actor WebhookActor, url string, eventTypes, spotIDs, buildingNames []string) error {
+	if err := s.sender.ValidateURL(url); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+
+	if len(eventTypes) == 0 {
+		return fmt.Errorf("at least one event type is required")
+	}
+	for _, eventType := range eventTypes {
+		if !webhookEventTypes[eventType] {
+			return fmt.Errorf("unsupported event type: %s", eventType)
+		}
+	}
+
+	if len(spotIDs) == 0 && len(buildingNames) == 0 {
+		return fmt.Errorf("at least one spot or building is required")
+	}
+
+	if len(spotIDs) > 0 {
+		var missing int
+		err := s.db.Pool.QueryRow(ctx, `
+			SELECT COUNT(*) FROM unnest($1::uuid[]) AS requested(id)
+			WHERE NOT EXISTS (SELECT 1 FROM spots WHERE spots.id = requested.id)
+		`, spotIDs).Scan(&missing)
+		if err != nil {
+			return fmt.Errorf("failed to check spots: %w", err)
+		}
+		if missing > 0 {
+			return fmt.Errorf("spot not found")
+		}
+	}
+
+	if actor.Admin {
+		return nil
+	}
+
+	var outOfScope int
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM spots s
+			 WHERE s.id = ANY($2::uuid[])
+			 AND NOT EXISTS (
+				SELECT 1 FROM partner_scopes p
+				WHERE p.user_id = $1 AND (p.spot_id = s.id OR p.building_name = s.building_name)
+			 ))
+			+
+			(SELECT COUNT(*) FROM unnest($3::text[]) AS b(name)
+			 WHERE NOT EXISTS (
+				SELECT 1 FROM partner_scopes p WHERE p.user_id = $1 AND p.building_name = b.name
+			 ))
+	`, actor.UserID, spotIDs, buildingNames).Scan(&outOfScope)
+	if err != nil {
+		return fmt.Errorf("failed to check partner scopes: %w", err)
+	}
+
+	if outOfScope > 0 {
+		return fmt.Errorf("spot or building outside partner scope")
+	}
+	return nil
+}
+
+// ListPartnerScopes lists partner scopes, optionally for a single user
+func (s *WebhookService) ListPartnerScopes(ctx context.Context, userID string) ([]models.PartnerScope, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, user_id, spot_id, building_name, created_by, created_at
+		FROM partner_scopes
+		WHERE $1 = '' OR user_id::text = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query partner scopes")
+		return nil, fmt.Errorf("failed to get partner scopes: %w", err)
+	}
+	defer rows.Close()
+
+	scopes := []models.PartnerScope{}
+	for rows.Next() {
+		var scope models.PartnerScope
+		err := rows.Scan(&scope.ID, &scope.UserID, &scope.SpotID, &scope.BuildingName, &scope.CreatedBy, &scope.CreatedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan partner scope")
+			continue
+		}
+		scopes = append(scopes, scope)
+	}
+
+	return scopes, rows.Err()
+}
+
+// GrantPartnerScope gives a user partner access to a spot or a building
+func (s *WebhookService) GrantPartnerScope(ctx context.Context, adminID, userID string, spotID, buildingName *string) (*models.PartnerScope, error) {
+	if (spotID == nil) == (buildingName == nil) {
+		return nil, fmt.Errorf("exactly one of spot_id or building_name is required")
+	}
+
+	var scope models.PartnerScope
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO partner_scopes (user_id, spot_id, building_name, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, user_id, spot_id, building_name, created_by, created_at
+	`, userID, spotID, buildingName, adminID).Scan(
+		&scope.ID,
+		&scope.UserID,
+		&scope.SpotID,
+		&scope.BuildingName,
+		&scope.CreatedBy,
+		&scope.CreatedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("partner scope already exists")
+		}
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("user or spot not found")
+		}
+		log.Error().Err(err).Msg("Failed to grant partner scope")
+		return nil, fmt.Errorf("failed to grant partner scope: %w", err)
+	}
+
+	log.Info().
+		Str("admin_id", adminID).
+		Str("user_id", userID).
+		Str("scope_id", scope.ID).
+		Msg("Partner scope granted")
+
+	return &scope, nil
+}
+
+// RevokePartnerScope removes a partner scope. Existing subscriptions are kept
+// but stop receiving events for the revoked spots, and can't be edited to
+// include them again.
+func (s *WebhookService) RevokePartnerScope(ctx context.Context, adminID, scopeID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM partner_scopes WHERE id = $1`, scopeID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to revoke partner scope")
+		return fmt.Errorf("failed to revoke partner scope: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("partner scope not found")
+	}
+
+	log.Info().Str("admin_id", adminID).Str("scope_id", scopeID).Msg("Partner scope revoked")
+	return nil
+}
+
+// dedupeStrings trims values and drops blanks and duplicates, keeping order
+func dedupeStrings(values []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	return result
+}
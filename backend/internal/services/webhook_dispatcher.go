@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/harrypall/havn-backend/internal/webhook"
+	"github.com/harrypall/havn-backend/pkg/database"
+	"github.com/rs/zerolog/log"
+)
+
+// webhookClaimLease is how long a claimed delivery is hidden from other
+// dispatchers. It must outlast the sender's request timeout.
+const webhookClaimLease = 2 * time.Minute
+
+// webhookPruneInterval is how often finished deliveries are pruned
+const webhookPruneInterval = time.Hour
+
+// WebhookDispatcher sends pending webhook deliveries, retrying failures with
+// exponential backoff
+type WebhookDispatcher struct {
+	db          *database.Database
+	sender      WebhookSender
+	interval    time.Duration
+	batchSize   int
+	concurrency int
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	retention   time.Duration
+}
+
+// NewWebhookDispatcher creates a webhook dispatcher with settings read from the environment
+func NewWebhookDispatcher(db *database.Database, sender WebhookSender) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		db:          db,
+		sender:      sender,
+		interval:    envDuration("WEBHOOK_DISPATCH_INTERVAL", 2*time.Second),
+		batchSize:   envInt("WEBHOOK_DISPATCH_BATCH_SIZE", 50),
+		concurrency: envInt("WEBHOOK_DISPATCH_CONCURRENCY", 8),
+		maxAttempts: envInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		baseBackoff: envDuration("WEBHOOK_RETRY_BACKOFF", 30*time.Second),
+		maxBackoff:  envDuration("WEBHOOK_RETRY_MAX_BACKOFF", 6*time.Hour),
+		retention:   envDuration("WEBHOOK_DELIVERY_RETENTION", 30*24*time.Hour),
+	}
+}
+
+// pendingWebhook is a claimed delivery with its subscription's endpoint
+type pendingWebhook struct {
+	ID        string
+	EventType string
+	Payload   []byte
+	Attempts  int
+	URL       string
+	Secret    string
+}
+
+// Run dispatches pending deliveries until ctx is cancelled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	log.Info().Dur("interval", d.interval).Msg("Webhook dispatcher started")
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		// Keep draining while full batches are being claimed
+		for {
+			n, err := d.DispatchPending(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("Webhook dispatch failed")
+				break
+			}
+			if n < d.batchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPrune) > webhookPruneInterval {
+			if err := d.pruneFinished(ctx); err != nil {
+				log.Error().Err(err).Msg("Webhook delivery pruning failed")
+			}
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchPending claims one batch of due deliveries, sends them concurrently
+// and records each result. Returns the number claimed.
+func (d *WebhookDispatcher) DispatchPending(ctx context.Context) (int, error) {
+	pending, err := d.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sem := make(chan struct{}, max(d.concurrency, 1))
+	var wg sync.WaitGroup
+	for _, p := range pending {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(p pendingWebhook) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			d.deliver(ctx, p)
+		}(p)
+	}
+	wg.Wait()
+
+	return len(pending), nil
+}
+
+// claim leases a batch of due deliveries to this dispatcher. Deliveries of
+// inactive subscriptions stay pending until the subscription is re-enabled.
+func (d *WebhookDispatcher) claim(ctx context.Context) ([]pendingWebhook, error) {
+	rows, err := d.db.Pool.Query(ctx, `
+		WITH due AS (
+			SELECT wd.id FROM webhook_deliveries wd
+			JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id AND ws.active
+			WHERE wd.status = 'pending' AND wd.next_attempt_at <= NOW()
+			AND (wd.locked_until IS NULL OR wd.locked_until <= NOW())
+			ORDER BY wd.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF wd SKIP LOCKED
+		)
+		UPDATE webhook_deliveries wd
+		SET attempts = wd.attempts + 1,
+		    locked_until = NOW() + make_interval(secs => $2),
+		    updated_at = NOW()
+		FROM due, webhook_subscriptions ws
+		WHERE wd.id = due.id AND ws.id = wd.subscription_id
+		RETURNING wd.id, wd.event_type, wd.payload, wd.attempts, ws.url, ws.secret
+	`, d.batchSize, webhookClaimLease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	pending := []pendingWebhook{}
+	for rows.Next() {
+		var p pendingWebhook
+		if err := rows.Scan(&p.ID, &p.EventType, &p.Payload, &p.Attempts, &p.URL, &p.Secret); err != nil {
+			log.Error().Err(err).Msg("Failed to scan claimed webhook delivery")
+			continue
+		}
+		pending = append(pending, p)
+	}
+
+	return pending, rows.Err()
+}
+
+// deliver sends one delivery and records the outcome
+func (d *WebhookDispatcher) deliver(ctx context.Context, p pendingWebhook) {
+	resp, err := d.sender.Send(ctx, webhook.Request{
+		URL:        p.URL,
+		Secret:     p.Secret,
+		EventType:  p.EventType,
+		DeliveryID: p.ID,
+		Body:       p.Payload,
+	})
+
+	switch {
+	case err != nil:
+		d.retryOrFail(ctx, p, nil, err.Error())
+	case resp.OK():
+		d.markSucceeded(ctx, p.ID, resp)
+	default:
+		d.retryOrFail(ctx, p, resp, fmt.Sprintf("receiver responded with status %d", resp.StatusCode))
+	}
+}
+
+// backoff returns the delay before the given retry attempt
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.baseBackoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
+
+// markSucceeded records a delivery the receiver accepted
+func (d *WebhookDispatcher) markSucceeded(ctx context.Context, deliveryID string, resp *webhook.Response) {
+	_, err := d.db.Pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'succeeded', delivered_at = NOW(), locked_until = NULL,
+		    response_status = $2, response_body = $3, duration_ms = $4,
+		    last_error = NULL, updated_at = NOW()
+		WHERE id = $1
+	`, deliveryID, resp.StatusCode, resp.Body, resp.Duration.Milliseconds())
+	if err != nil {
+		log.Error().Err(err).Str("delivery_id", deliveryID).Msg("Failed to mark webhook delivered")
+	}
+}
+
+// retryOrFail schedules another attempt, or fails the delivery once it has
+// used all its attempts. resp is nil when no response was received.
+func (d *WebhookDispatcher) retryOrFail(ctx context.Context, p pendingWebhook, resp *webhook.Response, reason string) {
+	var status *int
+	var body *string
+	var duration *int64
+	if resp != nil {
+		ms := resp.Duration.Milliseconds()
+		status, body, duration = &resp.StatusCode, &resp.Body, &ms
+	}
+
+	if p.Attempts >= d.maxAttempts {
+		log.Warn().
+			Str("delivery_id", p.ID).
+			Int("attempts", p.Attempts).
+			Str("error", reason).
+			Msg("Webhook delivery failed")
+	}
+
+	_, err := d.db.Pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = CASE WHEN $2 THEN 'failed' ELSE 'pending' END,
+		    next_attempt_at = NOW() + make_interval(secs => $3),
+		    locked_until = NULL,
+		    response_status = $4, response_body = $5, duration_ms = $6,
+		    last_error = $7, updated_at = NOW()
+		WHERE id = $1
+	`, p.ID, p.Attempts >= d.maxAttempts, d.backoff(p.Attempts).Seconds(), status, body, duration, reason)
+	if err != nil {
+		log.Error().Err(err).Str("delivery_id", p.ID).Msg("Failed to schedule webhook retry")
+	}
+}
+
+// pruneFinished deletes succeeded and failed deliveries past the retention period
+func (d *WebhookDispatcher) pruneFinished(ctx context.Context) error {
+	tag, err := d.db.Pool.Exec(ctx, `
+		DELETE FROM webhook_deliveries
+		WHERE status IN ('succeeded', 'failed')
+		AND updated_at < NOW() - make_interval(secs => $1)
+	`, d.retention.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to prune webhook deliveries: %w", err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		log.Info().Int64("pruned", tag.RowsAffected()).Msg("Pruned webhook deliveries")
+	}
+	return nil
+}
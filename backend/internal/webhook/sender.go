@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// maxResponseBody is how much of a receiver's response is kept for delivery logs
+const maxResponseBody = 1024
+
+// Request is a single webhook delivery
+type Request struct {
+	URL        string
+	Secret     string
+	EventType  string
+	DeliveryID string
+	Body       []byte
+}
+
+// Response is what the receiver answered. Body is truncated.
+type Response struct {
+	StatusCode int
+	Body       string
+	Duration   time.Duration
+}
+
+// OK reports whether the receiver accepted the delivery
+func (r *Response) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// HTTPSender posts signed deliveries over HTTP
+type HTTPSender struct {
+	client       *http.Client
+	allowPrivate bool
+}
+
+// NewHTTPSender creates a sender with the given per-request timeout. Unless
+// allowPrivate is set, receivers must use https and may not resolve to
+// loopback, private or link-local addresses, so subscriptions can't be used
+// to reach internal services.
+func NewHTTPSender(timeout time.Duration, allowPrivate bool) *HTTPSender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = rejectPrivateAddress
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &HTTPSender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// Redirects could bypass the address checks and re-sign nothing useful
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		allowPrivate: allowPrivate,
+	}
+}
+
+// ValidateURL checks that a receiver URL is acceptable to this sender
+func (s *HTTPSender) ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("url must be absolute")
+	}
+
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !s.allowPrivate {
+			return fmt.Errorf("url must use https")
+		}
+	default:
+		return fmt.Errorf("url must use https")
+	}
+
+	if u.User != nil {
+		return fmt.Errorf("url must not contain credentials")
+	}
+	return nil
+}
+
+// Send signs and posts a delivery. An error means no response was received.
+func (s *HTTPSender) Send(ctx context.Context, req Request) (*Response, error) {
+	if err := s.ValidateURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "havn-webhooks/1")
+	httpReq.Header.Set(HeaderEvent, req.EventType)
+	httpReq.Header.Set(HeaderDelivery, req.DeliveryID)
+	httpReq.Header.Set(HeaderSignature, Sign(req.Secret, time.Now(), req.Body))
+
+	start := time.Now()
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// carrierGradeNAT is the shared address space of RFC 6598, which is often
+// routable inside cloud VPCs but isn't covered by net.IP.IsPrivate
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// rejectPrivateAddress refuses connections to non-public addresses. It runs
+// after DNS resolution, so hostnames that resolve internally are caught too.
+func rejectPrivateAddress(network, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("webhook receiver address %q is not an IP", host)
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() || carrierGradeNAT.Contains(ip) {
+		return fmt.Errorf("webhook receiver address %s is not public", ip)
+	}
+	return nil
+}
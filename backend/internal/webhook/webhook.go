@@ -0,0 +1,86 @@
+// Package webhook signs and sends outbound webhook deliveries, and provides a
+// local receiver for tests.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers set on every delivery
+const (
+	HeaderEvent     = "X-Havn-Event"
+	HeaderDelivery  = "X-Havn-Delivery"
+	HeaderSignature = "X-Havn-Signature"
+)
+
+// SecretPrefix marks webhook signing secrets so they are recognisable if leaked
+const SecretPrefix = "whsec_"
+
+// NewSecret returns a random signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return SecretPrefix + hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Havn-Signature header value for body sent at timestamp:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Including the
+// timestamp lets receivers reject replayed requests.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, signature(secret, t, body))
+}
+
+// Verify checks a signature header against body, rejecting signatures older
+// than tolerance. A zero tolerance skips the age check.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+
+	if t == "" || v1 == "" {
+		return fmt.Errorf("malformed signature header")
+	}
+
+	if tolerance > 0 {
+		unix, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return fmt.Errorf("malformed signature timestamp")
+		}
+		if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+			return fmt.Errorf("signature timestamp outside tolerance")
+		}
+	}
+
+	if !hmac.Equal([]byte(v1), []byte(signature(secret, t, body))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,94 @@
+// Package webhooktest provides a local HTTP receiver for exercising webhook
+// deliveries in tests.
+package webhooktest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/harrypall/havn-backend/internal/webhook"
+)
+
+// Delivery is a request received by a Receiver
+type Delivery struct {
+	EventType  string
+	DeliveryID string
+	Signature  string
+	Body       []byte
+
+	// Verified reports whether the signature matched the receiver's secret
+	Verified bool
+}
+
+// Decode unmarshals the delivery body into v
+func (d Delivery) Decode(v interface{}) error {
+	return json.Unmarshal(d.Body, v)
+}
+
+// Receiver is a local webhook endpoint that records deliveries and verifies
+// their signatures. Use NewHTTPSender(timeout, true) to send to it.
+type Receiver struct {
+	URL string
+
+	server     *httptest.Server
+	secret     string
+	mu         sync.Mutex
+	deliveries []Delivery
+	statuses   []int
+}
+
+// NewReceiver starts a receiver that verifies signatures with secret. Close
+// it when done.
+func NewReceiver(secret string) *Receiver {
+	r := &Receiver{secret: secret}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serve))
+	r.URL = r.server.URL
+	return r
+}
+
+// Close shuts the receiver down
+func (r *Receiver) Close() {
+	r.server.Close()
+}
+
+// RespondWith queues status codes for the next requests, in order. Once
+// they're used up the receiver answers 200.
+func (r *Receiver) RespondWith(statuses ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, statuses...)
+}
+
+// Deliveries returns a copy of the requests received so far
+func (r *Receiver) Deliveries() []Delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Delivery(nil), r.deliveries...)
+}
+
+func (r *Receiver) serve(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	signature := req.Header.Get(webhook.HeaderSignature)
+
+	r.mu.Lock()
+	r.deliveries = append(r.deliveries, Delivery{
+		EventType:  req.Header.Get(webhook.HeaderEvent),
+		DeliveryID: req.Header.Get(webhook.HeaderDelivery),
+		Signature:  signature,
+		Body:       body,
+		Verified:   webhook.Verify(r.secret, signature, body, 0) == nil,
+	})
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status = r.statuses[0]
+		r.statuses = r.statuses[1:]
+	}
+	r.mu.Unlock()
+
+	w.WriteHeader(status)
+	w.Write([]byte(http.StatusText(status)))
+}
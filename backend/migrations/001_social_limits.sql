@@ -0,0 +1,29 @@
+-- ============================================================
+-- SOCIAL LIMITS (anti-spam quotas for friend and spot-save requests)
+-- ============================================================
+
+-- Per-action overrides for the limits configured via environment variables.
+-- A value of 0 disables that particular limit.
+CREATE TABLE social_limits (
+  action VARCHAR(50) PRIMARY KEY CHECK (action IN ('friend_request', 'spot_save')),
+  daily_quota INTEGER NOT NULL DEFAULT 0,
+  max_pending INTEGER NOT NULL DEFAULT 0,
+  decline_cooldown INTERVAL NOT NULL DEFAULT INTERVAL '0',
+  updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Ledger of sent requests, used to enforce rolling daily quotas.
+-- Kept separate from friendships/spot_save_requests so deleting or
+-- re-opening a request does not reset the sender's quota.
+CREATE TABLE social_request_log (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  target_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  action VARCHAR(50) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_social_request_log_user ON social_request_log(user_id, action, created_at DESC);
+
+CREATE TRIGGER update_social_limits_updated_at BEFORE UPDATE ON social_limits
+  FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
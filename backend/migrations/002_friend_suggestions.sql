@@ -0,0 +1,11 @@
+-- ============================================================
+-- FRIEND SUGGESTIONS
+-- ============================================================
+
+-- Opt-out for using check-in history (studying at the same spot at the same
+-- time) when suggesting friends. Applies in both directions: an opted-out user
+-- is neither suggested to others nor shown suggestions based on co-location.
+ALTER TABLE profiles ADD COLUMN colocation_suggestions BOOLEAN NOT NULL DEFAULT true;
+
+-- Speeds up the overlapping-session self join used for co-location scoring
+CREATE INDEX idx_occupancy_spot_window ON occupancy_logs(spot_id, checked_in_at, checked_out_at);
@@ -0,0 +1,34 @@
+-- ============================================================
+-- LOCATION VISIBILITY
+-- ============================================================
+
+-- 'building' shares only the building a user is in (not the exact spot) with friends
+ALTER TABLE profiles DROP CONSTRAINT IF EXISTS profiles_location_sharing_check;
+ALTER TABLE profiles ADD CONSTRAINT profiles_location_sharing_check
+  CHECK (location_sharing IN ('everyone', 'friends', 'building', 'none'));
+
+-- Function: How precisely viewer_id may see owner_id's location.
+-- Returns 'exact', 'building' or 'hidden'. All friend and spot visibility
+-- queries go through this so the rules live in one place.
+CREATE OR REPLACE FUNCTION location_visibility(owner_id UUID, viewer_id UUID)
+RETURNS TEXT AS $$
+  SELECT CASE
+    WHEN owner_id = viewer_id THEN 'exact'
+    WHEN EXISTS (
+      SELECT 1 FROM friendships
+      WHERE ((user_id = owner_id AND friend_id = viewer_id) OR (user_id = viewer_id AND friend_id = owner_id))
+      AND status = 'blocked'
+    ) THEN 'hidden'
+    WHEN p.location_sharing = 'everyone' THEN 'exact'
+    WHEN NOT EXISTS (
+      SELECT 1 FROM friendships
+      WHERE ((user_id = owner_id AND friend_id = viewer_id) OR (user_id = viewer_id AND friend_id = owner_id))
+      AND status = 'accepted'
+    ) THEN 'hidden'
+    WHEN p.location_sharing = 'friends' THEN 'exact'
+    WHEN p.location_sharing = 'building' THEN 'building'
+    ELSE 'hidden'
+  END
+  FROM profiles p
+  WHERE p.id = owner_id;
+$$ LANGUAGE sql STABLE;
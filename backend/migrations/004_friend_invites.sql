@@ -0,0 +1,26 @@
+-- ============================================================
+-- FRIEND INVITES (signed invite links / QR codes)
+-- ============================================================
+CREATE TABLE friend_invites (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  inviter_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+
+  -- Usage limits
+  max_uses INTEGER NOT NULL DEFAULT 1 CHECK (max_uses > 0),
+  use_count INTEGER NOT NULL DEFAULT 0,
+
+  expires_at TIMESTAMPTZ NOT NULL,
+  revoked_at TIMESTAMPTZ,
+
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_friend_invites_inviter ON friend_invites(inviter_id, created_at DESC);
+
+-- One row per redemption so a multi-use invite can't be redeemed twice by the same user
+CREATE TABLE friend_invite_redemptions (
+  invite_id UUID NOT NULL REFERENCES friend_invites(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  redeemed_at TIMESTAMPTZ DEFAULT NOW(),
+  PRIMARY KEY (invite_id, user_id)
+);
@@ -0,0 +1,49 @@
+-- ============================================================
+-- LOCATION SHARES (time-boxed location sharing with one friend)
+-- ============================================================
+CREATE TABLE location_shares (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  owner_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,   -- whose location is shared
+  grantee_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE, -- who can see it
+  expires_at TIMESTAMPTZ NOT NULL,
+  revoked_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+
+  CONSTRAINT no_self_share CHECK (owner_id != grantee_id)
+);
+
+CREATE INDEX idx_location_shares_owner ON location_shares(owner_id, expires_at DESC);
+CREATE INDEX idx_location_shares_grantee ON location_shares(grantee_id, expires_at DESC);
+CREATE INDEX idx_location_shares_active ON location_shares(owner_id, grantee_id) WHERE revoked_at IS NULL;
+
+-- Function: location_visibility now honours active share grants, which give
+-- the grantee exact location even if the owner's global setting is stricter
+CREATE OR REPLACE FUNCTION location_visibility(owner_id UUID, viewer_id UUID)
+RETURNS TEXT AS $$
+  SELECT CASE
+    WHEN owner_id = viewer_id THEN 'exact'
+    WHEN EXISTS (
+      SELECT 1 FROM friendships
+      WHERE ((user_id = owner_id AND friend_id = viewer_id) OR (user_id = viewer_id AND friend_id = owner_id))
+      AND status = 'blocked'
+    ) THEN 'hidden'
+    WHEN p.location_sharing = 'everyone' THEN 'exact'
+    WHEN NOT EXISTS (
+      SELECT 1 FROM friendships
+      WHERE ((user_id = owner_id AND friend_id = viewer_id) OR (user_id = viewer_id AND friend_id = owner_id))
+      AND status = 'accepted'
+    ) THEN 'hidden'
+    WHEN EXISTS (
+      SELECT 1 FROM location_shares ls
+      WHERE ls.owner_id = location_visibility.owner_id
+      AND ls.grantee_id = location_visibility.viewer_id
+      AND ls.revoked_at IS NULL
+      AND ls.expires_at > NOW()
+    ) THEN 'exact'
+    WHEN p.location_sharing = 'friends' THEN 'exact'
+    WHEN p.location_sharing = 'building' THEN 'building'
+    ELSE 'hidden'
+  END
+  FROM profiles p
+  WHERE p.id = owner_id;
+$$ LANGUAGE sql STABLE;
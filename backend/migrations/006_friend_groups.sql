@@ -0,0 +1,80 @@
+-- ============================================================
+-- FRIEND GROUPS (close friends / custom lists)
+-- ============================================================
+CREATE TABLE friend_groups (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  owner_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  name VARCHAR(50) NOT NULL,
+
+  -- How members see the owner's location and check-ins.
+  -- 'default' falls back to profiles.location_sharing.
+  visibility VARCHAR(20) NOT NULL DEFAULT 'default' CHECK (visibility IN ('default', 'exact', 'building', 'hidden')),
+
+  -- Suppress notifications caused by members of this group
+  notifications_muted BOOLEAN NOT NULL DEFAULT false,
+
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+  CONSTRAINT unique_group_name UNIQUE(owner_id, name)
+);
+
+CREATE TABLE friend_group_members (
+  group_id UUID NOT NULL REFERENCES friend_groups(id) ON DELETE CASCADE,
+  member_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  added_at TIMESTAMPTZ DEFAULT NOW(),
+  PRIMARY KEY (group_id, member_id)
+);
+
+CREATE INDEX idx_friend_group_members_member ON friend_group_members(member_id);
+
+CREATE TRIGGER update_friend_groups_updated_at BEFORE UPDATE ON friend_groups
+  FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Function: location_visibility with per-group levels. Order of precedence for
+-- friends: active share grant, then the most permissive group level the viewer
+-- is in, then the owner's global setting. Non-friends only ever see owners
+-- who share with 'everyone'.
+CREATE OR REPLACE FUNCTION location_visibility(owner_id UUID, viewer_id UUID)
+RETURNS TEXT AS $$
+  SELECT CASE
+    WHEN owner_id = viewer_id THEN 'exact'
+    WHEN EXISTS (
+      SELECT 1 FROM friendships
+      WHERE ((user_id = owner_id AND friend_id = viewer_id) OR (user_id = viewer_id AND friend_id = owner_id))
+      AND status = 'blocked'
+    ) THEN 'hidden'
+    WHEN NOT EXISTS (
+      SELECT 1 FROM friendships
+      WHERE ((user_id = owner_id AND friend_id = viewer_id) OR (user_id = viewer_id AND friend_id = owner_id))
+      AND status = 'accepted'
+    ) THEN CASE WHEN p.location_sharing = 'everyone' THEN 'exact' ELSE 'hidden' END
+    WHEN EXISTS (
+      SELECT 1 FROM location_shares ls
+      WHERE ls.owner_id = location_visibility.owner_id
+      AND ls.grantee_id = location_visibility.viewer_id
+      AND ls.revoked_at IS NULL
+      AND ls.expires_at > NOW()
+    ) THEN 'exact'
+    ELSE COALESCE(
+      (
+        SELECT g.visibility
+        FROM friend_groups g
+        JOIN friend_group_members m ON m.group_id = g.id
+        WHERE g.owner_id = location_visibility.owner_id
+        AND m.member_id = location_visibility.viewer_id
+        AND g.visibility != 'default'
+        ORDER BY CASE g.visibility WHEN 'exact' THEN 0 WHEN 'building' THEN 1 ELSE 2 END
+        LIMIT 1
+      ),
+      CASE p.location_sharing
+        WHEN 'everyone' THEN 'exact'
+        WHEN 'friends' THEN 'exact'
+        WHEN 'building' THEN 'building'
+        ELSE 'hidden'
+      END
+    )
+  END
+  FROM profiles p
+  WHERE p.id = owner_id;
+$$ LANGUAGE sql STABLE;
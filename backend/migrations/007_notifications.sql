@@ -0,0 +1,30 @@
+-- ============================================================
+-- NOTIFICATIONS TABLE (in-app inbox and push notification queue)
+-- ============================================================
+-- Matches the table in docs/design.md; created here for databases that were
+-- provisioned before it existed.
+CREATE TABLE IF NOT EXISTS notifications (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+
+  type VARCHAR(50) NOT NULL CHECK (type IN ('friend_request', 'friend_accepted', 'spot_save_request', 'spot_save_response', 'friend_nearby')),
+
+  title VARCHAR(200) NOT NULL,
+  body TEXT NOT NULL,
+  data JSONB, -- Additional payload for deep linking
+
+  -- Status
+  status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+  sent_at TIMESTAMPTZ,
+  error_message TEXT,
+
+  -- Read status (for in-app notifications)
+  read BOOLEAN DEFAULT false,
+  read_at TIMESTAMPTZ,
+
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_pending ON notifications(status) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE read = false;
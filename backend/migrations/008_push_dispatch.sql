@@ -0,0 +1,9 @@
+-- ============================================================
+-- PUSH DISPATCH (retry bookkeeping for the notifications queue)
+-- ============================================================
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- The dispatcher claims due pending rows oldest first
+DROP INDEX IF EXISTS idx_notifications_pending;
+CREATE INDEX idx_notifications_pending ON notifications(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,35 @@
+-- ============================================================
+-- USER DEVICES (replaces profiles.push_token)
+-- ============================================================
+CREATE TABLE user_devices (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  platform VARCHAR(20) NOT NULL CHECK (platform IN ('ios', 'android', 'web', 'unknown')),
+  push_token TEXT NOT NULL,
+  app_version VARCHAR(50),
+  notifications_enabled BOOLEAN NOT NULL DEFAULT true,
+  last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+  -- A token identifies one app install; re-registering moves it to the new user
+  CONSTRAINT unique_device_token UNIQUE(push_token)
+);
+
+CREATE INDEX idx_user_devices_user ON user_devices(user_id) WHERE notifications_enabled = true;
+CREATE INDEX idx_user_devices_last_seen ON user_devices(last_seen_at);
+
+-- Carry over tokens registered through the old single-token column
+INSERT INTO user_devices (user_id, platform, push_token, last_seen_at)
+SELECT id, 'unknown', push_token, updated_at
+FROM profiles
+WHERE push_token IS NOT NULL AND push_token != ''
+ON CONFLICT (push_token) DO NOTHING;
+
+ALTER TABLE profiles DROP COLUMN push_token;
+
+ALTER TABLE user_devices ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own devices"
+  ON user_devices FOR SELECT
+  USING (auth.uid() = user_id);
@@ -0,0 +1,30 @@
+-- ============================================================
+-- NOTIFICATION PREFERENCES
+-- ============================================================
+-- Pushes blocked by the recipient's preferences (master switch, quiet hours
+-- in drop mode, daily cap) are marked 'suppressed' but stay in the inbox
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_status_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_status_check
+  CHECK (status IN ('pending', 'sent', 'failed', 'suppressed'));
+
+-- Default document for new profiles; see services.Preferences for the schema
+ALTER TABLE profiles ALTER COLUMN preferences SET DEFAULT '{
+  "noise_tolerance": "moderate",
+  "outlet_required": false,
+  "auto_checkout_hours": 4,
+  "notifications_enabled": true,
+  "notifications": {
+    "friend_requests": true,
+    "spot_saves": true,
+    "friend_nearby": true,
+    "spot_availability": true
+  },
+  "quiet_hours": {
+    "enabled": false,
+    "start": "22:00",
+    "end": "07:00",
+    "timezone": "America/Los_Angeles",
+    "mode": "defer"
+  },
+  "daily_push_cap": 0
+}'::jsonb;
@@ -0,0 +1,14 @@
+-- ============================================================
+-- FRIEND NEARBY ALERTS (one alert per recipient/friend pair per day)
+-- ============================================================
+CREATE TABLE friend_nearby_alerts (
+  recipient_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  subject_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  -- Calendar day in the recipient's timezone
+  alert_date DATE NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+
+  PRIMARY KEY (recipient_id, subject_id, alert_date)
+);
+
+CREATE INDEX idx_friend_nearby_alerts_date ON friend_nearby_alerts(alert_date);
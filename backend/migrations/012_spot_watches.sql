@@ -0,0 +1,31 @@
+-- ============================================================
+-- SPOT WATCHES ("tell me when this spot has space")
+-- ============================================================
+CREATE TABLE spot_watches (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  spot_id UUID NOT NULL REFERENCES spots(id) ON DELETE CASCADE,
+
+  -- Notify when occupancy percentage drops below this value
+  threshold_percent INTEGER NOT NULL CHECK (threshold_percent BETWEEN 1 AND 100),
+  expires_at TIMESTAMPTZ NOT NULL,
+
+  -- Hysteresis: a watch fires once, then re-arms only after occupancy climbs
+  -- back above the threshold plus a margin
+  armed BOOLEAN NOT NULL DEFAULT true,
+  last_notified_at TIMESTAMPTZ,
+
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+  CONSTRAINT unique_spot_watch UNIQUE(user_id, spot_id)
+);
+
+CREATE INDEX idx_spot_watches_spot ON spot_watches(spot_id, expires_at);
+
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check
+  CHECK (type IN ('friend_request', 'friend_accepted', 'spot_save_request', 'spot_save_response', 'friend_nearby', 'spot_available'));
+
+-- Speeds up the auto-checkout sweep
+CREATE INDEX IF NOT EXISTS idx_occupancy_logs_active ON occupancy_logs(checked_in_at) WHERE checked_out_at IS NULL;
@@ -0,0 +1,36 @@
+-- ============================================================
+-- SPOT WAITLIST (first-in, first-out queue for full spots)
+-- ============================================================
+CREATE TABLE spot_waitlist_entries (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  spot_id UUID NOT NULL REFERENCES spots(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+
+  -- waiting: in the queue
+  -- offered: holds an exclusive claim on a freed seat until claim_expires_at
+  -- claimed/expired/left: no longer in the queue
+  status VARCHAR(20) NOT NULL DEFAULT 'waiting'
+    CHECK (status IN ('waiting', 'offered', 'claimed', 'expired', 'left')),
+  joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  offered_at TIMESTAMPTZ,
+  claim_expires_at TIMESTAMPTZ,
+  resolved_at TIMESTAMPTZ,
+
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- A user holds at most one open entry per spot
+CREATE UNIQUE INDEX idx_waitlist_open_entry ON spot_waitlist_entries(spot_id, user_id)
+  WHERE status IN ('waiting', 'offered');
+
+-- Queue order
+CREATE INDEX idx_waitlist_queue ON spot_waitlist_entries(spot_id, joined_at, id)
+  WHERE status = 'waiting';
+
+-- Expiry sweep
+CREATE INDEX idx_waitlist_offers ON spot_waitlist_entries(claim_expires_at)
+  WHERE status = 'offered';
+
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check
+  CHECK (type IN ('friend_request', 'friend_accepted', 'spot_save_request', 'spot_save_response', 'friend_nearby', 'spot_available', 'waitlist_offer'));
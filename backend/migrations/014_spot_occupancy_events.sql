@@ -0,0 +1,16 @@
+-- ============================================================
+-- SPOT OCCUPANCY EVENTS (replay log for the realtime stream)
+-- ============================================================
+-- One row per occupancy change, written in the same transaction as the
+-- check-in or checkout. Stream clients resume from the last id they saw.
+CREATE TABLE spot_occupancy_events (
+  id BIGSERIAL PRIMARY KEY,
+  spot_id UUID NOT NULL REFERENCES spots(id) ON DELETE CASCADE,
+  current_occupancy INTEGER NOT NULL,
+  capacity INTEGER NOT NULL,
+  cause VARCHAR(20) NOT NULL CHECK (cause IN ('checkin', 'checkout', 'auto_checkout')),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Retention pruning
+CREATE INDEX idx_occupancy_events_created ON spot_occupancy_events(created_at);
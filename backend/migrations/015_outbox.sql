@@ -0,0 +1,37 @@
+-- ============================================================
+-- TRANSACTIONAL OUTBOX
+-- ============================================================
+-- Domain events are written here in the same transaction as the business
+-- change. A relay worker delivers them to registered handlers at least once.
+CREATE TABLE outbox_events (
+  id BIGSERIAL PRIMARY KEY,
+  event_id UUID NOT NULL UNIQUE,
+  event_type VARCHAR(100) NOT NULL,
+
+  -- Events for the same aggregate are delivered in id order
+  aggregate_type VARCHAR(50) NOT NULL,
+  aggregate_id TEXT NOT NULL,
+
+  payload JSONB NOT NULL,
+  occurred_at TIMESTAMPTZ NOT NULL,
+
+  -- pending: awaiting delivery or retry
+  -- delivered: every handler succeeded
+  -- dead: gave up after the maximum attempts; retried from the admin API
+  status VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'delivered', 'dead')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  locked_until TIMESTAMPTZ,
+  last_error TEXT,
+  delivered_at TIMESTAMPTZ,
+
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Finding the head of each aggregate's queue
+CREATE INDEX idx_outbox_pending ON outbox_events(aggregate_type, aggregate_id, id)
+  WHERE status = 'pending';
+
+-- Admin listing and retention pruning
+CREATE INDEX idx_outbox_status ON outbox_events(status, id);
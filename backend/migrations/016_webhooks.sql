@@ -0,0 +1,78 @@
+-- ============================================================
+-- PARTNER SCOPES (which spots a partner account may subscribe to)
+-- ============================================================
+CREATE TABLE partner_scopes (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  spot_id UUID REFERENCES spots(id) ON DELETE CASCADE,
+  building_name VARCHAR(200),
+  created_by UUID REFERENCES auth.users(id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+
+  CONSTRAINT partner_scope_target CHECK ((spot_id IS NULL) <> (building_name IS NULL))
+);
+
+CREATE UNIQUE INDEX idx_partner_scopes_spot ON partner_scopes(user_id, spot_id) WHERE spot_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_partner_scopes_building ON partner_scopes(user_id, building_name) WHERE building_name IS NOT NULL;
+
+-- ============================================================
+-- WEBHOOK SUBSCRIPTIONS
+-- ============================================================
+CREATE TABLE webhook_subscriptions (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  owner_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  url TEXT NOT NULL,
+  description VARCHAR(200),
+
+  -- HMAC-SHA256 signing key, shown to the owner only on create and rotate
+  secret TEXT NOT NULL,
+
+  -- Events fire for spots listed here or located in these buildings
+  spot_ids UUID[] NOT NULL DEFAULT '{}',
+  building_names TEXT[] NOT NULL DEFAULT '{}',
+  event_types TEXT[] NOT NULL,
+
+  active BOOLEAN NOT NULL DEFAULT true,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+  CONSTRAINT webhook_has_scope CHECK (cardinality(spot_ids) > 0 OR cardinality(building_names) > 0),
+  CONSTRAINT webhook_has_events CHECK (cardinality(event_types) > 0)
+);
+
+CREATE INDEX idx_webhook_subscriptions_owner ON webhook_subscriptions(owner_id);
+CREATE INDEX idx_webhook_subscriptions_spots ON webhook_subscriptions USING GIN (spot_ids) WHERE active;
+CREATE INDEX idx_webhook_subscriptions_buildings ON webhook_subscriptions USING GIN (building_names) WHERE active;
+
+-- ============================================================
+-- WEBHOOK DELIVERIES (delivery log and retry queue)
+-- ============================================================
+CREATE TABLE webhook_deliveries (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+  event_id UUID NOT NULL,
+  event_type VARCHAR(100) NOT NULL,
+  payload JSONB NOT NULL,
+
+  status VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'succeeded', 'failed')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  locked_until TIMESTAMPTZ,
+
+  -- Result of the latest attempt
+  response_status INTEGER,
+  response_body TEXT,
+  duration_ms INTEGER,
+  last_error TEXT,
+
+  delivered_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+  -- The outbox delivers at least once; fan-out must not duplicate deliveries
+  CONSTRAINT unique_webhook_delivery UNIQUE(subscription_id, event_id)
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created_at DESC);
@@ -0,0 +1,92 @@
+-- ============================================================
+-- DELTA SYNC (change tracking for GET /sync)
+-- ============================================================
+-- Every synced row records the id of the transaction that last changed it.
+-- A sync token holds the xmin of the snapshot it was read with: any
+-- transaction still running at that point has an id >= xmin, so its changes
+-- are picked up by the next sync even if it commits after later transactions.
+
+CREATE OR REPLACE FUNCTION touch_sync_xid()
+RETURNS TRIGGER AS $$
+BEGIN
+  NEW.sync_xid := pg_current_xact_id();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE spots ADD COLUMN sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE friendships ADD COLUMN sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE spot_save_requests ADD COLUMN sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE notifications ADD COLUMN sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE TRIGGER touch_spots_sync_xid BEFORE UPDATE ON spots
+  FOR EACH ROW EXECUTE FUNCTION touch_sync_xid();
+CREATE TRIGGER touch_friendships_sync_xid BEFORE UPDATE ON friendships
+  FOR EACH ROW EXECUTE FUNCTION touch_sync_xid();
+CREATE TRIGGER touch_spot_save_requests_sync_xid BEFORE UPDATE ON spot_save_requests
+  FOR EACH ROW EXECUTE FUNCTION touch_sync_xid();
+
+-- Push dispatch bookkeeping isn't synced; only what the inbox shows is
+CREATE TRIGGER touch_notifications_sync_xid BEFORE UPDATE ON notifications
+  FOR EACH ROW
+  WHEN (OLD.read IS DISTINCT FROM NEW.read
+    OR OLD.title IS DISTINCT FROM NEW.title
+    OR OLD.body IS DISTINCT FROM NEW.body
+    OR OLD.data IS DISTINCT FROM NEW.data)
+  EXECUTE FUNCTION touch_sync_xid();
+
+CREATE INDEX idx_spots_sync ON spots(sync_xid);
+CREATE INDEX idx_friendships_user_sync ON friendships(user_id, sync_xid);
+CREATE INDEX idx_friendships_friend_sync ON friendships(friend_id, sync_xid);
+CREATE INDEX idx_spot_save_requests_requester_sync ON spot_save_requests(requester_id, sync_xid);
+CREATE INDEX idx_spot_save_requests_saver_sync ON spot_save_requests(saver_id, sync_xid);
+CREATE INDEX idx_notifications_user_sync ON notifications(user_id, sync_xid);
+
+-- ============================================================
+-- SYNC TOMBSTONES (deleted rows clients may still have cached)
+-- ============================================================
+CREATE TABLE sync_tombstones (
+  id BIGSERIAL PRIMARY KEY,
+  entity_type VARCHAR(30) NOT NULL
+    CHECK (entity_type IN ('spot', 'friendship', 'spot_save_request', 'notification')),
+  entity_id UUID NOT NULL,
+
+  -- Users who may have cached the row; NULL means everyone
+  user_ids UUID[],
+
+  sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id(),
+  deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_sync_tombstones_xid ON sync_tombstones(sync_xid);
+CREATE INDEX idx_sync_tombstones_users ON sync_tombstones USING GIN (user_ids);
+CREATE INDEX idx_sync_tombstones_deleted ON sync_tombstones(deleted_at);
+
+-- Function: records a tombstone for a deleted row. TG_ARGV[0] is the entity type.
+CREATE OR REPLACE FUNCTION record_sync_tombstone()
+RETURNS TRIGGER AS $$
+DECLARE
+  audience UUID[];
+BEGIN
+  IF TG_ARGV[0] = 'friendship' THEN
+    audience := ARRAY[OLD.user_id, OLD.friend_id];
+  ELSIF TG_ARGV[0] = 'spot_save_request' THEN
+    audience := ARRAY[OLD.requester_id, OLD.saver_id];
+  ELSIF TG_ARGV[0] = 'notification' THEN
+    audience := ARRAY[OLD.user_id];
+  END IF;
+
+  INSERT INTO sync_tombstones (entity_type, entity_id, user_ids)
+  VALUES (TG_ARGV[0], OLD.id, audience);
+  RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER spots_sync_tombstone AFTER DELETE ON spots
+  FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone('spot');
+CREATE TRIGGER friendships_sync_tombstone AFTER DELETE ON friendships
+  FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone('friendship');
+CREATE TRIGGER spot_save_requests_sync_tombstone AFTER DELETE ON spot_save_requests
+  FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone('spot_save_request');
+CREATE TRIGGER notifications_sync_tombstone AFTER DELETE ON notifications
+  FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone('notification');
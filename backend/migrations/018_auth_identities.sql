@@ -0,0 +1,50 @@
+-- ============================================================
+-- AUTH IDENTITIES (token issuer + subject -> profile)
+-- ============================================================
+-- Tokens identify users by an issuer-specific subject such as "auth0|abc123".
+-- Profiles are keyed by our own UUID, and are created on the first
+-- authenticated request, so they no longer need a Supabase auth.users row.
+
+ALTER TABLE profiles ALTER COLUMN id SET DEFAULT uuid_generate_v4();
+
+-- Repoint every user reference from auth.users to profiles. Existing
+-- constraints keep their names and delete actions. They are added NOT VALID
+-- so rows left over from users without a profile don't block the migration;
+-- new rows are still checked.
+DO $$
+DECLARE
+  fk RECORD;
+BEGIN
+  FOR fk IN
+    SELECT c.conname, c.conrelid::regclass AS tbl, pg_get_constraintdef(c.oid) AS def
+    FROM pg_constraint c
+    WHERE c.contype = 'f'
+      AND c.confrelid = 'auth.users'::regclass
+      AND c.connamespace = 'public'::regnamespace
+  LOOP
+    EXECUTE format('ALTER TABLE %s DROP CONSTRAINT %I', fk.tbl, fk.conname);
+
+    IF fk.tbl <> 'profiles'::regclass THEN
+      EXECUTE format('ALTER TABLE %s ADD CONSTRAINT %I %s NOT VALID',
+        fk.tbl, fk.conname,
+        replace(fk.def, 'REFERENCES auth.users(id)', 'REFERENCES profiles(id)'));
+    END IF;
+  END LOOP;
+END $$;
+
+CREATE TABLE auth_identities (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  issuer TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  user_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+
+  -- Claims as of the last sign-in, for support lookups
+  email TEXT,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT unique_auth_identity UNIQUE(issuer, subject)
+);
+
+CREATE INDEX idx_auth_identities_user ON auth_identities(user_id);
@@ -0,0 +1,35 @@
+-- ============================================================
+-- ROLES (privileged access beyond the implicit student role)
+-- ============================================================
+-- Roles can also come from token claims; this table holds the ones granted
+-- inside havn. See internal/rbac for what each role may do.
+CREATE TABLE user_roles (
+  user_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+  role VARCHAR(30) NOT NULL CHECK (role IN ('moderator', 'campus_admin', 'super_admin')),
+  granted_by UUID REFERENCES profiles(id) ON DELETE SET NULL,
+  granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (user_id, role)
+);
+
+CREATE INDEX idx_user_roles_role ON user_roles(role);
+
+-- ============================================================
+-- ROLE AUDIT LOG (every grant and revocation)
+-- ============================================================
+-- No foreign keys: the history must outlive the users it mentions.
+CREATE TABLE role_audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  user_id UUID NOT NULL,
+  role VARCHAR(30) NOT NULL,
+  action VARCHAR(10) NOT NULL CHECK (action IN ('grant', 'revoke')),
+
+  -- NULL when granted at startup from ADMIN_USER_IDS
+  actor_id UUID,
+  reason TEXT,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_role_audit_user ON role_audit_log(user_id, created_at DESC);
+CREATE INDEX idx_role_audit_created ON role_audit_log(created_at DESC);
@@ -0,0 +1,30 @@
+-- ============================================================
+-- FRIEND NEARBY ALERTS ARE OPT-IN
+-- ============================================================
+-- Nearby alerts reveal where friends are studying, so nobody receives them
+-- until they turn them on. Stored values of true are indistinguishable from
+-- the old default, so every profile starts opted out.
+ALTER TABLE profiles ALTER COLUMN preferences SET DEFAULT '{
+  "noise_tolerance": "moderate",
+  "outlet_required": false,
+  "auto_checkout_hours": 4,
+  "notifications_enabled": true,
+  "notifications": {
+    "friend_requests": true,
+    "spot_saves": true,
+    "friend_nearby": false,
+    "spot_availability": true
+  },
+  "quiet_hours": {
+    "enabled": false,
+    "start": "22:00",
+    "end": "07:00",
+    "timezone": "America/Los_Angeles",
+    "mode": "defer"
+  },
+  "daily_push_cap": 0
+}'::jsonb;
+
+UPDATE profiles
+SET preferences = jsonb_set(preferences, '{notifications,friend_nearby}', 'false'::jsonb)
+WHERE preferences->'notifications'->'friend_nearby' = 'true'::jsonb;